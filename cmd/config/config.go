@@ -3,6 +3,7 @@ package config
 import (
 	"flag"
 	"log"
+	"time"
 
 	"github.com/caarlos0/env/v11"
 )
@@ -13,8 +14,95 @@ type Config struct {
 	FilePath  string `env:"FILE_STORAGE_PATH"`
 	DB        string `env:"DATABASE_DSN"`
 	SecretKey string `env:"SECRET_KEY"`
+	// AuthRequired отключает автоматическое создание анонимных пользователей
+	AuthRequired bool `env:"AUTH_REQUIRED"`
+	// AdminLogins — логины через запятую, получающие роль администратора
+	AdminLogins string `env:"ADMIN_LOGINS"`
+	// JWTKeys — ключи подписи через запятую: kid:hs256:secret или kid:pem:path
+	JWTKeys      string `env:"JWT_KEYS"`
+	JWTActiveKID string `env:"JWT_ACTIVE_KID"`
+	// SessionLifetime — предельный срок сессии, дальше refresh-токен не продлевает
+	SessionLifetime time.Duration `env:"SESSION_LIFETIME"`
+
+	// вход через OpenID Connect, включается заданным OIDC_ISSUER
+	OIDCIssuer       string `env:"OIDC_ISSUER"`
+	OIDCClientID     string `env:"OIDC_CLIENT_ID"`
+	OIDCClientSecret string `env:"OIDC_CLIENT_SECRET"`
+	// по умолчанию BASE_URL + /api/auth/oidc/callback
+	OIDCRedirectURL string `env:"OIDC_REDIRECT_URL"`
+
+	// лимиты запросов по группам маршрутов вида 60/m или 10/s:20, пустой
+	// лимит выключает ограничение
+	RateLimitWrite    string `env:"RATE_LIMIT_WRITE"`
+	RateLimitRedirect string `env:"RATE_LIMIT_REDIRECT"`
+	RateLimitAPI      string `env:"RATE_LIMIT_API"`
+	RateLimitAuth     string `env:"RATE_LIMIT_AUTH"`
+	// RateLimitShared хранит лимиты в базе, общей для всех реплик
+	RateLimitShared bool `env:"RATE_LIMIT_SHARED"`
+	// TrustedProxies — прокси через запятую, чьему X-Forwarded-For верим
+	// при определении адреса клиента
+	TrustedProxies string `env:"TRUSTED_PROXIES"`
+
+	// лимиты на ссылки пользователя, 0 — без ограничения
+	QuotaMaxLinks   int `env:"QUOTA_MAX_LINKS"`
+	QuotaMaxBatch   int `env:"QUOTA_MAX_BATCH"`
+	QuotaDailyLinks int `env:"QUOTA_DAILY_LINKS"`
+
+	// URLSchemes — схемы ссылок через запятую, которые можно сокращать
+	URLSchemes string `env:"URL_SCHEMES"`
+	// как приводить ссылки к одному виду, кроме регистра и punycode хоста
+	URLStripDefaultPort bool `env:"URL_STRIP_DEFAULT_PORT"`
+	URLStripFragment    bool `env:"URL_STRIP_FRAGMENT"`
+	URLSortQuery        bool `env:"URL_SORT_QUERY"`
+	// Dedup — когда ссылка считается повтором: global, user или off
+	Dedup string `env:"DEDUP"`
+
+	// SafetyBlocklist — файл со списком блокировки, перечитывается раз в
+	// SafetyReloadInterval, если изменился
+	SafetyBlocklist      string        `env:"SAFETY_BLOCKLIST"`
+	SafetyReloadInterval time.Duration `env:"SAFETY_RELOAD_INTERVAL"`
+	// SafetyLookupURL — внешний сервис проверки ссылок, его ответы
+	// хранятся SafetyLookupTTL
+	SafetyLookupURL string        `env:"SAFETY_LOOKUP_URL"`
+	SafetyLookupTTL time.Duration `env:"SAFETY_LOOKUP_TTL"`
+
+	// ReportThreshold — после скольких жалоб с разных адресов ссылка
+	// отключается до решения модератора, 0 — не отключать
+	ReportThreshold int `env:"REPORT_THRESHOLD"`
+
+	// ClickFlushInterval — как часто счётчики переходов сбрасываются в
+	// хранилище
+	ClickFlushInterval time.Duration `env:"CLICK_FLUSH_INTERVAL"`
+
+	CacheSize        int           `env:"CACHE_SIZE"`
+	CacheTTL         time.Duration `env:"CACHE_TTL"`
+	CacheNegativeTTL time.Duration `env:"CACHE_NEGATIVE_TTL"`
+
+	SnapshotPath     string        `env:"SNAPSHOT_PATH"`
+	SnapshotInterval time.Duration `env:"SNAPSHOT_INTERVAL"`
+
+	MirrorFilePath          string        `env:"MIRROR_FILE_STORAGE_PATH"`
+	MirrorDB                string        `env:"MIRROR_DATABASE_DSN"`
+	MirrorFallback          bool          `env:"MIRROR_FALLBACK"`
+	MirrorReconcileInterval time.Duration `env:"MIRROR_RECONCILE_INTERVAL"`
 }
 
+// значения по умолчанию для флагов и для запуска только с переменными
+// окружения, когда флаги не разбираются
+const (
+	defaultSessionLifetime         = 30 * 24 * time.Hour
+	defaultURLSchemes              = "http,https"
+	defaultDedup                   = "global"
+	defaultSafetyReloadInterval    = 30 * time.Second
+	defaultSafetyLookupTTL         = 10 * time.Minute
+	defaultReportThreshold         = 5
+	defaultClickFlushInterval      = 10 * time.Second
+	defaultSnapshotInterval        = 5 * time.Minute
+	defaultCacheTTL                = 5 * time.Minute
+	defaultCacheNegativeTTL        = 10 * time.Second
+	defaultMirrorReconcileInterval = 10 * time.Minute
+)
+
 func GetCLParams() Config {
 	var config Config
 
@@ -25,7 +113,7 @@ func GetCLParams() Config {
 	}
 
 	if config.BaseURL != "" && config.Address != "" {
-		return config
+		return withDefaults(config)
 	}
 
 	if config.FilePath == "" {
@@ -40,6 +128,158 @@ func GetCLParams() Config {
 		flag.StringVar(&config.SecretKey, "k", "", "secret key for jwt token")
 	}
 
+	if config.JWTKeys == "" {
+		flag.StringVar(&config.JWTKeys, "jwt-keys", "", "jwt signing keys: kid:hs256:secret or kid:pem:path, comma separated")
+	}
+
+	if config.JWTActiveKID == "" {
+		flag.StringVar(&config.JWTActiveKID, "jwt-active-kid", "", "kid of the key used to sign new tokens")
+	}
+
+	if config.SessionLifetime == 0 {
+		flag.DurationVar(&config.SessionLifetime, "session-lifetime", defaultSessionLifetime, "absolute session lifetime, refresh tokens do not extend it")
+	}
+
+	if config.OIDCIssuer == "" {
+		flag.StringVar(&config.OIDCIssuer, "oidc-issuer", "", "OpenID Connect issuer URL")
+	}
+
+	if config.OIDCClientID == "" {
+		flag.StringVar(&config.OIDCClientID, "oidc-client-id", "", "OpenID Connect client id")
+	}
+
+	if config.OIDCClientSecret == "" {
+		flag.StringVar(&config.OIDCClientSecret, "oidc-client-secret", "", "OpenID Connect client secret")
+	}
+
+	if config.OIDCRedirectURL == "" {
+		flag.StringVar(&config.OIDCRedirectURL, "oidc-redirect-url", "", "OpenID Connect redirect URL")
+	}
+
+	if config.AdminLogins == "" {
+		flag.StringVar(&config.AdminLogins, "admins", "", "comma separated logins that get the admin role")
+	}
+
+	if !config.AuthRequired {
+		flag.BoolVar(&config.AuthRequired, "auth-required", false, "reject requests without a token instead of creating a new user")
+	}
+
+	if config.RateLimitWrite == "" {
+		flag.StringVar(&config.RateLimitWrite, "rate-write", "", "rate limit of link creation, e.g. 30/m:10, empty disables the limit")
+	}
+
+	if config.RateLimitRedirect == "" {
+		flag.StringVar(&config.RateLimitRedirect, "rate-redirect", "", "rate limit of redirects, empty disables the limit")
+	}
+
+	if config.RateLimitAPI == "" {
+		flag.StringVar(&config.RateLimitAPI, "rate-api", "", "rate limit of the user api, empty disables the limit")
+	}
+
+	if config.RateLimitAuth == "" {
+		flag.StringVar(&config.RateLimitAuth, "rate-auth", "", "rate limit of login and registration, empty disables the limit")
+	}
+
+	if !config.RateLimitShared {
+		flag.BoolVar(&config.RateLimitShared, "rate-shared", false, "keep rate limits in the database shared by all replicas")
+	}
+
+	if config.TrustedProxies == "" {
+		flag.StringVar(&config.TrustedProxies, "trusted-proxies", "", "comma separated proxies trusted to set X-Forwarded-For")
+	}
+
+	if config.QuotaMaxLinks == 0 {
+		flag.IntVar(&config.QuotaMaxLinks, "quota-links", 0, "max active links per user, 0 disables the limit")
+	}
+
+	if config.QuotaMaxBatch == 0 {
+		flag.IntVar(&config.QuotaMaxBatch, "quota-batch", 0, "max links in one batch request, 0 disables the limit")
+	}
+
+	if config.QuotaDailyLinks == 0 {
+		flag.IntVar(&config.QuotaDailyLinks, "quota-daily", 0, "max new links per user per day, 0 disables the limit")
+	}
+
+	if config.URLSchemes == "" {
+		flag.StringVar(&config.URLSchemes, "url-schemes", defaultURLSchemes, "comma separated url schemes allowed to be shortened")
+	}
+
+	if !config.URLStripDefaultPort {
+		flag.BoolVar(&config.URLStripDefaultPort, "url-strip-port", false, "drop default ports from shortened urls")
+	}
+
+	if !config.URLStripFragment {
+		flag.BoolVar(&config.URLStripFragment, "url-strip-fragment", false, "drop fragments from shortened urls")
+	}
+
+	if !config.URLSortQuery {
+		flag.BoolVar(&config.URLSortQuery, "url-sort-query", false, "sort query parameters of shortened urls")
+	}
+
+	if config.Dedup == "" {
+		flag.StringVar(&config.Dedup, "dedup", defaultDedup, "deduplication of shortened urls: global, user or off")
+	}
+
+	if config.SafetyBlocklist == "" {
+		flag.StringVar(&config.SafetyBlocklist, "blocklist", "", "path to the blocklist of domains and url patterns")
+	}
+
+	if config.SafetyReloadInterval == 0 {
+		flag.DurationVar(&config.SafetyReloadInterval, "blocklist-reload", defaultSafetyReloadInterval, "interval between checks of the blocklist file for changes")
+	}
+
+	if config.SafetyLookupURL == "" {
+		flag.StringVar(&config.SafetyLookupURL, "safety-lookup", "", "url of the external link safety lookup api")
+	}
+
+	if config.SafetyLookupTTL == 0 {
+		flag.DurationVar(&config.SafetyLookupTTL, "safety-lookup-ttl", defaultSafetyLookupTTL, "ttl of cached safety lookup results")
+	}
+
+	if config.ReportThreshold == 0 {
+		flag.IntVar(&config.ReportThreshold, "report-threshold", defaultReportThreshold, "reports from different addresses that disable a link until moderation, 0 disables it")
+	}
+
+	if config.ClickFlushInterval == 0 {
+		flag.DurationVar(&config.ClickFlushInterval, "click-flush", defaultClickFlushInterval, "interval between writes of click counters to the storage")
+	}
+
+	if config.SnapshotPath == "" {
+		flag.StringVar(&config.SnapshotPath, "snapshot", "", "path to snapshot of the in-memory storage")
+	}
+
+	if config.SnapshotInterval == 0 {
+		flag.DurationVar(&config.SnapshotInterval, "snapshot-interval", defaultSnapshotInterval, "interval between snapshots of the in-memory storage")
+	}
+
+	if config.CacheSize == 0 {
+		flag.IntVar(&config.CacheSize, "cache-size", 0, "max number of cached links, 0 disables the cache")
+	}
+
+	if config.CacheTTL == 0 {
+		flag.DurationVar(&config.CacheTTL, "cache-ttl", defaultCacheTTL, "ttl of cached links")
+	}
+
+	if config.CacheNegativeTTL == 0 {
+		flag.DurationVar(&config.CacheNegativeTTL, "cache-negative-ttl", defaultCacheNegativeTTL, "ttl of cached unknown links")
+	}
+
+	if config.MirrorFilePath == "" {
+		flag.StringVar(&config.MirrorFilePath, "mirror-f", "", "path to file of the secondary storage")
+	}
+
+	if config.MirrorDB == "" {
+		flag.StringVar(&config.MirrorDB, "mirror-d", "", "db connection settings of the secondary storage")
+	}
+
+	if !config.MirrorFallback {
+		flag.BoolVar(&config.MirrorFallback, "mirror-fallback", false, "read from the secondary storage when the primary fails")
+	}
+
+	if config.MirrorReconcileInterval == 0 {
+		flag.DurationVar(&config.MirrorReconcileInterval, "mirror-reconcile", defaultMirrorReconcileInterval, "interval between storage reconciliations, 0 disables it")
+	}
+
 	flag.StringVar(&config.Address, "a", "localhost:8080", "http server adress")
 	flag.StringVar(&config.BaseURL, "b", "http://localhost:8080", "base URL")
 
@@ -47,3 +287,53 @@ func GetCLParams() Config {
 
 	return config
 }
+
+// withDefaults заполняет незаданные настройки теми же значениями, что
+// получили бы флаги.
+func withDefaults(config Config) Config {
+	if config.SessionLifetime == 0 {
+		config.SessionLifetime = defaultSessionLifetime
+	}
+
+	if config.URLSchemes == "" {
+		config.URLSchemes = defaultURLSchemes
+	}
+
+	if config.Dedup == "" {
+		config.Dedup = defaultDedup
+	}
+
+	if config.SafetyReloadInterval == 0 {
+		config.SafetyReloadInterval = defaultSafetyReloadInterval
+	}
+
+	if config.SafetyLookupTTL == 0 {
+		config.SafetyLookupTTL = defaultSafetyLookupTTL
+	}
+
+	if config.ReportThreshold == 0 {
+		config.ReportThreshold = defaultReportThreshold
+	}
+
+	if config.ClickFlushInterval == 0 {
+		config.ClickFlushInterval = defaultClickFlushInterval
+	}
+
+	if config.SnapshotInterval == 0 {
+		config.SnapshotInterval = defaultSnapshotInterval
+	}
+
+	if config.CacheTTL == 0 {
+		config.CacheTTL = defaultCacheTTL
+	}
+
+	if config.CacheNegativeTTL == 0 {
+		config.CacheNegativeTTL = defaultCacheNegativeTTL
+	}
+
+	if config.MirrorReconcileInterval == 0 {
+		config.MirrorReconcileInterval = defaultMirrorReconcileInterval
+	}
+
+	return config
+}
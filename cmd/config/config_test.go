@@ -0,0 +1,26 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetCLParamsFromEnv(t *testing.T) {
+	t.Setenv("ADDRESS", "localhost:9090")
+	t.Setenv("BASE_URL", "http://localhost:9090")
+	t.Setenv("CACHE_TTL", "1m")
+
+	config := GetCLParams()
+
+	// без флагов действуют те же значения по умолчанию
+	assert.Equal(t, "localhost:9090", config.Address)
+	assert.Equal(t, time.Minute, config.CacheTTL)
+	assert.Equal(t, defaultCacheNegativeTTL, config.CacheNegativeTTL)
+	assert.Equal(t, defaultSnapshotInterval, config.SnapshotInterval)
+	assert.Equal(t, defaultSafetyReloadInterval, config.SafetyReloadInterval)
+	assert.Equal(t, defaultReportThreshold, config.ReportThreshold)
+	assert.Equal(t, defaultClickFlushInterval, config.ClickFlushInterval)
+	assert.Equal(t, defaultDedup, config.Dedup)
+}
@@ -5,8 +5,12 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"strings"
 	"time"
 
+	"github.com/BazNick/shortlink/internal/app/apperr"
+	"github.com/BazNick/shortlink/internal/app/functions"
+	"github.com/BazNick/shortlink/internal/app/storage"
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v4"
 )
@@ -14,15 +18,55 @@ import (
 type Claims struct {
 	jwt.RegisteredClaims
 	UserID string
+	// Role — роль пользователя, например RoleAdmin, у обычных пусто
+	Role string `json:"role,omitempty"`
+	// Type — TokenTypeRefresh у refresh-токена, у access-токена пусто
+	Type string `json:"typ,omitempty"`
+	// AuthTime — начало сессии, от него считается её предельный срок
+	AuthTime *jwt.NumericDate `json:"auth_time,omitempty"`
 }
 
+type (
+	Option func(*options)
+
+	options struct {
+		required bool
+		apiKeys  storage.APIKeyStore
+		users    storage.UserStore
+		lifetime time.Duration
+	}
+)
+
 const (
 	CookieName   = "token"
 	CookiePath   = "/"
 	CookieDomain = ""
 	TokenExp     = time.Hour * 3
+
+	HeaderName   = "Authorization"
+	BearerPrefix = "Bearer "
+
+	APIKeyHeader = "X-API-Key"
+
+	// ключ контекста, выставляется, если пользователь создан этим запросом
+	NewUserKey = "newUser"
+	// ключ контекста с storage.APIKey, если запрос пришёл с API-ключом
+	APIKeyKey = "apiKey"
+	// ключ контекста с ролью пользователя из токена
+	RoleKey = "role"
+
+	// чаще этого время последнего использования ключа не обновляется
+	apiKeyTouchInterval = time.Minute
 )
 
+// RequireToken запрещает выдавать новых анонимных пользователей:
+// запросы без действующего токена получают 401.
+func RequireToken() Option {
+	return func(o *options) {
+		o.required = true
+	}
+}
+
 func randBytes(n int) (string, error) {
 	b := make([]byte, n)
 	_, err := rand.Read(b)
@@ -33,79 +77,260 @@ func randBytes(n int) (string, error) {
 	return uuid, nil
 }
 
-func Auth(secret string) gin.HandlerFunc {
+// NewUserID — идентификатор нового пользователя, анонимного или
+// зарегистрированного.
+func NewUserID() (string, error) {
+	return randBytes(16)
+}
+
+// WithAPIKeys включает вход по заголовку X-API-Key.
+func WithAPIKeys(keys storage.APIKeyStore) Option {
+	return func(o *options) {
+		o.apiKeys = keys
+	}
+}
+
+// WithUsers перечитывает роль пользователя при каждом перевыпуске
+// токенов, чтобы отозванная роль не продлевалась вместе с сессией.
+func WithUsers(users storage.UserStore) Option {
+	return func(o *options) {
+		o.users = users
+	}
+}
+
+// renew перевыпускает токены пользователя из claims с его текущей ролью.
+func (o options) renew(kr *Keyring, claims *Claims) (*session, error) {
+	if o.users != nil {
+		user, err := o.users.UserByID(claims.UserID)
+		switch {
+		case errors.Is(err, apperr.ErrUserNotFound):
+			// анонимный пользователь, ролей у него нет
+			claims.Role = ""
+		case err != nil:
+			return nil, err
+		default:
+			claims.Role = user.Role
+		}
+	}
+	return issueSession(kr, o.lifetime, claims)
+}
+
+// Auth — Middleware со связкой из одного секрета. Пустой секрет —
+// ошибка настройки, поэтому Auth паникует уже при сборке маршрутов.
+func Auth(secret string, opts ...Option) gin.HandlerFunc {
+	kr, err := secretKeyring(secret)
+	if err != nil {
+		panic(err)
+	}
+	return Middleware(kr, opts...)
+}
+
+func newOptions(opts []Option) options {
+	o := options{lifetime: DefaultSessionLifetime}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// Middleware — то же, что Auth, но токены подписываются и проверяются
+// связкой ключей.
+func Middleware(kr *Keyring, opts ...Option) gin.HandlerFunc {
+	o := newOptions(opts)
+
 	return func(c *gin.Context) {
-		cookie, err := c.Cookie(CookieName)
+		if key := c.GetHeader(APIKeyHeader); key != "" && o.apiKeys != nil {
+			authAPIKey(c, o.apiKeys, key)
+			return
+		}
+
 		// парсим токен, если есть
-		if err == nil && cookie != "" {
-			claims, err := ParseToken(cookie, secret)
-			if err == nil {
-				c.Set("userID", claims.UserID)
-				c.Next()
-				return
+		for _, token := range requestTokens(c) {
+			claims, err := kr.Parse(token)
+			if err != nil || claims.Type != "" {
+				continue
+			}
+
+			// токен скоро истечёт — перевыпускаем его тому же пользователю
+			if claims.ExpiresAt != nil && time.Until(claims.ExpiresAt.Time) < RefreshWindow {
+				if s, err := o.renew(kr, claims); err == nil {
+					setSession(c, s)
+				}
+			}
+
+			c.Set("userID", claims.UserID)
+			c.Set(RoleKey, claims.Role)
+			c.Next()
+			return
+		}
+
+		// access-токен истёк, но сессию можно продолжить по refresh-токену
+		if refresh, err := c.Cookie(RefreshCookieName); err == nil {
+			if claims, err := parseRefresh(kr, refresh); err == nil {
+				s, err := o.renew(kr, claims)
+				if err == nil {
+					setSession(c, s)
+					c.Set("userID", claims.UserID)
+					c.Set(RoleKey, claims.Role)
+					c.Next()
+					return
+				}
 			}
 		}
 
-		// Если токена нет — создаём новый
-		tokenString, err := GenToken(secret)
+		if o.required {
+			c.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+
+		// Если токена нет — создаём нового пользователя
+		userID, err := NewUserID()
 		if err != nil {
 			c.AbortWithError(http.StatusInternalServerError, err)
 			return
 		}
 
-		claims, err := ParseToken(tokenString, secret)
+		s, err := issueSession(kr, o.lifetime, &Claims{UserID: userID})
 		if err != nil {
 			c.AbortWithError(http.StatusInternalServerError, err)
 			return
 		}
 
-		c.SetCookie(
-			CookieName,
-			tokenString,
-			int(TokenExp.Seconds()),
-			CookiePath,
-			CookieDomain,
-			false,
-			true,
-		)
-		c.Set("userID", claims.UserID)
+		setSession(c, s)
+		c.Set("userID", userID)
+		c.Set(NewUserKey, true)
+		c.Next()
+	}
+}
+
+// Required пропускает только пользователей, пришедших со своим токеном.
+// Ставится на маршруты, где у только что созданного пользователя
+// заведомо ничего нет (его ссылки, удаление).
+func Required() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetBool(NewUserKey) {
+			c.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequireScope не пускает API-ключи без нужного права. Запросы с токеном
+// пользователя проходят всегда.
+func RequireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if key, ok := c.Get(APIKeyKey); ok && !key.(storage.APIKey).HasScope(scope) {
+			c.AbortWithStatus(http.StatusForbidden)
+			return
+		}
+		c.Next()
+	}
+}
+
+// RoleFunc — текущая роль пользователя, не из токена.
+type RoleFunc func(userID string) (string, error)
+
+// RequireRole пускает только пользователей с ролью role в токене. API-ключи
+// ролей не несут и сюда не проходят. Если задан current, роль ещё и
+// сверяется с ним: отозванная роль перестаёт действовать сразу, а не когда
+// истечёт токен.
+func RequireRole(role string, current RoleFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetString(RoleKey) != role {
+			c.AbortWithStatus(http.StatusForbidden)
+			return
+		}
+
+		if current != nil {
+			actual, err := current(c.GetString("userID"))
+			if err != nil {
+				c.AbortWithStatus(http.StatusInternalServerError)
+				return
+			}
+			if actual != role {
+				c.AbortWithStatus(http.StatusForbidden)
+				return
+			}
+		}
+		c.Next()
+	}
+}
+
+// SessionOnly закрывает маршрут для API-ключей, например управление самими
+// ключами.
+func SessionOnly() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, ok := c.Get(APIKeyKey); ok {
+			c.AbortWithStatus(http.StatusForbidden)
+			return
+		}
 		c.Next()
 	}
 }
 
+func authAPIKey(c *gin.Context, keys storage.APIKeyStore, raw string) {
+	key, err := keys.APIKeyByHash(functions.HashAPIKey(raw))
+	if err != nil || key.RevokedAt != nil {
+		c.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
+
+	now := time.Now()
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) > apiKeyTouchInterval {
+		keys.TouchAPIKey(key.ID, now)
+	}
+
+	c.Set("userID", key.UserID)
+	c.Set(APIKeyKey, key)
+	c.Next()
+}
+
+// requestTokens собирает токены из cookie и заголовка
+// Authorization: Bearer <jwt>, cookie проверяется первой.
+func requestTokens(c *gin.Context) []string {
+	var tokens []string
+
+	if cookie, err := c.Cookie(CookieName); err == nil && cookie != "" {
+		tokens = append(tokens, cookie)
+	}
+
+	header := c.GetHeader(HeaderName)
+	if len(header) > len(BearerPrefix) && strings.EqualFold(header[:len(BearerPrefix)], BearerPrefix) {
+		tokens = append(tokens, strings.TrimSpace(header[len(BearerPrefix):]))
+	}
+
+	return tokens
+}
+
 func GenToken(secretKey string) (string, error) {
+	kr, err := secretKeyring(secretKey)
+	if err != nil {
+		return "", err
+	}
+	return NewToken(kr)
+}
+
+// NewToken выпускает токен для нового пользователя.
+func NewToken(kr *Keyring) (string, error) {
 	// генерируем последовательность рандомных байт для ID пользователя
 	id, err := randBytes(16)
 	if err != nil {
 		return "", err
 	}
-	// создаём новый токен с алгоритмом подписи HS256 и утверждениями — Claims
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, Claims{
+	// создаём новый токен и подписываем активным ключом связки
+	return kr.Sign(&Claims{
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(TokenExp)),
 		},
 		UserID: id,
 	})
-
-	// создаём строку токена
-	tokenString, err := token.SignedString([]byte(secretKey))
-	if err != nil {
-		return "", err
-	}
-	return tokenString, nil
 }
 
 func ParseToken(tokenStr, secret string) (*Claims, error) {
-	claims := &Claims{}
-	token, err := jwt.ParseWithClaims(
-		tokenStr,
-		claims,
-		func(token *jwt.Token) (any, error) {
-			return []byte(secret), nil
-		},
-	)
-	if err != nil || !token.Valid {
-		return nil, errors.New("invalid token")
+	kr, err := secretKeyring(secret)
+	if err != nil {
+		return nil, err
 	}
-	return claims, nil
+	return kr.Parse(tokenStr)
 }
@@ -0,0 +1,117 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuth(t *testing.T) {
+	secret := "secret_key"
+
+	token, err := GenToken(secret)
+	require.NoError(t, err)
+	claims, err := ParseToken(token, secret)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name         string
+		opts         []Option
+		cookie       string
+		header       string
+		required     bool
+		expectedCode int
+		expectedUser string
+		expectIssued bool
+	}{
+		{
+			name:         "Cookie token is accepted",
+			cookie:       token,
+			expectedCode: http.StatusOK,
+			expectedUser: claims.UserID,
+		},
+		{
+			name:         "Bearer token is accepted",
+			header:       "Bearer " + token,
+			expectedCode: http.StatusOK,
+			expectedUser: claims.UserID,
+		},
+		{
+			name:         "Broken cookie falls back to the bearer token",
+			cookie:       "broken",
+			header:       "Bearer " + token,
+			expectedCode: http.StatusOK,
+			expectedUser: claims.UserID,
+		},
+		{
+			name:         "New user is issued without a token",
+			expectedCode: http.StatusOK,
+			expectIssued: true,
+		},
+		{
+			name:         "Token is required by option",
+			opts:         []Option{RequireToken()},
+			header:       "Bearer broken",
+			expectedCode: http.StatusUnauthorized,
+		},
+		{
+			name:         "Required route rejects a new user",
+			required:     true,
+			expectedCode: http.StatusUnauthorized,
+			expectIssued: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			router := gin.New()
+			router.Use(Auth(secret, test.opts...))
+
+			handlers := []gin.HandlerFunc{}
+			if test.required {
+				handlers = append(handlers, Required())
+			}
+			handlers = append(handlers, func(c *gin.Context) {
+				c.String(http.StatusOK, c.GetString("userID"))
+			})
+			router.GET("/", handlers...)
+
+			request := httptest.NewRequest(http.MethodGet, "/", nil)
+			if test.cookie != "" {
+				request.AddCookie(&http.Cookie{Name: CookieName, Value: test.cookie})
+			}
+			if test.header != "" {
+				request.Header.Set(HeaderName, test.header)
+			}
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, request)
+
+			assert.Equal(t, test.expectedCode, w.Code)
+			if test.expectedUser != "" {
+				assert.Equal(t, test.expectedUser, w.Body.String())
+			}
+
+			issued := w.Header().Get(HeaderName)
+			assert.Equal(t, test.expectIssued, strings.HasPrefix(issued, BearerPrefix))
+		})
+	}
+}
+
+func TestAuthEmptySecret(t *testing.T) {
+	token, err := GenToken("secret_key")
+	require.NoError(t, err)
+
+	_, err = GenToken("")
+	assert.ErrorIs(t, err, ErrEmptySecret)
+
+	_, err = ParseToken(token, "")
+	assert.ErrorIs(t, err, ErrEmptySecret)
+
+	assert.Panics(t, func() { Auth("") })
+}
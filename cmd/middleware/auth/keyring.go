@@ -0,0 +1,297 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// DefaultKeyID — kid ключа, собранного из одного SECRET_KEY.
+const DefaultKeyID = "default"
+
+var ErrEmptySecret = errors.New("jwt secret is empty")
+
+type (
+	// Key — ключ подписи токенов. Для HMAC sign и verify совпадают,
+	// у ключа, загруженного из публичного PEM, sign пустой.
+	Key struct {
+		ID     string
+		Method jwt.SigningMethod
+		sign   any
+		verify any
+	}
+
+	// Keyring подписывает токены активным ключом, а проверяет любым из
+	// известных по kid, чтобы во время ротации старые токены продолжали
+	// работать. Алгоритм токена обязан совпадать с алгоритмом ключа.
+	Keyring struct {
+		active *Key
+		keys   map[string]*Key
+	}
+
+	JWK struct {
+		Kty string `json:"kty"`
+		Kid string `json:"kid"`
+		Alg string `json:"alg"`
+		Use string `json:"use"`
+		N   string `json:"n,omitempty"`
+		E   string `json:"e,omitempty"`
+		Crv string `json:"crv,omitempty"`
+		X   string `json:"x,omitempty"`
+	}
+
+	JWKSet struct {
+		Keys []JWK `json:"keys"`
+	}
+)
+
+func HMACKey(id, secret string) (*Key, error) {
+	if secret == "" {
+		return nil, ErrEmptySecret
+	}
+	return &Key{
+		ID:     id,
+		Method: jwt.SigningMethodHS256,
+		sign:   []byte(secret),
+		verify: []byte(secret),
+	}, nil
+}
+
+// PEMKey разбирает RSA или Ed25519 ключ. Из приватного ключа получается
+// ключ для подписи, из публичного — только для проверки.
+func PEMKey(id string, data []byte) (*Key, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("key %s: no PEM block found", id)
+	}
+
+	var parsed any
+	var err error
+
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("key %s: unsupported PEM block %q", id, block.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("key %s: %w", id, err)
+	}
+
+	key := &Key{ID: id}
+	switch k := parsed.(type) {
+	case *rsa.PrivateKey:
+		key.Method, key.sign, key.verify = jwt.SigningMethodRS256, k, &k.PublicKey
+	case *rsa.PublicKey:
+		key.Method, key.verify = jwt.SigningMethodRS256, k
+	case ed25519.PrivateKey:
+		key.Method, key.sign, key.verify = jwt.SigningMethodEdDSA, k, k.Public()
+	case ed25519.PublicKey:
+		key.Method, key.verify = jwt.SigningMethodEdDSA, k
+	default:
+		return nil, fmt.Errorf("key %s: unsupported key type %T", id, parsed)
+	}
+
+	return key, nil
+}
+
+// secretKeyring — связка из одного HMAC-ключа для Auth, GenToken и
+// ParseToken. Пустой секрет отвергается: подписанный им токен подделает
+// кто угодно.
+func secretKeyring(secret string) (*Keyring, error) {
+	key, err := HMACKey(DefaultKeyID, secret)
+	if err != nil {
+		return nil, err
+	}
+	return &Keyring{
+		active: key,
+		keys:   map[string]*Key{key.ID: key},
+	}, nil
+}
+
+func NewKeyring(active *Key, others ...*Key) (*Keyring, error) {
+	if active == nil || active.sign == nil {
+		return nil, errors.New("active jwt key must be able to sign")
+	}
+
+	kr := &Keyring{
+		active: active,
+		keys:   map[string]*Key{active.ID: active},
+	}
+	for _, key := range others {
+		if _, ok := kr.keys[key.ID]; ok {
+			return nil, fmt.Errorf("duplicate jwt key id %q", key.ID)
+		}
+		kr.keys[key.ID] = key
+	}
+
+	return kr, nil
+}
+
+// LoadKeyring собирает связку из настроек. specs — ключи через запятую
+// в виде kid:hs256:secret или kid:pem:/path/to/key.pem, активный
+// выбирается по activeID, иначе первый. Без specs используется secret.
+func LoadKeyring(secret, specs, activeID string) (*Keyring, error) {
+	if strings.TrimSpace(specs) == "" {
+		key, err := HMACKey(DefaultKeyID, secret)
+		if err != nil {
+			return nil, err
+		}
+		return NewKeyring(key)
+	}
+
+	var keys []*Key
+	for _, spec := range strings.Split(specs, ",") {
+		parts := strings.SplitN(strings.TrimSpace(spec), ":", 3)
+		if len(parts) != 3 {
+			return nil, fmt.Errorf("bad jwt key spec %q, want kid:type:value", spec)
+		}
+
+		var (
+			key *Key
+			err error
+		)
+		switch strings.ToLower(parts[1]) {
+		case "hs256":
+			key, err = HMACKey(parts[0], parts[2])
+		case "pem":
+			var data []byte
+			data, err = os.ReadFile(parts[2])
+			if err == nil {
+				key, err = PEMKey(parts[0], data)
+			}
+		default:
+			err = fmt.Errorf("unknown jwt key type %q", parts[1])
+		}
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+
+	active := 0
+	if activeID != "" {
+		active = -1
+		for i, key := range keys {
+			if key.ID == activeID {
+				active = i
+			}
+		}
+		if active < 0 {
+			return nil, fmt.Errorf("active jwt key %q is not configured", activeID)
+		}
+	}
+
+	others := append(append([]*Key{}, keys[:active]...), keys[active+1:]...)
+	return NewKeyring(keys[active], others...)
+}
+
+// Sign подписывает claims активным ключом и проставляет kid.
+func (kr *Keyring) Sign(claims *Claims) (string, error) {
+	token := jwt.NewWithClaims(kr.active.Method, claims)
+	token.Header["kid"] = kr.active.ID
+	return token.SignedString(kr.active.sign)
+}
+
+func (kr *Keyring) Parse(tokenStr string) (*Claims, error) {
+	claims := &Claims{}
+	token, err := jwt.ParseWithClaims(
+		tokenStr,
+		claims,
+		func(token *jwt.Token) (any, error) {
+			key := kr.active
+			// токены без kid выпущены до появления связки
+			if kid, ok := token.Header["kid"].(string); ok {
+				key = kr.keys[kid]
+			}
+			if key == nil {
+				return nil, errors.New("unknown key id")
+			}
+			if token.Method.Alg() != key.Method.Alg() {
+				return nil, fmt.Errorf("unexpected signing method %s", token.Method.Alg())
+			}
+			return key.verify, nil
+		},
+		jwt.WithValidMethods(kr.methods()),
+	)
+	if err != nil || !token.Valid {
+		return nil, errors.New("invalid token")
+	}
+	return claims, nil
+}
+
+// JWKS — публичные ключи связки. HMAC-ключи секретны и не публикуются.
+func (kr *Keyring) JWKS() JWKSet {
+	set := JWKSet{Keys: []JWK{}}
+	for _, key := range kr.keys {
+		jwk := JWK{
+			Kid: key.ID,
+			Alg: key.Method.Alg(),
+			Use: "sig",
+		}
+
+		switch pub := key.verify.(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+		default:
+			continue
+		}
+
+		set.Keys = append(set.Keys, jwk)
+	}
+
+	sort.Slice(set.Keys, func(i, j int) bool {
+		return set.Keys[i].Kid < set.Keys[j].Kid
+	})
+	return set
+}
+
+// JWKSHandler отдаёт публичные ключи для проверки токенов снаружи.
+func JWKSHandler(kr *Keyring) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		resp, err := json.Marshal(kr.JWKS())
+		if err != nil {
+			http.Error(c.Writer, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		c.Writer.Header().Set("content-type", "application/json")
+		c.Writer.WriteHeader(http.StatusOK)
+		c.Writer.Write(resp)
+	}
+}
+
+func (kr *Keyring) methods() []string {
+	var methods []string
+	seen := make(map[string]bool)
+	for _, key := range kr.keys {
+		if alg := key.Method.Alg(); !seen[alg] {
+			seen[alg] = true
+			methods = append(methods, alg)
+		}
+	}
+	return methods
+}
@@ -0,0 +1,164 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writePEM(t *testing.T, name, blockType string, der []byte) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), name)
+	data := pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der})
+	require.NoError(t, os.WriteFile(path, data, 0600))
+	return path
+}
+
+func signWith(t *testing.T, method jwt.SigningMethod, kid string, key any) string {
+	t.Helper()
+
+	token := jwt.NewWithClaims(method, &Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		},
+		UserID: "user",
+	})
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+
+	signed, err := token.SignedString(key)
+	require.NoError(t, err)
+	return signed
+}
+
+func TestKeyring(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	rsaPath := writePEM(t, "rsa.pem", "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(rsaKey))
+
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	edDER, err := x509.MarshalPKCS8PrivateKey(edKey)
+	require.NoError(t, err)
+	edPath := writePEM(t, "ed.pem", "PRIVATE KEY", edDER)
+
+	specs := "new:pem:" + rsaPath + ",old:hs256:old_secret,ed:pem:" + edPath
+	kr, err := LoadKeyring("", specs, "new")
+	require.NoError(t, err)
+
+	signed, err := kr.Sign(&Claims{UserID: "user"})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr bool
+	}{
+		{
+			name:  "Token signed by the active key",
+			token: signed,
+		},
+		{
+			name:  "Token signed by a rotated key",
+			token: signWith(t, jwt.SigningMethodHS256, "old", []byte("old_secret")),
+		},
+		{
+			name:  "Token signed by an EdDSA key",
+			token: signWith(t, jwt.SigningMethodEdDSA, "ed", edKey),
+		},
+		{
+			name:    "Unknown kid",
+			token:   signWith(t, jwt.SigningMethodHS256, "gone", []byte("old_secret")),
+			wantErr: true,
+		},
+		{
+			name:    "Algorithm does not match the key",
+			token:   signWith(t, jwt.SigningMethodHS256, "new", x509.MarshalPKCS1PublicKey(&rsaKey.PublicKey)),
+			wantErr: true,
+		},
+		{
+			name:    "Token without kid is checked by the active key",
+			token:   signWith(t, jwt.SigningMethodHS256, "", []byte("old_secret")),
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := kr.Parse(tt.token)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, "user", claims.UserID)
+		})
+	}
+
+	t.Run("JWKS publishes only public keys", func(t *testing.T) {
+		gin.SetMode(gin.TestMode)
+		router := gin.New()
+		router.GET("/jwks", JWKSHandler(kr))
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/jwks", nil))
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var set JWKSet
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &set))
+		require.Len(t, set.Keys, 2)
+
+		assert.Equal(t, "ed", set.Keys[0].Kid)
+		assert.Equal(t, "OKP", set.Keys[0].Kty)
+		assert.Equal(t, "EdDSA", set.Keys[0].Alg)
+		assert.Equal(t, "new", set.Keys[1].Kid)
+		assert.Equal(t, "RSA", set.Keys[1].Kty)
+		assert.Equal(t, "AQAB", set.Keys[1].E)
+	})
+}
+
+func TestLoadKeyring(t *testing.T) {
+	tests := []struct {
+		name     string
+		secret   string
+		specs    string
+		activeID string
+		wantErr  bool
+	}{
+		{name: "Secret only", secret: "secret_key"},
+		{name: "Empty secret", wantErr: true},
+		{name: "Empty secret in spec", specs: "a:hs256:", wantErr: true},
+		{name: "Bad spec", specs: "a-hs256", wantErr: true},
+		{name: "Unknown type", specs: "a:rot13:x", wantErr: true},
+		{name: "Missing active key", specs: "a:hs256:x", activeID: "b", wantErr: true},
+		{name: "Duplicate kid", specs: "a:hs256:x,a:hs256:y", wantErr: true},
+		{name: "Missing PEM file", specs: "a:pem:/nonexistent.pem", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := LoadKeyring(tt.secret, tt.specs, tt.activeID)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,245 @@
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+const (
+	RefreshCookieName = "refresh_token"
+	TokenTypeRefresh  = "refresh"
+
+	// за столько до истечения токен перевыпускается с тем же UserID
+	RefreshWindow = TokenExp / 3
+	// предельный срок сессии по умолчанию, дальше refresh не продлевает
+	DefaultSessionLifetime = 30 * 24 * time.Hour
+)
+
+var ErrSessionExpired = errors.New("session lifetime is over")
+
+type (
+	// session — пара токенов, выпущенная для пользователя
+	session struct {
+		access     string
+		refresh    string
+		accessExp  time.Time
+		refreshExp time.Time
+	}
+
+	RefreshRequest struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+
+	// Sessions выпускает токены вне middleware, например при входе
+	// по паролю.
+	Sessions struct {
+		kr *Keyring
+		o  options
+	}
+
+	TokenResponse struct {
+		AccessToken  string `json:"access_token"`
+		RefreshToken string `json:"refresh_token"`
+		TokenType    string `json:"token_type"`
+		ExpiresIn    int    `json:"expires_in"`
+	}
+)
+
+// WithSessionLifetime задаёт предельный срок сессии, отсчитываемый от
+// её начала. Ни скользящее продление, ни refresh его не превышают.
+func WithSessionLifetime(d time.Duration) Option {
+	return func(o *options) {
+		o.lifetime = d
+	}
+}
+
+// issueSession выпускает access- и refresh-токены для пользователя из
+// from, сессия продолжается с from.AuthTime.
+func issueSession(kr *Keyring, lifetime time.Duration, from *Claims) (*session, error) {
+	var (
+		now  = time.Now()
+		auth = authTime(from)
+		end  = auth.Add(lifetime)
+	)
+	if !now.Before(end) {
+		return nil, ErrSessionExpired
+	}
+
+	accessExp := now.Add(TokenExp)
+	if end.Before(accessExp) {
+		accessExp = end
+	}
+
+	access, err := kr.Sign(&Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(accessExp),
+		},
+		UserID:   from.UserID,
+		Role:     from.Role,
+		AuthTime: jwt.NewNumericDate(auth),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	refresh, err := kr.Sign(&Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(end),
+		},
+		UserID:   from.UserID,
+		Role:     from.Role,
+		Type:     TokenTypeRefresh,
+		AuthTime: jwt.NewNumericDate(auth),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &session{
+		access:     access,
+		refresh:    refresh,
+		accessExp:  accessExp,
+		refreshExp: end,
+	}, nil
+}
+
+func (s *session) response() TokenResponse {
+	return TokenResponse{
+		AccessToken:  s.access,
+		RefreshToken: s.refresh,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(time.Until(s.accessExp).Seconds()),
+	}
+}
+
+// setSession кладёт токены в cookie и отдаёт access-токен в заголовке.
+func setSession(c *gin.Context, s *session) {
+	c.SetCookie(
+		CookieName,
+		s.access,
+		int(time.Until(s.accessExp).Seconds()),
+		CookiePath,
+		CookieDomain,
+		false,
+		true,
+	)
+	c.SetCookie(
+		RefreshCookieName,
+		s.refresh,
+		int(time.Until(s.refreshExp).Seconds()),
+		CookiePath,
+		CookieDomain,
+		false,
+		true,
+	)
+	c.Header(HeaderName, BearerPrefix+s.access)
+}
+
+// parseRefresh проверяет refresh-токен. Access-токен вместо него не подходит.
+func parseRefresh(kr *Keyring, token string) (*Claims, error) {
+	claims, err := kr.Parse(token)
+	if err != nil {
+		return nil, err
+	}
+	if claims.Type != TokenTypeRefresh {
+		return nil, errors.New("not a refresh token")
+	}
+	return claims, nil
+}
+
+// authTime — начало сессии. У токенов, выпущенных до появления сессий,
+// его нет, и сессия считается начатой сейчас.
+func authTime(claims *Claims) time.Time {
+	if claims.AuthTime == nil {
+		return time.Now()
+	}
+	return claims.AuthTime.Time
+}
+
+// Refresh меняет refresh-токен из тела запроса или cookie на новую пару
+// токенов того же пользователя.
+func Refresh(kr *Keyring, opts ...Option) gin.HandlerFunc {
+	o := newOptions(opts)
+
+	return func(c *gin.Context) {
+		var req RefreshRequest
+		if c.Request.ContentLength != 0 {
+			if err := json.NewDecoder(c.Request.Body).Decode(&req); err != nil {
+				http.Error(c.Writer, "invalid request body", http.StatusBadRequest)
+				return
+			}
+		}
+		if req.RefreshToken == "" {
+			req.RefreshToken, _ = c.Cookie(RefreshCookieName)
+		}
+
+		claims, err := parseRefresh(kr, req.RefreshToken)
+		if err != nil {
+			http.Error(c.Writer, "invalid refresh token", http.StatusUnauthorized)
+			return
+		}
+
+		s, err := o.renew(kr, claims)
+		if errors.Is(err, ErrSessionExpired) {
+			http.Error(c.Writer, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		if err != nil {
+			http.Error(c.Writer, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		setSession(c, s)
+
+		resp, err := json.Marshal(s.response())
+		if err != nil {
+			http.Error(c.Writer, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		c.Writer.Header().Set("content-type", "application/json")
+		c.Writer.WriteHeader(http.StatusOK)
+		c.Writer.Write(resp)
+	}
+}
+
+func NewSessions(kr *Keyring, opts ...Option) *Sessions {
+	return &Sessions{kr: kr, o: newOptions(opts)}
+}
+
+// Start начинает новую сессию userID с ролью role и кладёт токены в ответ.
+func (s *Sessions) Start(c *gin.Context, userID, role string) (TokenResponse, error) {
+	sess, err := issueSession(s.kr, s.o.lifetime, &Claims{
+		UserID:   userID,
+		Role:     role,
+		AuthTime: jwt.NewNumericDate(time.Now()),
+	})
+	if err != nil {
+		return TokenResponse{}, err
+	}
+
+	setSession(c, sess)
+	return sess.response(), nil
+}
+
+// Current — пользователь, которому принадлежат токены запроса, если они
+// действительны. Новый пользователь при этом не создаётся.
+func (s *Sessions) Current(c *gin.Context) (string, bool) {
+	for _, token := range requestTokens(c) {
+		if claims, err := s.kr.Parse(token); err == nil && claims.Type == "" {
+			return claims.UserID, true
+		}
+	}
+
+	if refresh, err := c.Cookie(RefreshCookieName); err == nil {
+		if claims, err := parseRefresh(s.kr, refresh); err == nil {
+			return claims.UserID, true
+		}
+	}
+
+	return "", false
+}
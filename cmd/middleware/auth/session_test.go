@@ -0,0 +1,219 @@
+package auth
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func signClaims(t *testing.T, kr *Keyring, claims Claims) string {
+	t.Helper()
+
+	token, err := kr.Sign(&claims)
+	require.NoError(t, err)
+	return token
+}
+
+func TestSessionRefresh(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	kr, err := secretKeyring("secret_key")
+	require.NoError(t, err)
+	now := time.Now()
+
+	tests := []struct {
+		name          string
+		cookies       map[string]string
+		opts          []Option
+		expectedCode  int
+		expectedUser  string
+		expectRenewed bool
+	}{
+		{
+			name: "Fresh token is not renewed",
+			cookies: map[string]string{
+				CookieName: signClaims(t, kr, Claims{
+					RegisteredClaims: jwt.RegisteredClaims{ExpiresAt: jwt.NewNumericDate(now.Add(TokenExp))},
+					UserID:           "user",
+				}),
+			},
+			expectedCode: http.StatusOK,
+			expectedUser: "user",
+		},
+		{
+			name: "Token close to expiry is renewed for the same user",
+			cookies: map[string]string{
+				CookieName: signClaims(t, kr, Claims{
+					RegisteredClaims: jwt.RegisteredClaims{ExpiresAt: jwt.NewNumericDate(now.Add(time.Minute))},
+					UserID:           "user",
+				}),
+			},
+			expectedCode:  http.StatusOK,
+			expectedUser:  "user",
+			expectRenewed: true,
+		},
+		{
+			name: "Expired token is restored by the refresh token",
+			cookies: map[string]string{
+				CookieName: signClaims(t, kr, Claims{
+					RegisteredClaims: jwt.RegisteredClaims{ExpiresAt: jwt.NewNumericDate(now.Add(-time.Minute))},
+					UserID:           "user",
+				}),
+				RefreshCookieName: signClaims(t, kr, Claims{
+					RegisteredClaims: jwt.RegisteredClaims{ExpiresAt: jwt.NewNumericDate(now.Add(time.Hour))},
+					UserID:           "user",
+					Type:             TokenTypeRefresh,
+					AuthTime:         jwt.NewNumericDate(now.Add(-time.Hour)),
+				}),
+			},
+			expectedCode:  http.StatusOK,
+			expectedUser:  "user",
+			expectRenewed: true,
+		},
+		{
+			name: "Refresh token is not accepted as an access token",
+			cookies: map[string]string{
+				CookieName: signClaims(t, kr, Claims{
+					RegisteredClaims: jwt.RegisteredClaims{ExpiresAt: jwt.NewNumericDate(now.Add(time.Hour))},
+					UserID:           "user",
+					Type:             TokenTypeRefresh,
+				}),
+			},
+			opts:         []Option{RequireToken()},
+			expectedCode: http.StatusUnauthorized,
+		},
+		{
+			name: "Session past its lifetime is not restored",
+			cookies: map[string]string{
+				RefreshCookieName: signClaims(t, kr, Claims{
+					RegisteredClaims: jwt.RegisteredClaims{ExpiresAt: jwt.NewNumericDate(now.Add(time.Hour))},
+					UserID:           "user",
+					Type:             TokenTypeRefresh,
+					AuthTime:         jwt.NewNumericDate(now.Add(-2 * time.Hour)),
+				}),
+			},
+			opts:         []Option{RequireToken(), WithSessionLifetime(time.Hour)},
+			expectedCode: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := gin.New()
+			router.Use(Middleware(kr, tt.opts...))
+			router.GET("/", func(c *gin.Context) {
+				c.String(http.StatusOK, c.GetString("userID"))
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			for name, value := range tt.cookies {
+				req.AddCookie(&http.Cookie{Name: name, Value: value})
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			require.Equal(t, tt.expectedCode, w.Code)
+			if tt.expectedCode != http.StatusOK {
+				return
+			}
+			assert.Equal(t, tt.expectedUser, w.Body.String())
+
+			header := w.Header().Get(HeaderName)
+			if !tt.expectRenewed {
+				assert.Empty(t, header)
+				return
+			}
+
+			claims, err := kr.Parse(strings.TrimPrefix(header, BearerPrefix))
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedUser, claims.UserID)
+			assert.Greater(t, time.Until(claims.ExpiresAt.Time), RefreshWindow)
+		})
+	}
+}
+
+func TestRefresh(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	kr, err := secretKeyring("secret_key")
+	require.NoError(t, err)
+	now := time.Now()
+
+	refresh := signClaims(t, kr, Claims{
+		RegisteredClaims: jwt.RegisteredClaims{ExpiresAt: jwt.NewNumericDate(now.Add(time.Hour))},
+		UserID:           "user",
+		Type:             TokenTypeRefresh,
+		AuthTime:         jwt.NewNumericDate(now.Add(-time.Hour)),
+	})
+	access := signClaims(t, kr, Claims{
+		RegisteredClaims: jwt.RegisteredClaims{ExpiresAt: jwt.NewNumericDate(now.Add(time.Hour))},
+		UserID:           "user",
+	})
+
+	tests := []struct {
+		name         string
+		body         string
+		lifetime     time.Duration
+		expectedCode int
+	}{
+		{
+			name:         "Refresh token in body",
+			body:         `{"refresh_token":"` + refresh + `"}`,
+			lifetime:     DefaultSessionLifetime,
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:         "Access token is rejected",
+			body:         `{"refresh_token":"` + access + `"}`,
+			lifetime:     DefaultSessionLifetime,
+			expectedCode: http.StatusUnauthorized,
+		},
+		{
+			name:         "Session lifetime is over",
+			body:         `{"refresh_token":"` + refresh + `"}`,
+			lifetime:     time.Minute,
+			expectedCode: http.StatusUnauthorized,
+		},
+		{
+			name:         "Broken body",
+			body:         `{`,
+			lifetime:     DefaultSessionLifetime,
+			expectedCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := gin.New()
+			router.POST("/refresh", Refresh(kr, WithSessionLifetime(tt.lifetime)))
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/refresh", strings.NewReader(tt.body)))
+
+			require.Equal(t, tt.expectedCode, w.Code)
+			if tt.expectedCode != http.StatusOK {
+				return
+			}
+
+			var resp TokenResponse
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+
+			claims, err := kr.Parse(resp.AccessToken)
+			require.NoError(t, err)
+			assert.Equal(t, "user", claims.UserID)
+
+			// начало сессии переносится в новый refresh-токен
+			next, err := parseRefresh(kr, resp.RefreshToken)
+			require.NoError(t, err)
+			assert.Equal(t, now.Add(-time.Hour).Unix(), next.AuthTime.Unix())
+		})
+	}
+}
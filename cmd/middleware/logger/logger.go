@@ -27,6 +27,7 @@ func WithLogging() gin.HandlerFunc {
 			"status_code":    statusCode,
 			"content_length": contentLength,
 			"duration":       duration.String(),
+			"request_id":     c.GetString(RequestIDKey),
 		}).Info("HANDLE REQUEST")
 	}
 }
\ No newline at end of file
@@ -0,0 +1,33 @@
+package logger
+
+import (
+	"regexp"
+
+	"github.com/BazNick/shortlink/internal/app/functions"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	RequestIDHeader = "X-Request-ID"
+	// ключ контекста с идентификатором запроса
+	RequestIDKey = "requestID"
+)
+
+// пришедший от прокси идентификатор берём, только если он похож на
+// идентификатор, а не на произвольную строку
+var requestIDRe = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// RequestID выдаёт запросу идентификатор или берёт его из X-Request-ID и
+// возвращает клиенту в том же заголовке.
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(RequestIDHeader)
+		if !requestIDRe.MatchString(id) {
+			id = functions.RandSeq(16)
+		}
+
+		c.Set(RequestIDKey, id)
+		c.Writer.Header().Set(RequestIDHeader, id)
+		c.Next()
+	}
+}
@@ -0,0 +1,179 @@
+// Package ratelimit ограничивает частоту запросов маркерной корзиной
+// (token bucket): корзина вмещает Burst запросов и пополняется со
+// скоростью Rate в секунду.
+package ratelimit
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BazNick/shortlink/cmd/middleware/auth"
+	"github.com/BazNick/shortlink/internal/app/storage"
+	"github.com/gin-gonic/gin"
+)
+
+var ErrInvalidLimit = errors.New("rate limit must look like 60/m or 10/s:20")
+
+// Limit — Rate запросов в секунду с запасом Burst. Нулевой Limit ничего
+// не ограничивает.
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// ParseLimit разбирает лимит вида count/period[:burst], например 60/m,
+// 1000/1h или 10/s:20. По умолчанию burst равен count. Пустая строка —
+// без ограничений.
+func ParseLimit(s string) (Limit, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return Limit{}, nil
+	}
+
+	rate, burst, hasBurst := strings.Cut(s, ":")
+	count, period, ok := strings.Cut(rate, "/")
+	if !ok {
+		return Limit{}, ErrInvalidLimit
+	}
+
+	n, err := strconv.Atoi(count)
+	if err != nil || n <= 0 {
+		return Limit{}, ErrInvalidLimit
+	}
+
+	// 60/m то же, что 60/1m
+	if period != "" && (period[0] < '0' || period[0] > '9') {
+		period = "1" + period
+	}
+	per, err := time.ParseDuration(period)
+	if err != nil || per <= 0 {
+		return Limit{}, ErrInvalidLimit
+	}
+
+	limit := Limit{
+		Rate:  float64(n) / per.Seconds(),
+		Burst: n,
+	}
+	if hasBurst {
+		limit.Burst, err = strconv.Atoi(burst)
+		if err != nil || limit.Burst <= 0 {
+			return Limit{}, ErrInvalidLimit
+		}
+	}
+
+	return limit, nil
+}
+
+func (l Limit) Enabled() bool {
+	return l.Rate > 0 && l.Burst > 0
+}
+
+// Window — за сколько пустая корзина наполняется целиком.
+func (l Limit) Window() time.Duration {
+	if !l.Enabled() {
+		return 0
+	}
+	return l.after(float64(l.Burst))
+}
+
+// Result — итог одного запроса к ограничителю.
+type Result struct {
+	Allowed   bool
+	Remaining int
+	// через сколько корзина снова будет полной
+	Reset time.Duration
+	// через сколько появится следующий запрос, если этот отклонён
+	RetryAfter time.Duration
+}
+
+// Take пополняет корзину на прошедшее время и забирает из неё один запрос.
+func (l Limit) Take(b *storage.Bucket, now time.Time) Result {
+	burst := float64(l.Burst)
+
+	if b.UpdatedAt.IsZero() {
+		b.Tokens = burst
+	} else if elapsed := now.Sub(b.UpdatedAt); elapsed > 0 {
+		b.Tokens = math.Min(burst, b.Tokens+elapsed.Seconds()*l.Rate)
+	}
+	b.UpdatedAt = now
+
+	var res Result
+	if b.Tokens >= 1 {
+		b.Tokens--
+		res.Allowed = true
+	} else {
+		res.RetryAfter = l.after(1 - b.Tokens)
+	}
+
+	res.Remaining = int(b.Tokens)
+	res.Reset = l.after(burst - b.Tokens)
+
+	return res
+}
+
+func (l Limit) after(tokens float64) time.Duration {
+	return time.Duration(tokens / l.Rate * float64(time.Second))
+}
+
+// Middleware ограничивает запросы группы маршрутов name. Корзина своя у
+// каждого API-ключа, пользователя с токеном или адреса клиента, поэтому
+// ставится после auth. Если хранилище недоступно, запрос пропускается.
+func Middleware(store storage.BucketStore, name string, limit Limit) gin.HandlerFunc {
+	if !limit.Enabled() {
+		return func(c *gin.Context) {
+			c.Next()
+		}
+	}
+
+	policy := fmt.Sprintf("%d;w=%d", limit.Burst, seconds(limit.Window()))
+
+	return func(c *gin.Context) {
+		var res Result
+		err := store.UpdateBucket(name+":"+clientKey(c), func(b *storage.Bucket) {
+			res = limit.Take(b, time.Now())
+		})
+		if err != nil {
+			log.Printf("rate limit %s: %v", name, err)
+			c.Next()
+			return
+		}
+
+		header := c.Writer.Header()
+		header.Set("RateLimit-Policy", policy)
+		header.Set("RateLimit-Limit", strconv.Itoa(limit.Burst))
+		header.Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+		header.Set("RateLimit-Reset", strconv.Itoa(seconds(res.Reset)))
+
+		if !res.Allowed {
+			header.Set("Retry-After", strconv.Itoa(seconds(res.RetryAfter)))
+			http.Error(c.Writer, "too many requests", http.StatusTooManyRequests)
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// clientKey выбирает, чей это запрос. Только что созданный анонимный
+// пользователь ничего не значит — без cookie он новый на каждый запрос,
+// такие запросы считаются по адресу.
+func clientKey(c *gin.Context) string {
+	if key, ok := c.Get(auth.APIKeyKey); ok {
+		return "key:" + key.(storage.APIKey).ID
+	}
+	if user := c.GetString("userID"); user != "" && !c.GetBool(auth.NewUserKey) {
+		return "user:" + user
+	}
+	return "ip:" + c.ClientIP()
+}
+
+func seconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
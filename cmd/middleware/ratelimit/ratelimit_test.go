@@ -0,0 +1,128 @@
+package ratelimit
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/BazNick/shortlink/internal/app/entities"
+	"github.com/BazNick/shortlink/internal/app/storage"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type failingStore struct{}
+
+func (failingStore) UpdateBucket(key string, fn func(b *storage.Bucket)) error {
+	return errors.New("connection refused")
+}
+
+func (failingStore) GetBucket(key string) (storage.Bucket, error) {
+	return storage.Bucket{}, errors.New("connection refused")
+}
+
+func TestParseLimit(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Limit
+		wantErr bool
+	}{
+		{in: "", want: Limit{}},
+		{in: "60/m", want: Limit{Rate: 1, Burst: 60}},
+		{in: "10/s:20", want: Limit{Rate: 10, Burst: 20}},
+		{in: "3600/1h:5", want: Limit{Rate: 1, Burst: 5}},
+		{in: "30/2m", want: Limit{Rate: 0.25, Burst: 30}},
+		{in: "60", wantErr: true},
+		{in: "0/m", wantErr: true},
+		{in: "60/fortnight", wantErr: true},
+		{in: "60/m:0", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			limit, err := ParseLimit(tt.in)
+			if tt.wantErr {
+				require.ErrorIs(t, err, ErrInvalidLimit)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, limit)
+		})
+	}
+}
+
+func TestTake(t *testing.T) {
+	limit := Limit{Rate: 1, Burst: 2}
+	now := time.Now()
+
+	var b storage.Bucket
+	res := limit.Take(&b, now)
+	assert.True(t, res.Allowed)
+	assert.Equal(t, 1, res.Remaining)
+	assert.Equal(t, time.Second, res.Reset)
+
+	assert.True(t, limit.Take(&b, now).Allowed)
+
+	res = limit.Take(&b, now.Add(500*time.Millisecond))
+	assert.False(t, res.Allowed)
+	assert.Equal(t, 0, res.Remaining)
+	assert.Equal(t, 500*time.Millisecond, res.RetryAfter)
+
+	// за долгое время корзина наполняется не больше, чем на Burst
+	res = limit.Take(&b, now.Add(time.Hour))
+	assert.True(t, res.Allowed)
+	assert.Equal(t, 1, res.Remaining)
+}
+
+func TestMiddleware(t *testing.T) {
+	limit := Limit{Rate: 0.01, Burst: 2}
+
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		if user := c.GetHeader("X-User"); user != "" {
+			c.Set("userID", user)
+		}
+	})
+	router.GET("/", Middleware(entities.NewBuckets(time.Hour), "test", limit), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	router.GET("/down", Middleware(failingStore{}, "down", limit), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	do := func(target, ip, user string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(http.MethodGet, target, nil)
+		request.RemoteAddr = ip + ":1234"
+		if user != "" {
+			request.Header.Set("X-User", user)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, request)
+		return w
+	}
+
+	w := do("/", "10.0.0.1", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "2", w.Header().Get("RateLimit-Limit"))
+	assert.Equal(t, "1", w.Header().Get("RateLimit-Remaining"))
+	assert.Equal(t, "2;w=200", w.Header().Get("RateLimit-Policy"))
+
+	require.Equal(t, http.StatusOK, do("/", "10.0.0.1", "").Code)
+
+	w = do("/", "10.0.0.1", "")
+	require.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "0", w.Header().Get("RateLimit-Remaining"))
+	assert.Equal(t, "100", w.Header().Get("Retry-After"))
+
+	// у другого адреса и у пользователя с токеном свои корзины
+	assert.Equal(t, http.StatusOK, do("/", "10.0.0.2", "").Code)
+	assert.Equal(t, http.StatusOK, do("/", "10.0.0.1", "user1").Code)
+
+	// недоступное хранилище не должно ронять сервис
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, http.StatusOK, do("/down", "10.0.0.1", "").Code)
+	}
+}
@@ -1,62 +1,392 @@
 package main
 
 import (
+	"log"
 	"runtime"
+	"strings"
+	"time"
 	
 	"github.com/BazNick/shortlink/cmd/config"
 	"github.com/BazNick/shortlink/cmd/middleware/auth"
 	"github.com/BazNick/shortlink/cmd/middleware/compress"
 	"github.com/BazNick/shortlink/cmd/middleware/logger"
+	"github.com/BazNick/shortlink/cmd/middleware/ratelimit"
+	"github.com/BazNick/shortlink/internal/app/audit"
+	"github.com/BazNick/shortlink/internal/app/clicks"
 	"github.com/BazNick/shortlink/internal/app/entities"
 	"github.com/BazNick/shortlink/internal/app/handlers"
+	"github.com/BazNick/shortlink/internal/app/oidc"
+	"github.com/BazNick/shortlink/internal/app/quota"
+	"github.com/BazNick/shortlink/internal/app/safety"
+	"github.com/BazNick/shortlink/internal/app/search"
 	"github.com/BazNick/shortlink/internal/app/storage"
+	"github.com/BazNick/shortlink/internal/app/urlnorm"
 	"github.com/gin-gonic/gin"
 )
 
 func main() {
 	var (
-		conf    = config.GetCLParams()
-		router  = gin.Default()
-		storage storage.Storage
+		conf     = config.GetCLParams()
+		router   = gin.Default()
+		store    storage.Storage
+		apiKeys  storage.APIKeyStore
+		users    storage.UserStore
+		spaces   storage.WorkspaceStore
+		buckets  storage.BucketStore
+		audits   storage.AuditStore
+		reports  storage.ReportStore
+		versions storage.VersionStore
 	)
 
 	switch {
 	case conf.DB != "":
-		db := entities.NewDB(conf.DB)
-		storage = db
-
-		entities.StartDeleteWorkers(db.Database, runtime.NumCPU())
+		db, err := entities.NewDB(conf.DB)
+		if err != nil {
+			log.Fatal(err)
+		}
+		store = db
+		apiKeys = db
+		users = db
+		spaces = db
+		audits = db
+		reports = db
+		versions = db
+		if conf.RateLimitShared {
+			buckets = db
+		}
 
 		defer db.Database.Close()
 	case conf.FilePath != "":
 		file := entities.NewFileStore(conf.FilePath)
-		storage = file
+		store = file
 
 		defer file.FileStorage.Close()
+	case conf.SnapshotPath != "":
+		durable, err := entities.NewDurableHashDict(conf.SnapshotPath)
+		if err != nil {
+			log.Fatal(err)
+		}
+		store = durable
+
+		if conf.SnapshotInterval > 0 {
+			durable.StartSnapshots(conf.SnapshotInterval)
+		}
+
+		defer durable.Close()
 	default:
 		hashDict := entities.NewHashDict()
-		storage = hashDict
+		store = hashDict
+	}
+
+	if apiKeys == nil {
+		keys, err := entities.NewAPIKeys(sidecarPath(conf, "apikeys"))
+		if err != nil {
+			log.Fatal(err)
+		}
+		apiKeys = keys
+	}
+
+	if users == nil {
+		accounts, err := entities.NewUsers(sidecarPath(conf, "users"))
+		if err != nil {
+			log.Fatal(err)
+		}
+		users = accounts
+	}
+
+	if audits == nil {
+		entries, err := entities.NewAuditLog(sidecarPath(conf, "audit"))
+		if err != nil {
+			log.Fatal(err)
+		}
+		audits = entries
+	}
+	auditLog := audit.New(audits)
+
+	if reports == nil {
+		queue, err := entities.NewReports(sidecarPath(conf, "reports"))
+		if err != nil {
+			log.Fatal(err)
+		}
+		reports = queue
+	}
+
+	if versions == nil {
+		history, err := entities.NewVersions(sidecarPath(conf, "versions"))
+		if err != nil {
+			log.Fatal(err)
+		}
+		versions = history
+	}
+
+	if spaces == nil {
+		workspaces, err := entities.NewWorkspaces(sidecarPath(conf, "workspaces"))
+		if err != nil {
+			log.Fatal(err)
+		}
+		spaces = workspaces
+	}
+
+	var secondary storage.Storage
+
+	switch {
+	case conf.MirrorDB != "":
+		db, err := entities.NewDB(conf.MirrorDB)
+		if err != nil {
+			log.Fatal(err)
+		}
+		secondary = db
+
+		defer db.Database.Close()
+	case conf.MirrorFilePath != "":
+		secondary = entities.NewFileStore(conf.MirrorFilePath)
+	}
+
+	if secondary != nil {
+		mirror := storage.NewMirror(store, secondary, conf.MirrorFallback)
+		if conf.MirrorReconcileInterval > 0 {
+			mirror.StartReconcile(conf.MirrorReconcileInterval)
+		}
+		store = mirror
+	}
+
+	if conf.CacheSize > 0 {
+		store = storage.NewCache(store, storage.CacheConfig{
+			Size:        conf.CacheSize,
+			TTL:         conf.CacheTTL,
+			NegativeTTL: conf.CacheNegativeTTL,
+		})
+	}
+
+	// Postgres ищет сам, остальным хранилищам нужен индекс в памяти
+	if _, isDB := storage.As[*entities.DB](store); !isDB {
+		indexed, err := search.NewIndexed(store)
+		if err != nil {
+			log.Fatal(err)
+		}
+		store = indexed
+	}
+
+	dedup, err := storage.ParseDedup(conf.Dedup)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := storage.SetDedup(store, dedup); err != nil {
+		log.Fatal(err)
+	}
+
+	counter := clicks.New(store)
+	counter.Start(conf.ClickFlushInterval)
+
+	entities.StartDeleteWorkers(store, auditLog, runtime.NumCPU())
+
+	apiKeyHandler := handlers.NewAPIKeyHandler(apiKeys)
+
+	keyring, err := auth.LoadKeyring(conf.SecretKey, conf.JWTKeys, conf.JWTActiveKID)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	authOpts := []auth.Option{auth.WithAPIKeys(apiKeys), auth.WithUsers(users)}
+	if conf.SessionLifetime > 0 {
+		authOpts = append(authOpts, auth.WithSessionLifetime(conf.SessionLifetime))
+	}
+	if conf.AuthRequired {
+		authOpts = append(authOpts, auth.RequireToken())
+	}
+
+	var (
+		writeLimit    = parseLimit(conf.RateLimitWrite)
+		redirectLimit = parseLimit(conf.RateLimitRedirect)
+		apiLimit      = parseLimit(conf.RateLimitAPI)
+		authLimit     = parseLimit(conf.RateLimitAuth)
+	)
+
+	quotaLimits := quota.Limits{
+		MaxLinks:   conf.QuotaMaxLinks,
+		MaxBatch:   conf.QuotaMaxBatch,
+		DailyLinks: conf.QuotaDailyLinks,
+	}
+
+	// корзины лимитов и суточные счётчики квот хранятся вместе: запись,
+	// не тронутая дольше самого длинного окна, уже не нужна
+	idle := max(time.Minute, quotaLimits.Window())
+	for _, limit := range []ratelimit.Limit{writeLimit, redirectLimit, apiLimit, authLimit} {
+		idle = max(idle, limit.Window())
+	}
+	if db, ok := buckets.(*entities.DB); ok {
+		db.StartBucketPruning(10*time.Minute, idle)
+	}
+	if buckets == nil {
+		buckets = entities.NewBuckets(idle)
+	}
+
+	var (
+		limitWrites    = ratelimit.Middleware(buckets, "write", writeLimit)
+		limitRedirects = ratelimit.Middleware(buckets, "redirect", redirectLimit)
+		limitAPI       = ratelimit.Middleware(buckets, "api", apiLimit)
+		limitAuth      = ratelimit.Middleware(buckets, "auth", authLimit)
+	)
+
+	quotas := quota.New(store, buckets, quotaLimits)
+	urls := urlnorm.New(urlnorm.Options{
+		Schemes:          strings.Split(conf.URLSchemes, ","),
+		StripDefaultPort: conf.URLStripDefaultPort,
+		StripFragment:    conf.URLStripFragment,
+		SortQuery:        conf.URLSortQuery,
+	})
+	checker := safety.Chain{safety.NewSelfHosts(conf.BaseURL, conf.Address)}
+	if conf.SafetyBlocklist != "" {
+		blocklist, err := safety.LoadBlocklist(conf.SafetyBlocklist)
+		if err != nil {
+			log.Fatal(err)
+		}
+		blocklist.Watch(conf.SafetyReloadInterval)
+		checker = append(checker, blocklist)
+	}
+	if conf.SafetyLookupURL != "" {
+		checker = append(checker, safety.NewLookup(conf.SafetyLookupURL, nil, conf.SafetyLookupTTL))
 	}
 
 	urlHandler := handlers.NewURLHandler(
-		storage,
+		store,
 		conf.FilePath,
 		conf.DB,
+		handlers.WithQuota(quotas),
+		handlers.WithAudit(auditLog),
+		handlers.WithURLNormalizer(urls),
+		handlers.WithSafety(checker),
+		handlers.WithVersions(versions),
+		handlers.WithDedup(dedup),
+		handlers.WithClicks(counter),
+	)
+
+	var proxies []string
+	if conf.TrustedProxies != "" {
+		proxies = strings.Split(conf.TrustedProxies, ",")
+	}
+	if err := router.SetTrustedProxies(proxies); err != nil {
+		log.Fatal(err)
+	}
+
+	accountHandler := handlers.NewAccountHandler(
+		users,
+		store,
+		auth.NewSessions(keyring, authOpts...),
+		strings.Split(conf.AdminLogins, ","),
+		auditLog,
 	)
+	adminHandler := handlers.NewAdminHandler(store, users, auditLog)
+	reportHandler := handlers.NewReportHandler(store, reports, auditLog, conf.ReportThreshold)
+	workspaceHandler := handlers.NewWorkspaceHandler(spaces, store, users, quotas, auditLog, urls, checker)
+
+	router.Use(logger.RequestID())
+
+	// эти маршруты нужны и тем, у кого нет действующего токена,
+	// поэтому они регистрируются до middleware
+	router.GET("/.well-known/jwks.json", auth.JWKSHandler(keyring))
+	router.POST("/api/auth/refresh", limitAuth, auth.Refresh(keyring, authOpts...))
+	router.POST("/api/auth/register", limitAuth, accountHandler.Register)
+	router.POST("/api/auth/login", limitAuth, accountHandler.Login)
+
+	if conf.OIDCIssuer != "" {
+		redirectURL := conf.OIDCRedirectURL
+		if redirectURL == "" {
+			redirectURL = conf.BaseURL + "/api/auth/oidc/callback"
+		}
+
+		oidcHandler := handlers.NewOIDCHandler(
+			oidc.NewProvider(oidc.Config{
+				Issuer:       conf.OIDCIssuer,
+				ClientID:     conf.OIDCClientID,
+				ClientSecret: conf.OIDCClientSecret,
+				RedirectURL:  redirectURL,
+			}),
+			accountHandler,
+		)
+		router.GET("/api/auth/oidc/login", limitAuth, oidcHandler.Login)
+		router.GET("/api/auth/oidc/callback", limitAuth, oidcHandler.Callback)
+	}
 
 	router.Use(
 		logger.WithLogging(), 
 		compress.GzipHandle(),
-		auth.Auth(conf.SecretKey),
+		auth.Middleware(keyring, authOpts...),
+	)
+
+	var (
+		canRead   = auth.RequireScope(storage.ScopeRead)
+		canWrite  = auth.RequireScope(storage.ScopeWrite)
+		canDelete = auth.RequireScope(storage.ScopeDelete)
 	)
 
-	router.GET("/:id", urlHandler.GetLink)
-	router.POST("/", urlHandler.AddLink)
-	router.POST("/api/shorten", urlHandler.PostJSONLink)
+	router.GET("/:id", limitRedirects, urlHandler.GetLink)
+	router.POST("/", limitWrites, canWrite, urlHandler.AddLink)
+	router.POST("/:id/report", limitWrites, reportHandler.Report)
+	router.POST("/api/shorten", limitWrites, canWrite, urlHandler.PostJSONLink)
 	router.GET("/ping", urlHandler.DBPingConn)
-	router.POST("/api/shorten/batch", urlHandler.BatchLinks)
-	router.GET("/api/user/urls", urlHandler.GetUserLinks)
-	router.DELETE("/api/user/urls", urlHandler.DeleteUserLinks)
+	router.POST("/api/shorten/batch", limitWrites, canWrite, urlHandler.BatchLinks)
+
+	user := router.Group("/api/user", auth.Required(), limitAPI)
+	user.GET("/urls", canRead, urlHandler.GetUserLinks)
+	user.DELETE("/urls", canDelete, urlHandler.DeleteUserLinks)
+	user.GET("/urls/export", canRead, urlHandler.ExportUserLinks)
+	user.GET("/urls/search", canRead, urlHandler.SearchUserLinks)
+	user.PATCH("/urls/:id", canWrite, urlHandler.PatchUserLink)
+	user.GET("/urls/:id/versions", canRead, urlHandler.LinkVersions)
+	user.POST("/urls/:id/versions/:version/restore", canWrite, urlHandler.RestoreLinkVersion)
+	user.GET("/quota", canRead, urlHandler.GetUserQuota)
+	user.POST("/urls/import", limitWrites, canWrite, urlHandler.ImportUserLinks)
+
+	keys := user.Group("/keys", auth.SessionOnly())
+	keys.POST("", apiKeyHandler.CreateAPIKey)
+	keys.GET("", apiKeyHandler.ListAPIKeys)
+	keys.DELETE("/:id", apiKeyHandler.RevokeAPIKey)
+
+	workspaces := router.Group("/api/workspaces", auth.Required(), limitAPI)
+	workspaces.POST("", canWrite, workspaceHandler.CreateWorkspace)
+	workspaces.GET("", canRead, workspaceHandler.ListWorkspaces)
+	workspaces.GET("/:ws/members", canRead, workspaceHandler.ListMembers)
+	workspaces.POST("/:ws/members", canWrite, workspaceHandler.PutMember)
+	workspaces.DELETE("/:ws/members/:user", canWrite, workspaceHandler.RemoveMember)
+	workspaces.POST("/:ws/urls", limitWrites, canWrite, workspaceHandler.AddLink)
+	workspaces.GET("/:ws/urls", canRead, workspaceHandler.Links)
+	workspaces.DELETE("/:ws/urls", canDelete, workspaceHandler.DeleteLinks)
+
+	admin := router.Group("/api/admin", auth.RequireRole(storage.RoleAdmin, accountHandler.Role))
+	admin.GET("/links", adminHandler.SearchLinks)
+	admin.POST("/links/:id/disable", adminHandler.DisableLink)
+	admin.POST("/links/:id/enable", adminHandler.EnableLink)
+	admin.POST("/links/:id/owner", adminHandler.SetOwner)
+	admin.GET("/users", adminHandler.UserCounts)
+	admin.GET("/audit", adminHandler.SearchAudit)
+	admin.GET("/audit/export", adminHandler.ExportAudit)
+	admin.GET("/reports", reportHandler.ListReports)
+	admin.POST("/reports/:id", reportHandler.ResolveReport)
+	admin.GET("/internal/cache", urlHandler.CacheStats)
+	admin.GET("/internal/mirror", urlHandler.MirrorStats)
+	admin.POST("/internal/mirror/reconcile", urlHandler.ReconcileMirror)
 
 	router.Run(conf.Address)
 }
+
+// sidecarPath — файл для вспомогательных данных рядом с основным
+// хранилищем. Без файла и снимка всё живёт только в памяти.
+func sidecarPath(conf config.Config, name string) string {
+	switch {
+	case conf.FilePath != "":
+		return conf.FilePath + "." + name
+	case conf.SnapshotPath != "":
+		return conf.SnapshotPath + "." + name
+	default:
+		return ""
+	}
+}
+
+func parseLimit(s string) ratelimit.Limit {
+	limit, err := ratelimit.ParseLimit(s)
+	if err != nil {
+		log.Fatalf("%s: %v", s, err)
+	}
+	return limit
+}
@@ -3,10 +3,16 @@ package apperr
 import "errors"
 
 var (
-	ErrLinkExists       = errors.New("link already exists")
-	ErrLinkNotFound     = errors.New("link not found")
-	ErrBodyRead         = errors.New("cannot read the body")
-	ErrOnlyGET          = errors.New("only GET requests are allowed")
-	ErrOnlyPOST         = errors.New("only POST requests are allowed")
-	ErrValAlreadyExists = errors.New("conflict")
+	ErrLinkExists        = errors.New("link already exists")
+	ErrLinkNotFound      = errors.New("link not found")
+	ErrCodeTaken         = errors.New("short code is taken")
+	ErrBodyRead          = errors.New("cannot read the body")
+	ErrOnlyGET           = errors.New("only GET requests are allowed")
+	ErrOnlyPOST          = errors.New("only POST requests are allowed")
+	ErrValAlreadyExists  = errors.New("conflict")
+	ErrAPIKeyNotFound    = errors.New("api key not found")
+	ErrUserExists        = errors.New("user already exists")
+	ErrUserNotFound      = errors.New("user not found")
+	ErrWorkspaceNotFound = errors.New("workspace not found")
+	ErrMemberNotFound    = errors.New("workspace member not found")
 )
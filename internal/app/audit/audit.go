@@ -0,0 +1,73 @@
+// Package audit записывает в журнал, кто, когда и откуда менял ссылки.
+package audit
+
+import (
+	"log"
+	"time"
+
+	"github.com/BazNick/shortlink/cmd/middleware/logger"
+	"github.com/BazNick/shortlink/internal/app/functions"
+	"github.com/BazNick/shortlink/internal/app/storage"
+	"github.com/gin-gonic/gin"
+)
+
+// Log пишет записи в AuditStore. Ошибка записи не отменяет уже
+// сделанное действие, поэтому она только попадает в лог. С nil *Log
+// ничего не пишется.
+type Log struct {
+	store storage.AuditStore
+}
+
+func New(store storage.AuditStore) *Log {
+	return &Log{store: store}
+}
+
+// Source — автор запроса: пользователь, адрес и идентификатор запроса.
+func Source(c *gin.Context) storage.AuditSource {
+	return storage.AuditSource{
+		ActorID:   c.GetString("userID"),
+		IP:        c.ClientIP(),
+		RequestID: c.GetString(logger.RequestIDKey),
+	}
+}
+
+// Record записывает действие над ссылкой от имени автора запроса.
+func (l *Log) Record(c *gin.Context, action string, before, after *storage.Link) {
+	l.Add(Source(c), action, before, after, "")
+}
+
+// Add записывает действие от имени src, например из фоновых обработчиков,
+// где запроса уже нет.
+func (l *Log) Add(src storage.AuditSource, action string, before, after *storage.Link, note string) {
+	if l == nil {
+		return
+	}
+
+	entry := storage.AuditEntry{
+		ID:          functions.RandSeq(20),
+		Time:        time.Now(),
+		Action:      action,
+		AuditSource: src,
+		Before:      before,
+		After:       after,
+		Note:        note,
+	}
+	switch {
+	case after != nil:
+		entry.ShortURL = after.ShortURL
+	case before != nil:
+		entry.ShortURL = before.ShortURL
+	}
+
+	if err := l.store.AddAudit(entry); err != nil {
+		log.Printf("audit: %s %s failed: %v", action, entry.ShortURL, err)
+	}
+}
+
+// Entries читает журнал от новых записей к старым.
+func (l *Log) Entries(filter storage.AuditFilter, fn func(entry storage.AuditEntry) error) error {
+	if l == nil {
+		return nil
+	}
+	return l.store.AuditEntries(filter, fn)
+}
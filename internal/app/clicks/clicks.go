@@ -0,0 +1,92 @@
+// Package clicks считает переходы по ссылкам и запоминает время
+// последнего. Переход только обновляет счётчик в памяти, в хранилище
+// счётчики уходят пачкой раз в интервал, так что редирект не ждёт записи.
+// nil *Counter ничего не считает.
+package clicks
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/BazNick/shortlink/internal/app/storage"
+)
+
+// DefaultFlushInterval — как часто сбрасывать счётчики, если интервал не задан.
+const DefaultFlushInterval = 10 * time.Second
+
+type Counter struct {
+	store storage.Storage
+
+	mu      sync.Mutex
+	pending map[string]storage.Clicks
+	now     func() time.Time
+}
+
+func New(store storage.Storage) *Counter {
+	return &Counter{
+		store:   store,
+		pending: make(map[string]storage.Clicks),
+		now:     time.Now,
+	}
+}
+
+// Hit засчитывает переход по короткой ссылке hash.
+func (c *Counter) Hit(hash string) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	c.pending[hash] = c.pending[hash].Add(storage.Clicks{Count: 1, LastAt: c.now()})
+	c.mu.Unlock()
+}
+
+// Flush отдаёт накопленные переходы хранилищу. Если запись не удалась,
+// переходы возвращаются в счётчик до следующей попытки.
+func (c *Counter) Flush() error {
+	if c == nil {
+		return nil
+	}
+
+	c.mu.Lock()
+	pending := c.pending
+	c.pending = make(map[string]storage.Clicks)
+	c.mu.Unlock()
+
+	if len(pending) == 0 {
+		return nil
+	}
+
+	err := storage.AddClicks(c.store, pending)
+	if err != nil {
+		c.mu.Lock()
+		for hash, clicks := range pending {
+			c.pending[hash] = c.pending[hash].Add(clicks)
+		}
+		c.mu.Unlock()
+	}
+	return err
+}
+
+// Start раз в interval сбрасывает счётчики в фоне. Без интервала
+// переходы всё равно нужно сохранять, поэтому берётся DefaultFlushInterval.
+func (c *Counter) Start(interval time.Duration) {
+	if c == nil {
+		return
+	}
+	if interval <= 0 {
+		interval = DefaultFlushInterval
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			if err := c.Flush(); err != nil {
+				log.Printf("clicks: flush failed: %v", err)
+			}
+		}
+	}()
+}
@@ -0,0 +1,138 @@
+package clicks
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/BazNick/shortlink/internal/app/entities"
+	"github.com/BazNick/shortlink/internal/app/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type failingStore struct {
+	*entities.HashDict
+}
+
+func (failingStore) AddClicks(clicks map[string]storage.Clicks) error {
+	return errors.New("connection refused")
+}
+
+func TestCounter(t *testing.T) {
+	store := entities.NewHashDict()
+	store.AddHash("aaaaaaaa", "https://a.ru", "user")
+
+	first := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	now := first
+
+	counter := New(store)
+	counter.now = func() time.Time { return now }
+	counter.Hit("aaaaaaaa")
+	now = now.Add(time.Minute)
+	counter.Hit("aaaaaaaa")
+	counter.Hit("unknown")
+
+	link, err := store.GetLink("aaaaaaaa")
+	require.NoError(t, err)
+	assert.Zero(t, link.Clicks)
+	assert.Nil(t, link.LastAccessedAt)
+
+	require.NoError(t, counter.Flush())
+	link, err = store.GetLink("aaaaaaaa")
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), link.Clicks)
+	require.NotNil(t, link.LastAccessedAt)
+	assert.Equal(t, first.Add(time.Minute), *link.LastAccessedAt)
+
+	// сброшенные переходы второй раз не засчитываются
+	require.NoError(t, counter.Flush())
+	link, err = store.GetLink("aaaaaaaa")
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), link.Clicks)
+
+	// при ошибке переходы ждут следующего сброса
+	failing := New(failingStore{store})
+	failing.now = func() time.Time { return first.Add(time.Hour) }
+	failing.Hit("aaaaaaaa")
+	require.Error(t, failing.Flush())
+	failing.store = store
+	require.NoError(t, failing.Flush())
+	link, err = store.GetLink("aaaaaaaa")
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), link.Clicks)
+	assert.Equal(t, first.Add(time.Hour), *link.LastAccessedAt)
+
+	var none *Counter
+	none.Hit("aaaaaaaa")
+	assert.NoError(t, none.Flush())
+}
+
+func TestCounterStartWithoutInterval(t *testing.T) {
+	counter := New(entities.NewHashDict())
+	assert.NotPanics(t, func() { counter.Start(0) })
+
+	var none *Counter
+	assert.NotPanics(t, func() { none.Start(0) })
+}
+
+func TestCounterWithConcurrentEdits(t *testing.T) {
+	tests := []struct {
+		name  string
+		store func(t *testing.T) storage.Storage
+	}{
+		{
+			name:  "HashDict",
+			store: func(t *testing.T) storage.Storage { return entities.NewHashDict() },
+		},
+		{
+			name: "FileStore",
+			store: func(t *testing.T) storage.Storage {
+				return entities.NewFileStore(filepath.Join(t.TempDir(), "links.json"))
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := tt.store(t)
+			_, err := store.AddHash("aaaaaaaa", "https://a.ru", "user")
+			require.NoError(t, err)
+			counter := New(store)
+
+			var wg sync.WaitGroup
+			for i := 0; i < 4; i++ {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					for j := 0; j < 50; j++ {
+						counter.Hit("aaaaaaaa")
+						_, err := store.UpdateLink("aaaaaaaa", func(link *storage.Link) error {
+							link.Title = fmt.Sprintf("edited %d", i)
+							return nil
+						})
+						assert.NoError(t, err)
+						assert.NoError(t, counter.Flush())
+					}
+				}(i)
+			}
+			wg.Wait()
+
+			// правки ссылки не затирают сброшенные переходы, а сброс — правки
+			link, err := store.GetLink("aaaaaaaa")
+			require.NoError(t, err)
+			assert.Equal(t, int64(200), link.Clicks)
+			assert.Contains(t, link.Title, "edited")
+
+			require.NoError(t, store.DeleteLinks("user", []string{"aaaaaaaa"}))
+			counter.Hit("aaaaaaaa")
+			require.NoError(t, counter.Flush())
+			link, err = store.GetLink("aaaaaaaa")
+			require.NoError(t, err)
+			assert.True(t, link.IsDeleted)
+		})
+	}
+}
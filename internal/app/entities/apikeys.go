@@ -0,0 +1,105 @@
+package entities
+
+import (
+	"sync"
+	"time"
+
+	"github.com/BazNick/shortlink/internal/app/apperr"
+	"github.com/BazNick/shortlink/internal/app/storage"
+)
+
+// APIKeys — хранилище ключей в памяти, для файлового режима изменения
+// дописываются в отдельный файл.
+type APIKeys struct {
+	mu   sync.RWMutex
+	keys map[string]storage.APIKey
+	log  *recordLog[storage.APIKey]
+}
+
+func apiKeyKey(key storage.APIKey) (string, bool) {
+	return key.ID, true
+}
+
+func NewAPIKeys(path string) (*APIKeys, error) {
+	k := &APIKeys{
+		keys: make(map[string]storage.APIKey),
+		log:  newCompactingLog(path, apiKeyKey),
+	}
+
+	err := k.log.load(func(key storage.APIKey) {
+		k.keys[key.ID] = key
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return k, nil
+}
+
+func (k *APIKeys) AddAPIKey(key storage.APIKey) error {
+	return k.put(key)
+}
+
+func (k *APIKeys) APIKeyByHash(hash string) (storage.APIKey, error) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	for _, key := range k.keys {
+		if key.Hash == hash {
+			return key, nil
+		}
+	}
+	return storage.APIKey{}, apperr.ErrAPIKeyNotFound
+}
+
+func (k *APIKeys) UserAPIKeys(userID string) ([]storage.APIKey, error) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	var keys []storage.APIKey
+	for _, key := range k.keys {
+		if key.UserID == userID {
+			keys = append(keys, key)
+		}
+	}
+	return keys, nil
+}
+
+func (k *APIKeys) RevokeAPIKey(userID, id string) error {
+	k.mu.RLock()
+	key, ok := k.keys[id]
+	k.mu.RUnlock()
+
+	if !ok || key.UserID != userID || key.RevokedAt != nil {
+		return apperr.ErrAPIKeyNotFound
+	}
+
+	now := time.Now()
+	key.RevokedAt = &now
+	return k.put(key)
+}
+
+func (k *APIKeys) TouchAPIKey(id string, at time.Time) error {
+	k.mu.RLock()
+	key, ok := k.keys[id]
+	k.mu.RUnlock()
+
+	if !ok {
+		return apperr.ErrAPIKeyNotFound
+	}
+
+	key.LastUsedAt = &at
+	return k.put(key)
+}
+
+func (k *APIKeys) put(key storage.APIKey) error {
+	if err := k.log.append(key); err != nil {
+		return err
+	}
+
+	k.mu.Lock()
+	k.keys[key.ID] = key
+	k.mu.Unlock()
+
+	return nil
+}
@@ -0,0 +1,55 @@
+package entities
+
+import (
+	"sync"
+
+	"github.com/BazNick/shortlink/internal/app/storage"
+)
+
+// AuditLog — журнал аудита в памяти, в файловом режиме дописывается в
+// отдельный файл.
+type AuditLog struct {
+	mu      sync.RWMutex
+	entries []storage.AuditEntry
+	log     *recordLog[storage.AuditEntry]
+}
+
+func NewAuditLog(path string) (*AuditLog, error) {
+	a := &AuditLog{log: newRecordLog[storage.AuditEntry](path)}
+
+	err := a.log.load(func(entry storage.AuditEntry) {
+		a.entries = append(a.entries, entry)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return a, nil
+}
+
+func (a *AuditLog) AddAudit(entry storage.AuditEntry) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if err := a.log.append(entry); err != nil {
+		return err
+	}
+	a.entries = append(a.entries, entry)
+
+	return nil
+}
+
+func (a *AuditLog) AuditEntries(filter storage.AuditFilter, fn func(entry storage.AuditEntry) error) error {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	for i := len(a.entries) - 1; i >= 0; i-- {
+		if !filter.Match(a.entries[i]) {
+			continue
+		}
+		if err := fn(a.entries[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package entities
+
+import (
+	"sync"
+	"time"
+
+	"github.com/BazNick/shortlink/internal/app/storage"
+)
+
+// как часто Buckets ищет заброшенные ограничители
+const bucketSweepInterval = time.Minute
+
+// Buckets — ограничители частоты в памяти одной реплики. Ограничители,
+// которых не трогали дольше idle, выбрасываются: к этому времени они всё
+// равно снова полны.
+type Buckets struct {
+	mu        sync.Mutex
+	buckets   map[string]storage.Bucket
+	idle      time.Duration
+	lastSweep time.Time
+}
+
+func NewBuckets(idle time.Duration) *Buckets {
+	return &Buckets{
+		buckets:   make(map[string]storage.Bucket),
+		idle:      idle,
+		lastSweep: time.Now(),
+	}
+}
+
+func (b *Buckets) UpdateBucket(key string, fn func(b *storage.Bucket)) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(b.lastSweep) >= bucketSweepInterval {
+		for k, bucket := range b.buckets {
+			if now.Sub(bucket.UpdatedAt) > b.idle {
+				delete(b.buckets, k)
+			}
+		}
+		b.lastSweep = now
+	}
+
+	bucket := b.buckets[key]
+	fn(&bucket)
+	b.buckets[key] = bucket
+
+	return nil
+}
+
+func (b *Buckets) GetBucket(key string) (storage.Bucket, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.buckets[key], nil
+}
@@ -3,35 +3,43 @@ package entities
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"strings"
 	"time"
 
 	"github.com/BazNick/shortlink/internal/app/apperr"
+	"github.com/BazNick/shortlink/internal/app/storage"
+	"github.com/jackc/pgx/v5/pgtype"
 	_ "github.com/jackc/pgx/v5/stdlib"
 )
 
+const linkColumns = `short_url, original_url, user_id, is_deleted, is_disabled, workspace_id, expires_at,
+	title, description, tags, created_at, clicks, updated_at, last_accessed_at`
+
 type DB struct {
 	Database *sql.DB
+	// пустой — storage.DedupGlobal
+	Dedup storage.Dedup
 }
 
-func NewDB(connection string) *DB {
+// NewDB подключается к базе и доводит схему до текущей. Миграции идут
+// без таймаута пинга: добавление колонки может переписать всю таблицу.
+func NewDB(connection string) (*DB, error) {
 	db, err := sql.Open("pgx", connection)
 	if err != nil {
-		fmt.Println(err)
-		return nil
+		return nil, err
 	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
 	defer cancel()
 
 	if err = db.PingContext(ctx); err != nil {
-		fmt.Println(err)
-		return nil
+		db.Close()
+		return nil, err
 	}
 
-	_, err = db.ExecContext(
-		ctx,
+	statements := append([]string{
 		`CREATE TABLE IF NOT EXISTS links (
 			short_url varchar(15) NOT NULL,
 			original_url text NOT NULL UNIQUE,
@@ -39,22 +47,17 @@ func NewDB(connection string) *DB {
 			is_deleted BOOLEAN DEFAULT FALSE,
 			PRIMARY KEY (short_url)
 		)`,
-	)
-	if err != nil {
-		fmt.Println(err)
-		return nil
-	}
-
-	_, err = db.ExecContext(
-		ctx,
 		`CREATE INDEX IF NOT EXISTS idx_original_url ON links(original_url);`,
-	)
-	if err != nil {
-		fmt.Println(err)
-		return nil
+	}, schema...)
+
+	for _, stmt := range statements {
+		if _, err = db.ExecContext(context.Background(), stmt); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("migrate schema: %w", err)
+		}
 	}
 
-	return &DB{Database: db}
+	return &DB{Database: db}, nil
 }
 
 func (db *DB) AddHash(hash, link, userID string) (string, error) {
@@ -71,13 +74,8 @@ func (db *DB) AddHash(hash, link, userID string) (string, error) {
 	).Scan(&shortURL)
 
 	if err != nil {
-		if strings.Contains(err.Error(), "duplicate key") {
-			errQueryRow := db.Database.QueryRowContext(
-				context.Background(),
-				`SELECT short_url FROM links WHERE original_url = $1`,
-				link,
-			).Scan(&shortURL)
-
+		if strings.Contains(err.Error(), "duplicate key") && db.Dedup != storage.DedupOff {
+			shortURL, errQueryRow := db.FindShortURL(userID, link)
 			if errQueryRow != nil {
 				return "", fmt.Errorf("conflict, but failed to retrieve short_url: %w", errQueryRow)
 			}
@@ -92,15 +90,7 @@ func (db *DB) AddHash(hash, link, userID string) (string, error) {
 }
 
 func (db *DB) GetHash(hash string) string {
-	row := db.Database.QueryRowContext(
-		context.Background(),
-		`SELECT original_url FROM links WHERE short_url = $1 AND is_deleted = false;`,
-		hash,
-	)
-
-	var link string
-
-	err := row.Scan(&link)
+	link, err := db.LookupHash(hash)
 	if err != nil {
 		return ""
 	}
@@ -108,11 +98,19 @@ func (db *DB) GetHash(hash string) string {
 	return link
 }
 
-func (db *DB) CheckValExists(link string) bool {
+func (db *DB) CheckValExists(userID, link string) bool {
+	query, args := `SELECT original_url FROM links WHERE original_url = $1`, []any{link}
+	switch db.Dedup {
+	case storage.DedupOff:
+		return false
+	case storage.DedupUser:
+		query, args = query+` AND user_id = $2`, append(args, userID)
+	}
+
 	row := db.Database.QueryRowContext(
 		context.Background(),
-		`SELECT original_url FROM links WHERE original_url = $1`,
-		link,
+		query+` LIMIT 1`,
+		args...,
 	)
 
 	var exists sql.NullString
@@ -129,3 +127,339 @@ func (db *DB) CheckValExists(link string) bool {
 
 	return false
 }
+
+// SetDedup перестраивает уникальные индексы под режим: глобальный по
+// original_url, по паре (user_id, original_url) или никакого.
+func (db *DB) SetDedup(mode storage.Dedup) error {
+	stmts := []string{
+		`ALTER TABLE links DROP CONSTRAINT IF EXISTS links_original_url_key`,
+		`DROP INDEX IF EXISTS links_original_url_key`,
+		`DROP INDEX IF EXISTS idx_links_user_original_url`,
+	}
+	switch mode {
+	case storage.DedupGlobal:
+		stmts = append(stmts[2:], `CREATE UNIQUE INDEX IF NOT EXISTS links_original_url_key ON links(original_url)`)
+	case storage.DedupUser:
+		stmts = append(stmts, `CREATE UNIQUE INDEX IF NOT EXISTS idx_links_user_original_url ON links(user_id, original_url)`)
+	}
+
+	for _, stmt := range stmts {
+		if _, err := db.Database.ExecContext(context.Background(), stmt); err != nil {
+			return err
+		}
+	}
+
+	db.Dedup = mode
+	return nil
+}
+
+func (db *DB) DeleteLinks(userID string, hashes []string) error {
+	_, err := db.Database.ExecContext(
+		context.Background(),
+		`UPDATE links SET is_deleted = true, updated_at = now() WHERE user_id = $1 AND short_url = ANY($2);`,
+		userID,
+		hashes,
+	)
+	return err
+}
+
+func (db *DB) AddBatch(links []storage.Link) error {
+	tx, err := db.Database.Begin()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+
+	for _, link := range links {
+		if link.CreatedAt.IsZero() {
+			link.CreatedAt = now
+		}
+		if link.UpdatedAt.IsZero() {
+			link.UpdatedAt = link.CreatedAt
+		}
+
+		_, err := tx.ExecContext(
+			context.Background(),
+			`INSERT INTO links (`+linkColumns+`) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)`,
+			link.ShortURL,
+			link.OriginalURL,
+			link.UserID,
+			link.IsDeleted,
+			link.IsDisabled,
+			link.WorkspaceID,
+			link.ExpiresAt,
+			link.Title,
+			link.Description,
+			link.Tags,
+			link.CreatedAt,
+			link.Clicks,
+			link.UpdatedAt,
+			link.LastAccessedAt,
+		)
+		if err != nil {
+			tx.Rollback()
+			if strings.Contains(err.Error(), "duplicate key") {
+				return apperr.ErrValAlreadyExists
+			}
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// FindShortURL ищет уже сокращённую ссылку, с которой link считается
+// повтором в текущем режиме Dedup.
+func (db *DB) FindShortURL(userID, link string) (string, error) {
+	query, args := `SELECT short_url FROM links WHERE original_url = $1`, []any{link}
+	if db.Dedup == storage.DedupUser {
+		query, args = query+` AND user_id = $2`, append(args, userID)
+	}
+
+	var shortURL string
+	err := db.Database.QueryRowContext(
+		context.Background(),
+		query,
+		args...,
+	).Scan(&shortURL)
+	return shortURL, err
+}
+
+// InsertLink не трогает занятый код: ON CONFLICT касается только
+// short_url, повтор адреса по-прежнему даёт ErrValAlreadyExists.
+func (db *DB) InsertLink(link storage.Link) error {
+	if link.CreatedAt.IsZero() {
+		link.CreatedAt = time.Now()
+	}
+	if link.UpdatedAt.IsZero() {
+		link.UpdatedAt = link.CreatedAt
+	}
+
+	res, err := db.Database.ExecContext(
+		context.Background(),
+		`INSERT INTO links (`+linkColumns+`) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
+		 ON CONFLICT (short_url) DO NOTHING`,
+		link.ShortURL,
+		link.OriginalURL,
+		link.UserID,
+		link.IsDeleted,
+		link.IsDisabled,
+		link.WorkspaceID,
+		link.ExpiresAt,
+		link.Title,
+		link.Description,
+		link.Tags,
+		link.CreatedAt,
+		link.Clicks,
+		link.UpdatedAt,
+		link.LastAccessedAt,
+	)
+	if err != nil {
+		if strings.Contains(err.Error(), "duplicate key") {
+			return apperr.ErrValAlreadyExists
+		}
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return apperr.ErrCodeTaken
+	}
+	return nil
+}
+
+func (db *DB) LookupHash(hash string) (string, error) {
+	var link string
+
+	err := db.Database.QueryRowContext(
+		context.Background(),
+		`SELECT original_url FROM links WHERE short_url = $1 AND is_deleted = false AND is_disabled = false
+		 AND (expires_at IS NULL OR expires_at > now());`,
+		hash,
+	).Scan(&link)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+
+	return link, err
+}
+
+func (db *DB) Links(fn func(link storage.Link) error) error {
+	rows, err := db.Database.QueryContext(
+		context.Background(),
+		`SELECT `+linkColumns+` FROM links ORDER BY short_url`,
+	)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	types := pgtype.NewMap()
+	for rows.Next() {
+		rec, err := scanLink(types, rows)
+		if err != nil {
+			return err
+		}
+		if err := fn(rec); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+func (db *DB) GetLink(hash string) (storage.Link, error) {
+	rec, err := scanLink(pgtype.NewMap(), db.Database.QueryRowContext(
+		context.Background(),
+		`SELECT `+linkColumns+` FROM links WHERE short_url = $1`,
+		hash,
+	))
+	if errors.Is(err, sql.ErrNoRows) {
+		return storage.Link{}, apperr.ErrLinkNotFound
+	}
+
+	return rec, err
+}
+
+func (db *DB) UserLinks(userID string, fn func(link storage.Link) error) error {
+	rows, err := db.Database.QueryContext(
+		context.Background(),
+		`SELECT `+linkColumns+` FROM links WHERE user_id = $1`,
+		userID,
+	)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	types := pgtype.NewMap()
+	for rows.Next() {
+		rec, err := scanLink(types, rows)
+		if err != nil {
+			return err
+		}
+		if err := fn(rec); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+func (db *DB) WorkspaceLinks(workspaceID string, fn func(link storage.Link) error) error {
+	rows, err := db.Database.QueryContext(
+		context.Background(),
+		`SELECT `+linkColumns+` FROM links WHERE workspace_id = $1`,
+		workspaceID,
+	)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	types := pgtype.NewMap()
+	for rows.Next() {
+		rec, err := scanLink(types, rows)
+		if err != nil {
+			return err
+		}
+		if err := fn(rec); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+func (db *DB) ReassignLinks(from, to string) (int, error) {
+	res, err := db.Database.ExecContext(
+		context.Background(),
+		`UPDATE links SET user_id = $2, updated_at = now() WHERE user_id = $1;`,
+		from,
+		to,
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := res.RowsAffected()
+	return int(n), err
+}
+
+func (db *DB) UpdateLink(hash string, fn func(link *storage.Link) error) (storage.Link, error) {
+	tx, err := db.Database.Begin()
+	if err != nil {
+		return storage.Link{}, err
+	}
+	defer tx.Rollback()
+
+	link, err := scanLink(pgtype.NewMap(), tx.QueryRowContext(
+		context.Background(),
+		`SELECT `+linkColumns+` FROM links WHERE short_url = $1 FOR UPDATE`,
+		hash,
+	))
+	if errors.Is(err, sql.ErrNoRows) {
+		return storage.Link{}, apperr.ErrLinkNotFound
+	}
+	if err != nil {
+		return storage.Link{}, err
+	}
+
+	if err := fn(&link); err != nil {
+		return storage.Link{}, err
+	}
+	link.ShortURL = hash
+	link.UpdatedAt = time.Now()
+
+	_, err = tx.ExecContext(
+		context.Background(),
+		`UPDATE links SET original_url = $2, user_id = $3, is_deleted = $4, is_disabled = $5,
+		 workspace_id = $6, expires_at = $7, title = $8, description = $9, tags = $10, updated_at = $11
+		 WHERE short_url = $1`,
+		link.ShortURL,
+		link.OriginalURL,
+		link.UserID,
+		link.IsDeleted,
+		link.IsDisabled,
+		link.WorkspaceID,
+		link.ExpiresAt,
+		link.Title,
+		link.Description,
+		link.Tags,
+		link.UpdatedAt,
+	)
+	if err != nil {
+		if strings.Contains(err.Error(), "duplicate key") {
+			return storage.Link{}, apperr.ErrValAlreadyExists
+		}
+		return storage.Link{}, err
+	}
+
+	return link, tx.Commit()
+}
+
+// scanLink разбирает строку из linkColumns. types создаётся один раз на
+// запрос: на каждую строку он дорог, а делить его между горутинами нельзя.
+func scanLink(types *pgtype.Map, row interface{ Scan(dest ...any) error }) (storage.Link, error) {
+	var (
+		rec            storage.Link
+		expiresAt      sql.NullTime
+		lastAccessedAt sql.NullTime
+	)
+	err := row.Scan(
+		&rec.ShortURL, &rec.OriginalURL, &rec.UserID, &rec.IsDeleted, &rec.IsDisabled, &rec.WorkspaceID, &expiresAt,
+		&rec.Title, &rec.Description, types.SQLScanner(&rec.Tags), &rec.CreatedAt, &rec.Clicks,
+		&rec.UpdatedAt, &lastAccessedAt,
+	)
+	if expiresAt.Valid {
+		rec.ExpiresAt = &expiresAt.Time
+	}
+	if lastAccessedAt.Valid {
+		rec.LastAccessedAt = &lastAccessedAt.Time
+	}
+	return rec, err
+}
@@ -0,0 +1,129 @@
+package entities
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/BazNick/shortlink/internal/app/apperr"
+	"github.com/BazNick/shortlink/internal/app/storage"
+)
+
+const apiKeyColumns = `id, user_id, name, key_hash, scopes, created_at, last_used_at, revoked_at`
+
+func (db *DB) AddAPIKey(key storage.APIKey) error {
+	_, err := db.Database.ExecContext(
+		context.Background(),
+		`INSERT INTO api_keys (id, user_id, name, key_hash, scopes, created_at)
+		 VALUES ($1, $2, $3, $4, $5, $6)`,
+		key.ID,
+		key.UserID,
+		key.Name,
+		key.Hash,
+		strings.Join(key.Scopes, ","),
+		key.CreatedAt,
+	)
+	return err
+}
+
+func (db *DB) APIKeyByHash(hash string) (storage.APIKey, error) {
+	row := db.Database.QueryRowContext(
+		context.Background(),
+		`SELECT `+apiKeyColumns+` FROM api_keys WHERE key_hash = $1`,
+		hash,
+	)
+
+	key, err := scanAPIKey(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return storage.APIKey{}, apperr.ErrAPIKeyNotFound
+	}
+	return key, err
+}
+
+func (db *DB) UserAPIKeys(userID string) ([]storage.APIKey, error) {
+	rows, err := db.Database.QueryContext(
+		context.Background(),
+		`SELECT `+apiKeyColumns+` FROM api_keys WHERE user_id = $1 ORDER BY created_at`,
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []storage.APIKey
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+
+	return keys, rows.Err()
+}
+
+func (db *DB) RevokeAPIKey(userID, id string) error {
+	res, err := db.Database.ExecContext(
+		context.Background(),
+		`UPDATE api_keys SET revoked_at = now()
+		 WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL`,
+		id,
+		userID,
+	)
+	if err != nil {
+		return err
+	}
+
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return apperr.ErrAPIKeyNotFound
+	}
+	return nil
+}
+
+func (db *DB) TouchAPIKey(id string, at time.Time) error {
+	_, err := db.Database.ExecContext(
+		context.Background(),
+		`UPDATE api_keys SET last_used_at = $2 WHERE id = $1`,
+		id,
+		at,
+	)
+	return err
+}
+
+func scanAPIKey(row interface{ Scan(dest ...any) error }) (storage.APIKey, error) {
+	var (
+		key       storage.APIKey
+		scopes    string
+		lastUsed  sql.NullTime
+		revokedAt sql.NullTime
+	)
+
+	err := row.Scan(
+		&key.ID,
+		&key.UserID,
+		&key.Name,
+		&key.Hash,
+		&scopes,
+		&key.CreatedAt,
+		&lastUsed,
+		&revokedAt,
+	)
+	if err != nil {
+		return storage.APIKey{}, err
+	}
+
+	if scopes != "" {
+		key.Scopes = strings.Split(scopes, ",")
+	}
+	if lastUsed.Valid {
+		key.LastUsedAt = &lastUsed.Time
+	}
+	if revokedAt.Valid {
+		key.RevokedAt = &revokedAt.Time
+	}
+
+	return key, nil
+}
@@ -0,0 +1,132 @@
+package entities
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"github.com/BazNick/shortlink/internal/app/storage"
+)
+
+const auditColumns = `id, at, action, short_url, actor_id, ip, request_id, before, after, note`
+
+func (db *DB) AddAudit(entry storage.AuditEntry) error {
+	before, err := auditLinkJSON(entry.Before)
+	if err != nil {
+		return err
+	}
+	after, err := auditLinkJSON(entry.After)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Database.ExecContext(
+		context.Background(),
+		`INSERT INTO audit_log (`+auditColumns+`) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`,
+		entry.ID,
+		entry.Time,
+		entry.Action,
+		entry.ShortURL,
+		entry.ActorID,
+		entry.IP,
+		entry.RequestID,
+		before,
+		after,
+		entry.Note,
+	)
+	return err
+}
+
+func (db *DB) AuditEntries(filter storage.AuditFilter, fn func(entry storage.AuditEntry) error) error {
+	var (
+		conds []string
+		args  []any
+	)
+	where := func(cond string, arg any) {
+		args = append(args, arg)
+		conds = append(conds, strings.ReplaceAll(cond, "?", "$"+strconv.Itoa(len(args))))
+	}
+
+	if filter.ShortURL != "" {
+		where("short_url = ?", filter.ShortURL)
+	}
+	if filter.ActorID != "" {
+		where("actor_id = ?", filter.ActorID)
+	}
+	if filter.Action != "" {
+		where("action = ?", filter.Action)
+	}
+	if !filter.Since.IsZero() {
+		where("at >= ?", filter.Since)
+	}
+	if !filter.Until.IsZero() {
+		where("at < ?", filter.Until)
+	}
+
+	query := `SELECT ` + auditColumns + ` FROM audit_log`
+	if len(conds) > 0 {
+		query += ` WHERE ` + strings.Join(conds, " AND ")
+	}
+	query += ` ORDER BY at DESC, id DESC`
+
+	rows, err := db.Database.QueryContext(context.Background(), query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			entry         storage.AuditEntry
+			before, after []byte
+		)
+		err := rows.Scan(
+			&entry.ID,
+			&entry.Time,
+			&entry.Action,
+			&entry.ShortURL,
+			&entry.ActorID,
+			&entry.IP,
+			&entry.RequestID,
+			&before,
+			&after,
+			&entry.Note,
+		)
+		if err != nil {
+			return err
+		}
+
+		if entry.Before, err = auditLink(before); err != nil {
+			return err
+		}
+		if entry.After, err = auditLink(after); err != nil {
+			return err
+		}
+
+		if err := fn(entry); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+func auditLinkJSON(link *storage.Link) ([]byte, error) {
+	if link == nil {
+		return nil, nil
+	}
+	return json.Marshal(link)
+}
+
+func auditLink(data []byte) (*storage.Link, error) {
+	if data == nil {
+		return nil, nil
+	}
+
+	var link storage.Link
+	if err := json.Unmarshal(data, &link); err != nil {
+		return nil, err
+	}
+	return &link, nil
+}
@@ -0,0 +1,100 @@
+package entities
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/BazNick/shortlink/internal/app/storage"
+)
+
+// UpdateBucket держит строку ограничителя заблокированной, пока fn
+// пересчитывает её, поэтому все реплики видят один и тот же остаток.
+func (db *DB) UpdateBucket(key string, fn func(b *storage.Bucket)) error {
+	tx, err := db.Database.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(
+		context.Background(),
+		`INSERT INTO rate_limits (key, tokens) VALUES ($1, 0) ON CONFLICT (key) DO NOTHING`,
+		key,
+	)
+	if err != nil {
+		return err
+	}
+
+	var (
+		bucket    storage.Bucket
+		updatedAt sql.NullTime
+	)
+	err = tx.QueryRowContext(
+		context.Background(),
+		`SELECT tokens, updated_at FROM rate_limits WHERE key = $1 FOR UPDATE`,
+		key,
+	).Scan(&bucket.Tokens, &updatedAt)
+	if err != nil {
+		return err
+	}
+	bucket.UpdatedAt = updatedAt.Time
+
+	fn(&bucket)
+
+	_, err = tx.ExecContext(
+		context.Background(),
+		`UPDATE rate_limits SET tokens = $2, updated_at = $3 WHERE key = $1`,
+		key,
+		bucket.Tokens,
+		sql.NullTime{Time: bucket.UpdatedAt, Valid: !bucket.UpdatedAt.IsZero()},
+	)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (db *DB) GetBucket(key string) (storage.Bucket, error) {
+	var (
+		bucket    storage.Bucket
+		updatedAt sql.NullTime
+	)
+	err := db.Database.QueryRowContext(
+		context.Background(),
+		`SELECT tokens, updated_at FROM rate_limits WHERE key = $1`,
+		key,
+	).Scan(&bucket.Tokens, &updatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return storage.Bucket{}, nil
+	}
+	if err != nil {
+		return storage.Bucket{}, err
+	}
+	bucket.UpdatedAt = updatedAt.Time
+
+	return bucket, nil
+}
+
+// StartBucketPruning раз в interval удаляет ограничители, которых не
+// трогали дольше idle.
+func (db *DB) StartBucketPruning(interval, idle time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			_, err := db.Database.ExecContext(
+				context.Background(),
+				`DELETE FROM rate_limits WHERE updated_at IS NULL OR updated_at < $1`,
+				time.Now().Add(-idle),
+			)
+			if err != nil {
+				log.Printf("rate limits pruning failed: %v", err)
+			}
+		}
+	}()
+}
@@ -0,0 +1,183 @@
+package entities
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/BazNick/shortlink/internal/app/storage"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// ListLinks отдаёт страницу ссылок одним запросом: курсор сравнивается с
+// парой (ключ сортировки, short_url), по которой есть индекс.
+func (db *DB) ListLinks(q storage.LinkQuery) ([]storage.Link, error) {
+	var (
+		conds = []string{`user_id = $1`}
+		args  = []any{q.UserID}
+	)
+	arg := func(v any) string {
+		args = append(args, v)
+		return "$" + strconv.Itoa(len(args))
+	}
+
+	if q.Contains != "" {
+		conds = append(conds, `strpos(lower(original_url), lower(`+arg(q.Contains)+`)) > 0`)
+	}
+	if q.Tag != "" {
+		conds = append(conds, arg(q.Tag)+` = ANY(tags)`)
+	}
+
+	now := func() string {
+		if q.Now.IsZero() {
+			return `now()`
+		}
+		return arg(q.Now)
+	}
+
+	switch q.State {
+	case storage.StateActive:
+		conds = append(conds, `NOT is_deleted AND (expires_at IS NULL OR expires_at > `+now()+`)`)
+	case storage.StateDeleted:
+		conds = append(conds, `is_deleted`)
+	case storage.StateExpired:
+		conds = append(conds, `NOT is_deleted AND expires_at <= `+now())
+	}
+
+	key, dir, cmp := "created_at", "ASC", ">"
+	if q.Sort == storage.SortClicks {
+		key = "clicks"
+	}
+	if q.Desc {
+		dir, cmp = "DESC", "<"
+	}
+
+	if q.After != nil {
+		var after any = q.After.CreatedAt
+		if q.Sort == storage.SortClicks {
+			after = q.After.Clicks
+		}
+		conds = append(conds, `(`+key+`, short_url) `+cmp+` (`+arg(after)+`, `+arg(q.After.ShortURL)+`)`)
+	}
+
+	query := `SELECT ` + linkColumns + ` FROM links WHERE ` + strings.Join(conds, ` AND `) +
+		` ORDER BY ` + key + ` ` + dir + `, short_url ` + dir
+	if q.Limit > 0 {
+		query += ` LIMIT ` + arg(q.Limit)
+	}
+
+	rows, err := db.Database.QueryContext(context.Background(), query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var (
+		page  []storage.Link
+		types = pgtype.NewMap()
+	)
+	for rows.Next() {
+		rec, err := scanLink(types, rows)
+		if err != nil {
+			return nil, err
+		}
+		page = append(page, rec)
+	}
+
+	return page, rows.Err()
+}
+
+func (db *DB) AddClicks(clicks map[string]storage.Clicks) error {
+	var (
+		hashes = make([]string, 0, len(clicks))
+		counts = make([]int64, 0, len(clicks))
+		times  = make([]time.Time, 0, len(clicks))
+	)
+	for hash, c := range clicks {
+		hashes = append(hashes, hash)
+		counts = append(counts, c.Count)
+		times = append(times, c.LastAt)
+	}
+
+	// GREATEST пропускает NULL, так что первый переход просто запишется
+	_, err := db.Database.ExecContext(
+		context.Background(),
+		`UPDATE links SET clicks = links.clicks + c.n,
+		 last_accessed_at = GREATEST(links.last_accessed_at, c.at)
+		 FROM unnest($1::text[], $2::bigint[], $3::timestamptz[]) AS c(short_url, n, at)
+		 WHERE links.short_url = c.short_url`,
+		hashes,
+		counts,
+		times,
+	)
+	return err
+}
+
+// SearchLinks ищет по столбцу search: запрос разбивается на слова так же,
+// как адрес, и каждое слово ищется как начало слова. Достаточно одного
+// совпавшего слова, ссылки с большим числом совпадений ранжируются выше.
+//
+// Слова передаются параметром и состоят только из букв и цифр, поэтому
+// собранный из них tsquery не содержит операторов и кавычек.
+// plainto_tsquery не подходит: он не ищет по началу слова.
+func (db *DB) SearchLinks(q storage.SearchQuery) ([]storage.SearchResult, error) {
+	terms := searchTerms(q.Text)
+	if len(terms) == 0 {
+		return nil, nil
+	}
+
+	query := `WITH q AS (
+		SELECT to_tsquery('simple', string_agg(term || ':*', ' | ')) AS query
+		FROM unnest($2::text[]) AS term
+	)
+	SELECT ` + linkColumns + `, ts_rank(search, q.query) AS rank
+	FROM links, q
+	WHERE user_id = $1 AND NOT is_deleted AND search @@ q.query
+	ORDER BY rank DESC, short_url`
+	args := []any{q.UserID, terms}
+	if q.Limit > 0 {
+		query += ` LIMIT $3`
+		args = append(args, q.Limit)
+	}
+
+	rows, err := db.Database.QueryContext(context.Background(), query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var (
+		results []storage.SearchResult
+		types   = pgtype.NewMap()
+	)
+	for rows.Next() {
+		var rank float64
+		link, err := scanLink(types, rankedRow{rows, &rank})
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, storage.SearchResult{Link: link, Rank: rank})
+	}
+
+	return results, rows.Err()
+}
+
+// searchTerms — слова запроса из букв и цифр в нижнем регистре.
+func searchTerms(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// rankedRow дочитывает после столбцов ссылки ранг поиска.
+type rankedRow struct {
+	*sql.Rows
+	rank *float64
+}
+
+func (r rankedRow) Scan(dest ...any) error {
+	return r.Rows.Scan(append(dest, r.rank)...)
+}
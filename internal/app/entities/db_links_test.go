@@ -0,0 +1,25 @@
+package entities
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSearchTerms(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []string
+	}{
+		{in: `Jira OPS-7`, want: []string{"jira", "ops", "7"}},
+		{in: `C:\Users\it's "quoted"`, want: []string{"c", "users", "it", "s", "quoted"}},
+		{in: `a:* | b & !c`, want: []string{"a", "b", "c"}},
+		{in: `\\ '' ::`, want: []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			assert.Equal(t, tt.want, searchTerms(tt.in))
+		})
+	}
+}
@@ -0,0 +1,121 @@
+package entities
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BazNick/shortlink/internal/app/storage"
+)
+
+const reportColumns = `id, short_url, reason, reporter_id, ip, created_at, status, resolved_by, resolved_at`
+
+func (db *DB) AddReport(report storage.Report) error {
+	_, err := db.Database.ExecContext(
+		context.Background(),
+		`INSERT INTO reports (`+reportColumns+`) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`,
+		report.ID,
+		report.ShortURL,
+		report.Reason,
+		report.ReporterID,
+		report.IP,
+		report.CreatedAt,
+		report.Status,
+		report.ResolvedBy,
+		report.ResolvedAt,
+	)
+	return err
+}
+
+func (db *DB) Reports(filter storage.ReportFilter, fn func(report storage.Report) error) error {
+	where, args := reportWhere(filter)
+
+	rows, err := db.Database.QueryContext(
+		context.Background(),
+		`SELECT `+reportColumns+` FROM reports`+where+` ORDER BY created_at, id`,
+		args...,
+	)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			report     storage.Report
+			resolvedAt sql.NullTime
+		)
+		err := rows.Scan(
+			&report.ID,
+			&report.ShortURL,
+			&report.Reason,
+			&report.ReporterID,
+			&report.IP,
+			&report.CreatedAt,
+			&report.Status,
+			&report.ResolvedBy,
+			&resolvedAt,
+		)
+		if err != nil {
+			return err
+		}
+		if resolvedAt.Valid {
+			report.ResolvedAt = &resolvedAt.Time
+		}
+
+		if err := fn(report); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+func (db *DB) ResolveReports(filter storage.ReportFilter, status, by string, at time.Time) (int, error) {
+	filter.Status = storage.ReportOpen
+	where, args := reportWhere(filter)
+	args = append(args, status, by, at)
+	n := len(args)
+
+	res, err := db.Database.ExecContext(
+		context.Background(),
+		`UPDATE reports SET status = $`+strconv.Itoa(n-2)+
+			`, resolved_by = $`+strconv.Itoa(n-1)+
+			`, resolved_at = $`+strconv.Itoa(n)+where,
+		args...,
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	affected, err := res.RowsAffected()
+	return int(affected), err
+}
+
+func reportWhere(filter storage.ReportFilter) (string, []any) {
+	var (
+		conds []string
+		args  []any
+	)
+	where := func(cond string, arg any) {
+		args = append(args, arg)
+		conds = append(conds, strings.ReplaceAll(cond, "?", "$"+strconv.Itoa(len(args))))
+	}
+
+	if filter.ID != "" {
+		where("id = ?", filter.ID)
+	}
+	if filter.ShortURL != "" {
+		where("short_url = ?", filter.ShortURL)
+	}
+	if filter.Status != "" {
+		where("status = ?", filter.Status)
+	}
+
+	if len(conds) == 0 {
+		return "", nil
+	}
+	return ` WHERE ` + strings.Join(conds, " AND "), args
+}
@@ -0,0 +1,70 @@
+package entities
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+
+	"github.com/BazNick/shortlink/internal/app/apperr"
+	"github.com/BazNick/shortlink/internal/app/storage"
+)
+
+const userColumns = `id, login, password_hash, COALESCE(subject, ''), role, created_at`
+
+func (db *DB) AddUser(user storage.User) error {
+	_, err := db.Database.ExecContext(
+		context.Background(),
+		`INSERT INTO users (id, login, password_hash, subject, created_at)
+		 VALUES ($1, $2, $3, NULLIF($4, ''), $5)`,
+		user.ID,
+		user.Login,
+		user.PasswordHash,
+		user.Subject,
+		user.CreatedAt,
+	)
+	if err != nil && strings.Contains(err.Error(), "duplicate key") {
+		return apperr.ErrUserExists
+	}
+	return err
+}
+
+func (db *DB) UserByLogin(login string) (storage.User, error) {
+	return db.queryUser(`SELECT `+userColumns+` FROM users WHERE login = $1`, login)
+}
+
+func (db *DB) UserByID(id string) (storage.User, error) {
+	return db.queryUser(`SELECT `+userColumns+` FROM users WHERE id = $1`, id)
+}
+
+func (db *DB) UserBySubject(subject string) (storage.User, error) {
+	return db.queryUser(`SELECT `+userColumns+` FROM users WHERE subject = $1`, subject)
+}
+
+func (db *DB) SetUserRole(id, role string) error {
+	res, err := db.Database.ExecContext(
+		context.Background(),
+		`UPDATE users SET role = $2 WHERE id = $1`,
+		id,
+		role,
+	)
+	if err != nil {
+		return err
+	}
+
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return apperr.ErrUserNotFound
+	}
+	return nil
+}
+
+func (db *DB) queryUser(query string, arg string) (storage.User, error) {
+	var user storage.User
+
+	err := db.Database.QueryRowContext(context.Background(), query, arg).
+		Scan(&user.ID, &user.Login, &user.PasswordHash, &user.Subject, &user.Role, &user.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return storage.User{}, apperr.ErrUserNotFound
+	}
+	return user, err
+}
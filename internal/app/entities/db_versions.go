@@ -0,0 +1,75 @@
+package entities
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/BazNick/shortlink/internal/app/storage"
+)
+
+func (db *DB) AddVersion(version storage.LinkVersion) (storage.LinkVersion, error) {
+	link, err := json.Marshal(version.Link)
+	if err != nil {
+		return storage.LinkVersion{}, err
+	}
+
+	// номер выдаётся в том же запросе; если две правки одной ссылки всё же
+	// столкнутся, вторая упадёт на первичном ключе
+	err = db.Database.QueryRowContext(
+		context.Background(),
+		`INSERT INTO link_versions (short_url, version, link, changed_by, changed_at, restored_from)
+		 SELECT $1, COALESCE(MAX(version), 0) + 1, $2, $3, $4, $5
+		 FROM link_versions WHERE short_url = $1
+		 RETURNING version`,
+		version.ShortURL,
+		link,
+		version.ChangedBy,
+		version.ChangedAt,
+		version.RestoredFrom,
+	).Scan(&version.Version)
+	if err != nil {
+		return storage.LinkVersion{}, err
+	}
+
+	return version, nil
+}
+
+func (db *DB) Versions(hash string, fn func(version storage.LinkVersion) error) error {
+	rows, err := db.Database.QueryContext(
+		context.Background(),
+		`SELECT short_url, version, link, changed_by, changed_at, restored_from
+		 FROM link_versions WHERE short_url = $1 ORDER BY version`,
+		hash,
+	)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			version storage.LinkVersion
+			link    []byte
+		)
+		err := rows.Scan(
+			&version.ShortURL,
+			&version.Version,
+			&link,
+			&version.ChangedBy,
+			&version.ChangedAt,
+			&version.RestoredFrom,
+		)
+		if err != nil {
+			return err
+		}
+		if err := json.Unmarshal(link, &version.Link); err != nil {
+			return err
+		}
+
+		if err := fn(version); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
@@ -0,0 +1,146 @@
+package entities
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/BazNick/shortlink/internal/app/apperr"
+	"github.com/BazNick/shortlink/internal/app/storage"
+)
+
+const memberColumns = `workspace_id, user_id, role, added_at`
+
+func (db *DB) AddWorkspace(ws storage.Workspace, owner storage.Member) error {
+	tx, err := db.Database.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(
+		context.Background(),
+		`INSERT INTO workspaces (id, name, created_at) VALUES ($1, $2, $3)`,
+		ws.ID,
+		ws.Name,
+		ws.CreatedAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(
+		context.Background(),
+		`INSERT INTO workspace_members (`+memberColumns+`) VALUES ($1, $2, $3, $4)`,
+		ws.ID,
+		owner.UserID,
+		owner.Role,
+		owner.AddedAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (db *DB) Workspace(id string) (storage.Workspace, error) {
+	var ws storage.Workspace
+
+	err := db.Database.QueryRowContext(
+		context.Background(),
+		`SELECT id, name, created_at FROM workspaces WHERE id = $1`,
+		id,
+	).Scan(&ws.ID, &ws.Name, &ws.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return storage.Workspace{}, apperr.ErrWorkspaceNotFound
+	}
+	return ws, err
+}
+
+func (db *DB) UserWorkspaces(userID string) ([]storage.Member, error) {
+	return db.queryMembers(
+		`SELECT `+memberColumns+` FROM workspace_members WHERE user_id = $1 ORDER BY added_at`,
+		userID,
+	)
+}
+
+func (db *DB) Member(workspaceID, userID string) (storage.Member, error) {
+	var m storage.Member
+
+	err := db.Database.QueryRowContext(
+		context.Background(),
+		`SELECT `+memberColumns+` FROM workspace_members WHERE workspace_id = $1 AND user_id = $2`,
+		workspaceID,
+		userID,
+	).Scan(&m.WorkspaceID, &m.UserID, &m.Role, &m.AddedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return storage.Member{}, apperr.ErrMemberNotFound
+	}
+	return m, err
+}
+
+func (db *DB) Members(workspaceID string) ([]storage.Member, error) {
+	if _, err := db.Workspace(workspaceID); err != nil {
+		return nil, err
+	}
+
+	return db.queryMembers(
+		`SELECT `+memberColumns+` FROM workspace_members WHERE workspace_id = $1 ORDER BY added_at`,
+		workspaceID,
+	)
+}
+
+func (db *DB) PutMember(m storage.Member) error {
+	if _, err := db.Workspace(m.WorkspaceID); err != nil {
+		return err
+	}
+
+	// при смене роли время добавления остаётся прежним
+	_, err := db.Database.ExecContext(
+		context.Background(),
+		`INSERT INTO workspace_members (`+memberColumns+`) VALUES ($1, $2, $3, $4)
+		 ON CONFLICT (workspace_id, user_id) DO UPDATE SET role = EXCLUDED.role`,
+		m.WorkspaceID,
+		m.UserID,
+		m.Role,
+		m.AddedAt,
+	)
+	return err
+}
+
+func (db *DB) RemoveMember(workspaceID, userID string) error {
+	res, err := db.Database.ExecContext(
+		context.Background(),
+		`DELETE FROM workspace_members WHERE workspace_id = $1 AND user_id = $2`,
+		workspaceID,
+		userID,
+	)
+	if err != nil {
+		return err
+	}
+
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return apperr.ErrMemberNotFound
+	}
+	return nil
+}
+
+func (db *DB) queryMembers(query string, arg string) ([]storage.Member, error) {
+	rows, err := db.Database.QueryContext(context.Background(), query, arg)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []storage.Member
+	for rows.Next() {
+		var m storage.Member
+		if err := rows.Scan(&m.WorkspaceID, &m.UserID, &m.Role, &m.AddedAt); err != nil {
+			return nil, err
+		}
+		result = append(result, m)
+	}
+
+	return result, rows.Err()
+}
@@ -0,0 +1,377 @@
+package entities
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/BazNick/shortlink/internal/app/apperr"
+	"github.com/BazNick/shortlink/internal/app/storage"
+)
+
+const (
+	walOpAdd      = "add"
+	walOpDelete   = "delete"
+	walOpReassign = "reassign"
+	// walOpClicks хранит итоговые счётчики, а не прибавку, чтобы запись,
+	// уже попавшая в снимок, при повторном проигрывании ничего не меняла
+	walOpClicks = "click_totals"
+	// прибавки из журналов старых версий
+	walOpClicksAdd = "clicks"
+)
+
+type (
+	// DurableHashDict — HashDict, который переживает перезапуск: изменения
+	// сначала пишутся в журнал (WAL), а словарь периодически целиком
+	// сохраняется в сжатый снимок, после чего журнал обнуляется.
+	DurableHashDict struct {
+		mu   sync.RWMutex
+		dict *HashDict
+
+		snapshotPath string
+		walPath      string
+		wal          *os.File
+	}
+
+	walRecord struct {
+		Op     string                    `json:"op"`
+		Links  []storage.Link            `json:"links,omitempty"`
+		UserID string                    `json:"user_id,omitempty"`
+		Hashes []string                  `json:"hashes,omitempty"`
+		To     string                    `json:"to,omitempty"`
+		Clicks map[string]storage.Clicks `json:"clicks,omitempty"`
+		// At — время удаления или передачи ссылок
+		At time.Time `json:"at,omitempty"`
+	}
+)
+
+// NewDurableHashDict поднимает словарь из снимка snapshotPath и
+// проигрывает поверх него журнал snapshotPath + ".wal".
+func NewDurableHashDict(snapshotPath string) (*DurableHashDict, error) {
+	d := &DurableHashDict{
+		dict:         NewHashDict(),
+		snapshotPath: snapshotPath,
+		walPath:      snapshotPath + ".wal",
+	}
+
+	if err := d.loadSnapshot(); err != nil {
+		return nil, err
+	}
+
+	if err := d.replayWAL(); err != nil {
+		return nil, err
+	}
+
+	wal, err := os.OpenFile(d.walPath, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0666)
+	if err != nil {
+		return nil, err
+	}
+	d.wal = wal
+
+	return d, nil
+}
+
+func (d *DurableHashDict) AddHash(hash, link, userID string) (string, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	// время создания пишется в журнал, чтобы не сдвинуться при проигрывании
+	now := time.Now()
+	links := []storage.Link{{ShortURL: hash, OriginalURL: link, UserID: userID, CreatedAt: now, UpdatedAt: now}}
+	if err := d.writeWAL(walRecord{Op: walOpAdd, Links: links}); err != nil {
+		return "", err
+	}
+
+	return "", d.dict.AddBatch(links)
+}
+
+func (d *DurableHashDict) AddBatch(links []storage.Link) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if err := d.writeWAL(walRecord{Op: walOpAdd, Links: links}); err != nil {
+		return err
+	}
+
+	return d.dict.AddBatch(links)
+}
+
+func (d *DurableHashDict) InsertLink(link storage.Link) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if _, err := d.dict.GetLink(link.ShortURL); err == nil {
+		return apperr.ErrCodeTaken
+	}
+
+	if link.CreatedAt.IsZero() {
+		link.CreatedAt = time.Now()
+	}
+	links := []storage.Link{link}
+	if err := d.writeWAL(walRecord{Op: walOpAdd, Links: links}); err != nil {
+		return err
+	}
+
+	return d.dict.AddBatch(links)
+}
+
+func (d *DurableHashDict) GetHash(hash string) string {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	return d.dict.GetHash(hash)
+}
+
+func (d *DurableHashDict) GetLink(hash string) (storage.Link, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	return d.dict.GetLink(hash)
+}
+
+func (d *DurableHashDict) CheckValExists(userID, link string) bool {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	return d.dict.CheckValExists(userID, link)
+}
+
+func (d *DurableHashDict) SetDedup(mode storage.Dedup) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return d.dict.SetDedup(mode)
+}
+
+func (d *DurableHashDict) DeleteLinks(userID string, hashes []string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	rec := walRecord{
+		Op:     walOpDelete,
+		UserID: userID,
+		Hashes: hashes,
+		At:     time.Now(),
+	}
+	if err := d.writeWAL(rec); err != nil {
+		return err
+	}
+
+	d.dict.deleteLinks(rec.UserID, rec.Hashes, rec.At)
+	return nil
+}
+
+func (d *DurableHashDict) Links(fn func(link storage.Link) error) error {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	return d.dict.Links(fn)
+}
+
+func (d *DurableHashDict) UserLinks(userID string, fn func(link storage.Link) error) error {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	return d.dict.UserLinks(userID, fn)
+}
+
+func (d *DurableHashDict) WorkspaceLinks(workspaceID string, fn func(link storage.Link) error) error {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	return d.dict.WorkspaceLinks(workspaceID, fn)
+}
+
+func (d *DurableHashDict) AddClicks(clicks map[string]storage.Clicks) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	totals := make(map[string]storage.Clicks, len(clicks))
+	for hash, c := range clicks {
+		link, err := d.dict.GetLink(hash)
+		if err != nil {
+			continue
+		}
+		c.Apply(&link)
+		totals[hash] = storage.Clicks{Count: link.Clicks, LastAt: *link.LastAccessedAt}
+	}
+	if len(totals) == 0 {
+		return nil
+	}
+
+	if err := d.writeWAL(walRecord{Op: walOpClicks, Clicks: totals}); err != nil {
+		return err
+	}
+
+	d.dict.setClicks(totals)
+	return nil
+}
+
+func (d *DurableHashDict) ReassignLinks(from, to string) (int, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	rec := walRecord{
+		Op:     walOpReassign,
+		UserID: from,
+		To:     to,
+		At:     time.Now(),
+	}
+	if err := d.writeWAL(rec); err != nil {
+		return 0, err
+	}
+
+	return d.dict.reassignLinks(from, to, rec.At), nil
+}
+
+func (d *DurableHashDict) UpdateLink(hash string, fn func(link *storage.Link) error) (storage.Link, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	link, err := d.dict.GetLink(hash)
+	if err != nil {
+		return storage.Link{}, err
+	}
+
+	clicks, lastAccessedAt := link.Clicks, link.LastAccessedAt
+	if err := fn(&link); err != nil {
+		return storage.Link{}, err
+	}
+	link.ShortURL = hash
+	link.UpdatedAt = time.Now()
+	// переходы ведёт только AddClicks
+	link.Clicks, link.LastAccessedAt = clicks, lastAccessedAt
+
+	// в журнал пишется запись целиком, при проигрывании она заменит старую
+	if err := d.writeWAL(walRecord{Op: walOpAdd, Links: []storage.Link{link}}); err != nil {
+		return storage.Link{}, err
+	}
+
+	return link, d.dict.AddBatch([]storage.Link{link})
+}
+
+// Snapshot сохраняет словарь в снимок и очищает журнал.
+func (d *DurableHashDict) Snapshot() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	tmpPath := d.snapshotPath + ".tmp"
+	file, err := os.Create(tmpPath)
+	if err != nil {
+		return err
+	}
+
+	if err := d.dict.Dump(file); err != nil {
+		file.Close()
+		return err
+	}
+
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+
+	if err := file.Close(); err != nil {
+		return err
+	}
+
+	// снимок подменяется атомарно, журнал чистится только после этого
+	if err := os.Rename(tmpPath, d.snapshotPath); err != nil {
+		return err
+	}
+
+	return d.wal.Truncate(0)
+}
+
+// StartSnapshots раз в interval делает снимок в фоне.
+func (d *DurableHashDict) StartSnapshots(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			if err := d.Snapshot(); err != nil {
+				log.Printf("snapshot failed: %v", err)
+			}
+		}
+	}()
+}
+
+func (d *DurableHashDict) Close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return d.wal.Close()
+}
+
+func (d *DurableHashDict) writeWAL(rec walRecord) error {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+
+	if _, err := d.wal.Write(append(data, '\n')); err != nil {
+		return err
+	}
+
+	return d.wal.Sync()
+}
+
+func (d *DurableHashDict) loadSnapshot() error {
+	file, err := os.Open(d.snapshotPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	return d.dict.Load(file)
+}
+
+func (d *DurableHashDict) replayWAL() error {
+	file, err := os.OpenFile(d.walPath, os.O_RDWR|os.O_CREATE, 0666)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	var (
+		dec  = json.NewDecoder(bufio.NewReader(file))
+		good int64
+	)
+
+	for {
+		var rec walRecord
+		err := dec.Decode(&rec)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			// хвост журнала мог не дописаться при падении — отрезаем его,
+			// чтобы новые записи не легли после мусора
+			log.Printf("wal %s is corrupted after offset %d, truncating: %v", d.walPath, good, err)
+			return file.Truncate(good)
+		}
+
+		switch rec.Op {
+		case walOpAdd:
+			d.dict.AddBatch(rec.Links)
+		case walOpDelete:
+			d.dict.deleteLinks(rec.UserID, rec.Hashes, rec.At)
+		case walOpReassign:
+			d.dict.reassignLinks(rec.UserID, rec.To, rec.At)
+		case walOpClicks:
+			d.dict.setClicks(rec.Clicks)
+		case walOpClicksAdd:
+			d.dict.AddClicks(rec.Clicks)
+		}
+		good = dec.InputOffset()
+	}
+}
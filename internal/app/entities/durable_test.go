@@ -0,0 +1,128 @@
+package entities
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/BazNick/shortlink/internal/app/storage"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDurableHashDict(t *testing.T) {
+	tests := []struct {
+		name    string
+		prepare func(t *testing.T, d *DurableHashDict, path string)
+	}{
+		{
+			name:    "Changes are restored from the log",
+			prepare: func(t *testing.T, d *DurableHashDict, path string) {},
+		},
+		{
+			name: "Changes are restored from the snapshot",
+			prepare: func(t *testing.T, d *DurableHashDict, path string) {
+				require.NoError(t, d.Snapshot())
+
+				info, err := os.Stat(path + ".wal")
+				require.NoError(t, err)
+				require.Zero(t, info.Size())
+			},
+		},
+		{
+			name: "Torn tail of the log is dropped",
+			prepare: func(t *testing.T, d *DurableHashDict, path string) {
+				wal, err := os.OpenFile(path+".wal", os.O_WRONLY|os.O_APPEND, 0666)
+				require.NoError(t, err)
+				defer wal.Close()
+
+				_, err = wal.WriteString(`{"op":"add","links":[{"short_url":"broken`)
+				require.NoError(t, err)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "snapshot.gz")
+
+			d, err := NewDurableHashDict(path)
+			require.NoError(t, err)
+
+			_, err = d.AddHash("aaaaaaaa", "https://a.ru", "user")
+			require.NoError(t, err)
+			_, err = d.AddHash("bbbbbbbb", "https://b.ru", "user")
+			require.NoError(t, err)
+			require.NoError(t, d.DeleteLinks("user", []string{"bbbbbbbb"}))
+			n, err := d.ReassignLinks("user", "owner")
+			require.NoError(t, err)
+			require.Equal(t, 2, n)
+			require.NoError(t, d.AddClicks(map[string]storage.Clicks{
+				"aaaaaaaa": {Count: 1, LastAt: time.Now()},
+			}))
+			want, err := d.GetLink("aaaaaaaa")
+			require.NoError(t, err)
+			require.False(t, want.UpdatedAt.Before(want.CreatedAt))
+			require.NotNil(t, want.LastAccessedAt)
+
+			tt.prepare(t, d, path)
+			require.NoError(t, d.Close())
+
+			restored, err := NewDurableHashDict(path)
+			require.NoError(t, err)
+			defer restored.Close()
+
+			require.Equal(t, "https://a.ru", restored.GetHash("aaaaaaaa"))
+			require.Equal(t, "", restored.GetHash("bbbbbbbb"))
+			require.True(t, restored.CheckValExists("user", "https://b.ru"))
+
+			link, err := restored.GetLink("aaaaaaaa")
+			require.NoError(t, err)
+			require.Equal(t, "owner", link.UserID)
+			// время не сдвигается при проигрывании журнала
+			require.True(t, want.CreatedAt.Equal(link.CreatedAt))
+			require.True(t, want.UpdatedAt.Equal(link.UpdatedAt))
+			require.True(t, want.LastAccessedAt.Equal(*link.LastAccessedAt))
+			require.Equal(t, int64(1), link.Clicks)
+
+			_, err = restored.AddHash("cccccccc", "https://c.ru", "user")
+			require.NoError(t, err)
+			require.NoError(t, restored.Close())
+
+			again, err := NewDurableHashDict(path)
+			require.NoError(t, err)
+			defer again.Close()
+			require.Equal(t, "https://c.ru", again.GetHash("cccccccc"))
+		})
+	}
+}
+
+func TestDurableHashDictClicksReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "snapshot.gz")
+
+	d, err := NewDurableHashDict(path)
+	require.NoError(t, err)
+	_, err = d.AddHash("aaaaaaaa", "https://a.ru", "user")
+	require.NoError(t, err)
+	for i := 0; i < 2; i++ {
+		require.NoError(t, d.AddClicks(map[string]storage.Clicks{
+			"aaaaaaaa": {Count: 2, LastAt: time.Now()},
+		}))
+	}
+
+	// падение после подмены снимка, но до очистки журнала: записи журнала
+	// проигрываются поверх снимка, в который уже вошли
+	wal, err := os.ReadFile(path + ".wal")
+	require.NoError(t, err)
+	require.NoError(t, d.Snapshot())
+	require.NoError(t, d.Close())
+	require.NoError(t, os.WriteFile(path+".wal", wal, 0666))
+
+	restored, err := NewDurableHashDict(path)
+	require.NoError(t, err)
+	defer restored.Close()
+
+	link, err := restored.GetLink("aaaaaaaa")
+	require.NoError(t, err)
+	require.Equal(t, int64(4), link.Clicks)
+}
@@ -6,16 +6,28 @@ import (
 	"io"
 	"log"
 	"os"
+	"sync"
+	"time"
+
+	"github.com/BazNick/shortlink/internal/app/apperr"
+	"github.com/BazNick/shortlink/internal/app/storage"
 )
 
-type FileLinks struct {
-	ShortURL    string `json:"short_url"`
-	OriginalURL string `json:"original_url"`
-}
+// FileLinks — строка файла хранилища, формат совпадает с storage.Link.
+type FileLinks = storage.Link
+
+// compactSlack — сколько устаревших записей файл терпит сверх числа
+// ссылок, прежде чем AddClicks перепишет его целиком.
+const compactSlack = 1000
 
 type FileStore struct {
+	// mu держится от чтения до записи, чтобы запись, собранная по
+	// устаревшему чтению, не вернула старую версию ссылки
+	mu          sync.Mutex
 	Path        string
 	FileStorage *os.File
+	// пустой — storage.DedupGlobal
+	Dedup storage.Dedup
 }
 
 func NewFileStore(path string) *FileStore {
@@ -25,77 +37,361 @@ func NewFileStore(path string) *FileStore {
 }
 
 func (f *FileStore) AddHash(hash, link, userID string) (string, error) {
-	file, err := os.OpenFile(f.Path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0666)
+	now := time.Now()
+	err := f.appendRecords(FileLinks{
+		ShortURL:    hash,
+		OriginalURL: link,
+		UserID:      userID,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	})
 	if err != nil {
 		return "", err
 	}
 
-	bufWriter := bufio.NewWriter(file)
+	return "", nil
+}
 
-	data, err := json.Marshal(FileLinks{
-		ShortURL:    hash,
-		OriginalURL: link,
+func (f *FileStore) AddBatch(links []storage.Link) error {
+	now := time.Now()
+
+	records := make([]FileLinks, len(links))
+	for i, link := range links {
+		if link.CreatedAt.IsZero() {
+			link.CreatedAt = now
+		}
+		if link.UpdatedAt.IsZero() {
+			link.UpdatedAt = link.CreatedAt
+		}
+		records[i] = link
+	}
+
+	return f.appendRecords(records...)
+}
+
+func (f *FileStore) GetHash(hash string) string {
+	var link string
+
+	// файл только дописывается, поэтому актуальна последняя запись
+	f.mustScan(func(res FileLinks) {
+		if hash != res.ShortURL {
+			return
+		}
+		link = res.OriginalURL
+		if res.IsDeleted || res.IsDisabled || res.Expired(time.Now()) {
+			link = ""
+		}
+	})
+
+	return link
+}
+
+func (f *FileStore) GetLink(hash string) (storage.Link, error) {
+	var (
+		rec   storage.Link
+		found bool
+	)
+
+	err := f.scan(func(res FileLinks) error {
+		if hash == res.ShortURL {
+			rec, found = res, true
+		}
+		return nil
 	})
 	if err != nil {
-		file.Close()
-		return "", err
+		return storage.Link{}, err
 	}
 
-	if _, err = bufWriter.Write(data); err != nil {
-		file.Close()
-		return "", err
+	if !found {
+		return storage.Link{}, apperr.ErrLinkNotFound
 	}
+	return rec, nil
+}
 
-	if _, err = bufWriter.WriteRune('\n'); err != nil {
-		file.Close()
-		return "", err
+func (f *FileStore) CheckValExists(userID, link string) bool {
+	var exists bool
+
+	// адрес ссылки можно поменять, поэтому смотрим только последние записи
+	err := f.Links(func(res storage.Link) error {
+		if f.Dedup.Duplicate(res, userID, link) {
+			exists = true
+		}
+		return nil
+	})
+	if err != nil {
+		log.Fatalf("Ошибка при открытии файла %s: %v", f.Path, err)
 	}
 
-	if err := bufWriter.Flush(); err != nil {
-		file.Close()
-		return "", err
+	return exists
+}
+
+func (f *FileStore) SetDedup(mode storage.Dedup) error {
+	f.Dedup = mode
+	return nil
+}
+
+// AddClicks дописывает ссылки с новым числом переходов, поэтому счётчик
+// стоит сбрасывать пачками, а не на каждый переход. Когда устаревших
+// записей набирается слишком много, файл переписывается целиком.
+func (f *FileStore) AddClicks(clicks map[string]storage.Clicks) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	order, latest, records, err := f.latest()
+	if err != nil {
+		return err
 	}
 
-	if err := file.Sync(); err != nil {
-		file.Close()
-		return "", err
+	var updated []FileLinks
+	for _, hash := range order {
+		c, ok := clicks[hash]
+		if !ok {
+			continue
+		}
+		link := latest[hash]
+		c.Apply(&link)
+		latest[hash] = link
+		updated = append(updated, link)
 	}
 
-	return "", nil
+	if len(updated) == 0 {
+		return nil
+	}
+
+	if records+len(updated) > 2*len(order)+compactSlack {
+		compacted := make([]FileLinks, len(order))
+		for i, hash := range order {
+			compacted[i] = latest[hash]
+		}
+		return f.rewriteRecords(compacted)
+	}
+
+	return f.writeRecords(updated...)
 }
 
-func (f *FileStore) GetHash(hash string) string {
-	reader, errOpenFile := os.OpenFile(f.Path, os.O_RDONLY|os.O_CREATE, 0666)
-	if errOpenFile != nil {
-		log.Fatalf("Ошибка при открытии файла %s: %v", f.Path, errOpenFile)
+func (f *FileStore) DeleteLinks(userID string, hashes []string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	wanted := make(map[string]bool, len(hashes))
+	for _, hash := range hashes {
+		wanted[hash] = true
 	}
-	defer reader.Close()
 
-	var (
-		r   = bufio.NewReader(reader)
-		dec = json.NewDecoder(r)
-	)
+	owned := make(map[string]FileLinks)
+	err := f.scan(func(res FileLinks) error {
+		if wanted[res.ShortURL] && res.UserID == userID {
+			owned[res.ShortURL] = res
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
 
-	for {
-		var res FileLinks
-		err := dec.Decode(&res)
-		if err == io.EOF {
-			break
+	now := time.Now()
+	deleted := make([]FileLinks, 0, len(owned))
+	for _, res := range owned {
+		if res.IsDeleted {
+			continue
 		}
+		res.IsDeleted = true
+		res.UpdatedAt = now
+		deleted = append(deleted, res)
+	}
+
+	if len(deleted) == 0 {
+		return nil
+	}
+
+	return f.writeRecords(deleted...)
+}
+
+func (f *FileStore) Links(fn func(link storage.Link) error) error {
+	order, latest, _, err := f.latest()
+	if err != nil {
+		return err
+	}
+
+	for _, hash := range order {
+		if err := fn(latest[hash]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *FileStore) UserLinks(userID string, fn func(link storage.Link) error) error {
+	return f.Links(func(link storage.Link) error {
+		if link.UserID != userID {
+			return nil
+		}
+		return fn(link)
+	})
+}
+
+func (f *FileStore) WorkspaceLinks(workspaceID string, fn func(link storage.Link) error) error {
+	return f.Links(func(link storage.Link) error {
+		if link.WorkspaceID != workspaceID {
+			return nil
+		}
+		return fn(link)
+	})
+}
+
+func (f *FileStore) ReassignLinks(from, to string) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var moved []FileLinks
+
+	now := time.Now()
+	err := f.UserLinks(from, func(link storage.Link) error {
+		link.UserID = to
+		link.UpdatedAt = now
+		moved = append(moved, link)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	if len(moved) == 0 {
+		return 0, nil
+	}
+
+	return len(moved), f.writeRecords(moved...)
+}
+
+// UpdateLink не даёт fn поменять счётчик переходов: его ведёт только
+// AddClicks.
+func (f *FileStore) UpdateLink(hash string, fn func(link *storage.Link) error) (storage.Link, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	link, err := f.GetLink(hash)
+	if err != nil {
+		return storage.Link{}, err
+	}
+	clicks, accessed := link.Clicks, link.LastAccessedAt
+
+	if err := fn(&link); err != nil {
+		return storage.Link{}, err
+	}
+	link.ShortURL = hash
+	link.Clicks, link.LastAccessedAt = clicks, accessed
+	link.UpdatedAt = time.Now()
+
+	return link, f.writeRecords(link)
+}
+
+func (f *FileStore) InsertLink(link storage.Link) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var taken bool
+	err := f.scan(func(res FileLinks) error {
+		if res.ShortURL == link.ShortURL {
+			taken = true
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if taken {
+		return apperr.ErrCodeTaken
+	}
+
+	if link.CreatedAt.IsZero() {
+		link.CreatedAt = time.Now()
+	}
+	if link.UpdatedAt.IsZero() {
+		link.UpdatedAt = link.CreatedAt
+	}
+	return f.writeRecords(link)
+}
+
+func (f *FileStore) appendRecords(records ...FileLinks) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.writeRecords(records...)
+}
+
+func (f *FileStore) writeRecords(records ...FileLinks) error {
+	return writeRecordsTo(f.Path, os.O_APPEND, records...)
+}
+
+func writeRecordsTo(path string, flag int, records ...FileLinks) error {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|flag, 0666)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	bufWriter := bufio.NewWriter(file)
+
+	for _, rec := range records {
+		data, err := json.Marshal(rec)
 		if err != nil {
-			log.Fatalf("Ошибка при открытии файла %s: %v", f.Path, err)
+			return err
 		}
-		if hash == res.ShortURL {
-			return res.OriginalURL
+
+		if _, err = bufWriter.Write(data); err != nil {
+			return err
+		}
+
+		if _, err = bufWriter.WriteRune('\n'); err != nil {
+			return err
 		}
 	}
-	return ""
+
+	if err := bufWriter.Flush(); err != nil {
+		return err
+	}
+
+	return file.Sync()
 }
 
-func (f *FileStore) CheckValExists(link string) bool {
-	reader, errOpenFile := os.OpenFile(f.Path, os.O_RDONLY|os.O_CREATE, 0666)
-	if errOpenFile != nil {
-		log.Fatalf("Ошибка при открытии файла %s: %v", f.Path, errOpenFile)
+// rewriteRecords заменяет файл записями records через временный файл,
+// чтобы читатели видели либо старый файл, либо новый целиком.
+func (f *FileStore) rewriteRecords(records []FileLinks) error {
+	tmp := f.Path + ".tmp"
+	if err := writeRecordsTo(tmp, os.O_TRUNC, records...); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, f.Path)
+}
+
+// latest — последние версии ссылок в порядке первого появления и
+// сколько всего записей в файле.
+func (f *FileStore) latest() ([]string, map[string]FileLinks, int, error) {
+	var (
+		order   []string
+		latest  = make(map[string]FileLinks)
+		records int
+	)
+
+	err := f.scan(func(res FileLinks) error {
+		if _, ok := latest[res.ShortURL]; !ok {
+			order = append(order, res.ShortURL)
+		}
+		latest[res.ShortURL] = res
+		records++
+		return nil
+	})
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	return order, latest, records, nil
+}
+
+func (f *FileStore) scan(fn func(res FileLinks) error) error {
+	reader, err := os.OpenFile(f.Path, os.O_RDONLY|os.O_CREATE, 0666)
+	if err != nil {
+		return err
 	}
 	defer reader.Close()
 
@@ -108,14 +404,23 @@ func (f *FileStore) CheckValExists(link string) bool {
 		var res FileLinks
 		err := dec.Decode(&res)
 		if err == io.EOF {
-			break
+			return nil
 		}
 		if err != nil {
-			log.Fatalf("Ошибка при открытии файла %s: %v", f.Path, err)
+			return err
 		}
-		if link == res.OriginalURL {
-			return true
+		if err := fn(res); err != nil {
+			return err
 		}
 	}
-	return false
+}
+
+func (f *FileStore) mustScan(fn func(res FileLinks)) {
+	err := f.scan(func(res FileLinks) error {
+		fn(res)
+		return nil
+	})
+	if err != nil {
+		log.Fatalf("Ошибка при открытии файла %s: %v", f.Path, err)
+	}
 }
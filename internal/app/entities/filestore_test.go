@@ -0,0 +1,52 @@
+package entities
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/BazNick/shortlink/internal/app/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileStoreCompaction(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "links.json")
+	f := NewFileStore(path)
+
+	links := make([]storage.Link, 600)
+	clicks := make(map[string]storage.Clicks, len(links))
+	for i := range links {
+		hash := fmt.Sprintf("h%07d", i)
+		links[i] = storage.Link{ShortURL: hash, OriginalURL: "https://example.com/" + hash, UserID: "user"}
+		clicks[hash] = storage.Clicks{Count: 1}
+	}
+	require.NoError(t, f.AddBatch(links))
+	require.NoError(t, f.DeleteLinks("user", []string{links[1].ShortURL}))
+
+	for i := 0; i < 10; i++ {
+		require.NoError(t, f.AddClicks(clicks))
+	}
+
+	file, err := os.Open(path)
+	require.NoError(t, err)
+	defer file.Close()
+
+	lines := 0
+	for scanner := bufio.NewScanner(file); scanner.Scan(); {
+		lines++
+	}
+	assert.LessOrEqual(t, lines, 3*len(links)+compactSlack)
+
+	// после перезаписи остаются последние версии ссылок
+	link, err := f.GetLink(links[0].ShortURL)
+	require.NoError(t, err)
+	assert.Equal(t, int64(10), link.Clicks)
+	assert.Equal(t, links[0].OriginalURL, f.GetHash(links[0].ShortURL))
+
+	deleted, err := f.GetLink(links[1].ShortURL)
+	require.NoError(t, err)
+	assert.True(t, deleted.IsDeleted)
+}
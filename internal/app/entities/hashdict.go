@@ -1,32 +1,299 @@
 package entities
 
-type HashDict struct {
-	Dict map[string]string
+import (
+	"sync"
+	"time"
+
+	"github.com/BazNick/shortlink/internal/app/apperr"
+	"github.com/BazNick/shortlink/internal/app/storage"
+)
+
+type (
+	LinkMeta struct {
+		UserID      string
+		IsDeleted   bool
+		IsDisabled  bool
+		WorkspaceID string
+		ExpiresAt   *time.Time
+		Title       string
+		Description string
+		Tags        []string
+		CreatedAt   time.Time
+		UpdatedAt   time.Time
+		Clicks      int64
+		// LastAccessedAt — последний переход, nil — переходов не было
+		LastAccessedAt *time.Time
+	}
+
+	// HashDict — хранилище в памяти. Счётчики переходов сбрасываются в
+	// него из фона, поэтому все методы берут mu.
+	HashDict struct {
+		mu   sync.RWMutex
+		Dict map[string]string
+		Meta map[string]*LinkMeta
+		// пустой — storage.DedupGlobal
+		Dedup storage.Dedup
+	}
+)
+
+func (meta *LinkMeta) expired(now time.Time) bool {
+	return meta.ExpiresAt != nil && !now.Before(*meta.ExpiresAt)
 }
 
 func NewHashDict() *HashDict {
 	return &HashDict{
 		Dict: make(map[string]string),
+		Meta: make(map[string]*LinkMeta),
 	}
 }
 
 func (hasdDict *HashDict) AddHash(hash, link, userID string) (string, error) {
-	hasdDict.Dict[hash] = link
+	hasdDict.mu.Lock()
+	defer hasdDict.mu.Unlock()
+
+	hasdDict.addHash(hash, link, userID)
 	return "", nil
 }
 
+func (hasdDict *HashDict) addHash(hash, link, userID string) {
+	hasdDict.Dict[hash] = link
+	if hasdDict.Meta == nil {
+		hasdDict.Meta = make(map[string]*LinkMeta)
+	}
+	now := time.Now()
+	hasdDict.Meta[hash] = &LinkMeta{UserID: userID, CreatedAt: now, UpdatedAt: now}
+}
+
+func (hasdDict *HashDict) AddBatch(links []storage.Link) error {
+	hasdDict.mu.Lock()
+	defer hasdDict.mu.Unlock()
+
+	hasdDict.addBatch(links)
+	return nil
+}
+
+func (hasdDict *HashDict) addBatch(links []storage.Link) {
+	for _, link := range links {
+		hasdDict.addHash(link.ShortURL, link.OriginalURL, link.UserID)
+		hasdDict.Meta[link.ShortURL].IsDeleted = link.IsDeleted
+		hasdDict.Meta[link.ShortURL].IsDisabled = link.IsDisabled
+		hasdDict.Meta[link.ShortURL].WorkspaceID = link.WorkspaceID
+		hasdDict.Meta[link.ShortURL].ExpiresAt = link.ExpiresAt
+		hasdDict.Meta[link.ShortURL].Title = link.Title
+		hasdDict.Meta[link.ShortURL].Description = link.Description
+		hasdDict.Meta[link.ShortURL].Tags = link.Tags
+		hasdDict.Meta[link.ShortURL].Clicks = link.Clicks
+		hasdDict.Meta[link.ShortURL].LastAccessedAt = link.LastAccessedAt
+		if !link.CreatedAt.IsZero() {
+			hasdDict.Meta[link.ShortURL].CreatedAt = link.CreatedAt
+			hasdDict.Meta[link.ShortURL].UpdatedAt = link.CreatedAt
+		}
+		if !link.UpdatedAt.IsZero() {
+			hasdDict.Meta[link.ShortURL].UpdatedAt = link.UpdatedAt
+		}
+	}
+}
+
+func (hasdDict *HashDict) InsertLink(link storage.Link) error {
+	hasdDict.mu.Lock()
+	defer hasdDict.mu.Unlock()
+
+	if _, ok := hasdDict.Dict[link.ShortURL]; ok {
+		return apperr.ErrCodeTaken
+	}
+	hasdDict.addBatch([]storage.Link{link})
+	return nil
+}
+
 func (hasdDict *HashDict) GetHash(hash string) string {
+	hasdDict.mu.RLock()
+	defer hasdDict.mu.RUnlock()
+
+	if meta, ok := hasdDict.Meta[hash]; ok && (meta.IsDeleted || meta.IsDisabled || meta.expired(time.Now())) {
+		return ""
+	}
 	if val, ok := hasdDict.Dict[hash]; ok {
 		return val
 	}
 	return ""
 }
 
-func (hasdDict *HashDict) CheckValExists(link string) bool {
-	for _, v := range hasdDict.Dict {
-		if v == link {
+func (hasdDict *HashDict) GetLink(hash string) (storage.Link, error) {
+	hasdDict.mu.RLock()
+	defer hasdDict.mu.RUnlock()
+
+	return hasdDict.getLink(hash)
+}
+
+func (hasdDict *HashDict) getLink(hash string) (storage.Link, error) {
+	link, ok := hasdDict.Dict[hash]
+	if !ok {
+		return storage.Link{}, apperr.ErrLinkNotFound
+	}
+
+	rec := storage.Link{
+		ShortURL:    hash,
+		OriginalURL: link,
+	}
+	if meta, ok := hasdDict.Meta[hash]; ok {
+		rec.UserID = meta.UserID
+		rec.IsDeleted = meta.IsDeleted
+		rec.IsDisabled = meta.IsDisabled
+		rec.WorkspaceID = meta.WorkspaceID
+		rec.ExpiresAt = meta.ExpiresAt
+		rec.Title = meta.Title
+		rec.Description = meta.Description
+		rec.Tags = meta.Tags
+		rec.CreatedAt = meta.CreatedAt
+		rec.UpdatedAt = meta.UpdatedAt
+		rec.Clicks = meta.Clicks
+		rec.LastAccessedAt = meta.LastAccessedAt
+	}
+	return rec, nil
+}
+
+func (hasdDict *HashDict) CheckValExists(userID, link string) bool {
+	hasdDict.mu.RLock()
+	defer hasdDict.mu.RUnlock()
+
+	for hash, v := range hasdDict.Dict {
+		existing := storage.Link{OriginalURL: v}
+		if meta, ok := hasdDict.Meta[hash]; ok {
+			existing.UserID = meta.UserID
+		}
+		if hasdDict.Dedup.Duplicate(existing, userID, link) {
 			return true
 		}
 	}
 	return false
 }
+
+func (hasdDict *HashDict) SetDedup(mode storage.Dedup) error {
+	hasdDict.mu.Lock()
+	defer hasdDict.mu.Unlock()
+
+	hasdDict.Dedup = mode
+	return nil
+}
+
+func (hasdDict *HashDict) AddClicks(clicks map[string]storage.Clicks) error {
+	hasdDict.mu.Lock()
+	defer hasdDict.mu.Unlock()
+
+	for hash, c := range clicks {
+		if meta, ok := hasdDict.Meta[hash]; ok {
+			link := storage.Link{Clicks: meta.Clicks, LastAccessedAt: meta.LastAccessedAt}
+			c.Apply(&link)
+			meta.Clicks, meta.LastAccessedAt = link.Clicks, link.LastAccessedAt
+		}
+	}
+	return nil
+}
+
+// setClicks заменяет счётчики ссылок итоговыми значениями.
+func (hasdDict *HashDict) setClicks(totals map[string]storage.Clicks) {
+	hasdDict.mu.Lock()
+	defer hasdDict.mu.Unlock()
+
+	for hash, c := range totals {
+		if meta, ok := hasdDict.Meta[hash]; ok {
+			at := c.LastAt
+			meta.Clicks, meta.LastAccessedAt = c.Count, &at
+		}
+	}
+}
+
+func (hasdDict *HashDict) DeleteLinks(userID string, hashes []string) error {
+	hasdDict.deleteLinks(userID, hashes, time.Now())
+	return nil
+}
+
+func (hasdDict *HashDict) deleteLinks(userID string, hashes []string, at time.Time) {
+	hasdDict.mu.Lock()
+	defer hasdDict.mu.Unlock()
+
+	for _, hash := range hashes {
+		if meta, ok := hasdDict.Meta[hash]; ok && meta.UserID == userID {
+			meta.IsDeleted = true
+			meta.UpdatedAt = at
+		}
+	}
+}
+
+// Links отдаёт снимок ссылок: fn вызывается без блокировки и может
+// обращаться к словарю.
+func (hasdDict *HashDict) Links(fn func(link storage.Link) error) error {
+	hasdDict.mu.RLock()
+	links := make([]storage.Link, 0, len(hasdDict.Dict))
+	for hash := range hasdDict.Dict {
+		rec, _ := hasdDict.getLink(hash)
+		links = append(links, rec)
+	}
+	hasdDict.mu.RUnlock()
+
+	for _, rec := range links {
+		if err := fn(rec); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (hasdDict *HashDict) UserLinks(userID string, fn func(link storage.Link) error) error {
+	return hasdDict.Links(func(link storage.Link) error {
+		if link.UserID != userID {
+			return nil
+		}
+		return fn(link)
+	})
+}
+
+func (hasdDict *HashDict) WorkspaceLinks(workspaceID string, fn func(link storage.Link) error) error {
+	return hasdDict.Links(func(link storage.Link) error {
+		if link.WorkspaceID != workspaceID {
+			return nil
+		}
+		return fn(link)
+	})
+}
+
+func (hasdDict *HashDict) ReassignLinks(from, to string) (int, error) {
+	return hasdDict.reassignLinks(from, to, time.Now()), nil
+}
+
+func (hasdDict *HashDict) reassignLinks(from, to string, at time.Time) int {
+	hasdDict.mu.Lock()
+	defer hasdDict.mu.Unlock()
+
+	var n int
+	for _, meta := range hasdDict.Meta {
+		if meta.UserID == from {
+			meta.UserID = to
+			meta.UpdatedAt = at
+			n++
+		}
+	}
+	return n
+}
+
+func (hasdDict *HashDict) UpdateLink(hash string, fn func(link *storage.Link) error) (storage.Link, error) {
+	hasdDict.mu.Lock()
+	defer hasdDict.mu.Unlock()
+
+	link, err := hasdDict.getLink(hash)
+	if err != nil {
+		return storage.Link{}, err
+	}
+
+	if err := fn(&link); err != nil {
+		return storage.Link{}, err
+	}
+	link.ShortURL = hash
+	link.UpdatedAt = time.Now()
+	// переходы ведёт только AddClicks
+	meta := hasdDict.Meta[hash]
+	link.Clicks, link.LastAccessedAt = meta.Clicks, meta.LastAccessedAt
+
+	hasdDict.addBatch([]storage.Link{link})
+	return link, nil
+}
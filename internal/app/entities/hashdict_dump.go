@@ -0,0 +1,52 @@
+package entities
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+
+	"github.com/BazNick/shortlink/internal/app/storage"
+)
+
+// Dump пишет содержимое словаря в w: JSON-строки storage.Link, сжатые gzip.
+func (hasdDict *HashDict) Dump(w io.Writer) error {
+	gz := gzip.NewWriter(w)
+	bufWriter := bufio.NewWriter(gz)
+	enc := json.NewEncoder(bufWriter)
+
+	err := hasdDict.Links(func(link storage.Link) error {
+		return enc.Encode(link)
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := bufWriter.Flush(); err != nil {
+		return err
+	}
+
+	return gz.Close()
+}
+
+// Load добавляет в словарь ссылки из дампа, записанного Dump.
+func (hasdDict *HashDict) Load(r io.Reader) error {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+
+	dec := json.NewDecoder(bufio.NewReader(gz))
+	for {
+		var link storage.Link
+		err := dec.Decode(&link)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		hasdDict.AddBatch([]storage.Link{link})
+	}
+}
@@ -1,21 +1,25 @@
 package entities
 
 import (
+	"path/filepath"
 	"testing"
 
+	"github.com/BazNick/shortlink/internal/app/apperr"
+	"github.com/BazNick/shortlink/internal/app/storage"
 	"github.com/stretchr/testify/require"
 )
 
 func TestCheckValExists(t *testing.T) {
 	tests := []struct {
 		name string
-		hd   HashDict
+		hd   *HashDict
+		user string
 		link string
 		want bool
 	}{
 		{
 			name: "Value exists in the hash dictionary",
-			hd: HashDict{
+			hd: &HashDict{
 				Dict: map[string]string{
 					"key1": "value1",
 					"key2": "value2",
@@ -26,7 +30,7 @@ func TestCheckValExists(t *testing.T) {
 		},
 		{
 			name: "Value does not exist in the hash dictionary",
-			hd: HashDict{
+			hd: &HashDict{
 				Dict: map[string]string{
 					"key1": "value1",
 					"key2": "value2",
@@ -37,17 +41,48 @@ func TestCheckValExists(t *testing.T) {
 		},
 		{
 			name: "Empty hash dictionary",
-			hd: HashDict{
+			hd: &HashDict{
 				Dict: map[string]string{},
 			},
 			link: "any_value",
 			want: false,
 		},
+		{
+			name: "Value of another user with per-user dedup",
+			hd: &HashDict{
+				Dict:  map[string]string{"key1": "value1"},
+				Meta:  map[string]*LinkMeta{"key1": {UserID: "user1"}},
+				Dedup: storage.DedupUser,
+			},
+			user: "user2",
+			link: "value1",
+			want: false,
+		},
+		{
+			name: "Value of the same user with per-user dedup",
+			hd: &HashDict{
+				Dict:  map[string]string{"key1": "value1"},
+				Meta:  map[string]*LinkMeta{"key1": {UserID: "user1"}},
+				Dedup: storage.DedupUser,
+			},
+			user: "user1",
+			link: "value1",
+			want: true,
+		},
+		{
+			name: "Value exists with dedup off",
+			hd: &HashDict{
+				Dict:  map[string]string{"key1": "value1"},
+				Dedup: storage.DedupOff,
+			},
+			link: "value1",
+			want: false,
+		},
 	}
 
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
-			got := test.hd.CheckValExists(test.link)
+			got := test.hd.CheckValExists(test.user, test.link)
 			require.Equal(t, test.want, got)
 		})
 	}
@@ -56,14 +91,14 @@ func TestCheckValExists(t *testing.T) {
 func TestHashDict_AddHash(t *testing.T) {
 	tests := []struct {
 		name   string
-		h      HashDict
+		h      *HashDict
 		hash   string
 		link   string
 		userID string
 	}{
 		{
 			name: "Adding a new key-value pair to an empty hash dictionary",
-			h: HashDict{
+			h: &HashDict{
 				Dict: map[string]string{},
 			},
 			hash:   "new_key",
@@ -72,7 +107,7 @@ func TestHashDict_AddHash(t *testing.T) {
 		},
 		{
 			name: "Adding a new key-value pair to a non-empty hash dictionary",
-			h: HashDict{
+			h: &HashDict{
 				Dict: map[string]string{
 					"existing_key": "existing_value",
 				},
@@ -83,7 +118,7 @@ func TestHashDict_AddHash(t *testing.T) {
 		},
 		{
 			name: "Overwriting existing value with a new one",
-			h: HashDict{
+			h: &HashDict{
 				Dict: map[string]string{
 					"existing_key": "old_value",
 				},
@@ -106,13 +141,13 @@ func TestHashDict_AddHash(t *testing.T) {
 func TestHashDict_GetHash(t *testing.T) {
 	tests := []struct {
 		name string
-		h    HashDict
+		h    *HashDict
 		hash string
 		want string
 	}{
 		{
 			name: "Getting value for existing key",
-			h: HashDict{
+			h: &HashDict{
 				Dict: map[string]string{
 					"key1": "value1",
 					"key2": "value2",
@@ -123,7 +158,7 @@ func TestHashDict_GetHash(t *testing.T) {
 		},
 		{
 			name: "Getting value for non-existing key",
-			h: HashDict{
+			h: &HashDict{
 				Dict: map[string]string{
 					"key1": "value1",
 					"key2": "value2",
@@ -134,7 +169,7 @@ func TestHashDict_GetHash(t *testing.T) {
 		},
 		{
 			name: "Getting value from an empty hash dictionary",
-			h: HashDict{
+			h: &HashDict{
 				Dict: map[string]string{},
 			},
 			hash: "any_key",
@@ -149,3 +184,40 @@ func TestHashDict_GetHash(t *testing.T) {
 		})
 	}
 }
+
+func TestInsertLink(t *testing.T) {
+	durable, err := NewDurableHashDict(filepath.Join(t.TempDir(), "snapshot.gz"))
+	require.NoError(t, err)
+	defer durable.Close()
+
+	tests := []struct {
+		name  string
+		store storage.Storage
+	}{
+		{name: "HashDict", store: NewHashDict()},
+		{name: "DurableHashDict", store: durable},
+		{name: "FileStore", store: NewFileStore(filepath.Join(t.TempDir(), "links.json"))},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.store.AddHash("taken001", "https://owner.ru", "owner")
+			require.NoError(t, err)
+
+			err = storage.InsertLink(tt.store, storage.Link{ShortURL: "taken001", OriginalURL: "https://thief.ru", UserID: "thief"})
+			require.ErrorIs(t, err, apperr.ErrCodeTaken)
+
+			link, err := tt.store.GetLink("taken001")
+			require.NoError(t, err)
+			require.Equal(t, "owner", link.UserID)
+			require.Equal(t, "https://owner.ru", link.OriginalURL)
+
+			err = storage.InsertLink(tt.store, storage.Link{ShortURL: "free0001", OriginalURL: "https://new.ru", UserID: "user", Title: "New"})
+			require.NoError(t, err)
+			link, err = tt.store.GetLink("free0001")
+			require.NoError(t, err)
+			require.Equal(t, "New", link.Title)
+			require.False(t, link.CreatedAt.IsZero())
+		})
+	}
+}
@@ -0,0 +1,190 @@
+package entities
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"os"
+	"sync"
+)
+
+// recordLog хранит записи вспомогательных хранилищ (ключи, пользователи и
+// т.п.) в JSON-строках: каждое изменение дописывает запись целиком, при
+// старте побеждает последняя. С пустым путём ничего не пишет.
+//
+// Если задан key, лог помнит последнюю запись каждого объекта и
+// переписывает файл только ими: при загрузке, если есть устаревшие
+// записи, и когда устаревших набирается больше compactSlack. Журнал
+// действий и история версий записи не заменяют, им key не нужен.
+type recordLog[T any] struct {
+	path string
+	mu   sync.Mutex
+
+	// key — к какому объекту относится запись и жив ли он после неё
+	key func(rec T) (string, bool)
+	// последние записи живых объектов в порядке первого появления
+	live    map[string]T
+	order   []string
+	ordered map[string]bool
+	// сколько записей в файле
+	records int
+}
+
+func newRecordLog[T any](path string) *recordLog[T] {
+	return &recordLog[T]{path: path}
+}
+
+func newCompactingLog[T any](path string, key func(rec T) (string, bool)) *recordLog[T] {
+	return &recordLog[T]{
+		path:    path,
+		key:     key,
+		live:    make(map[string]T),
+		ordered: make(map[string]bool),
+	}
+}
+
+func (l *recordLog[T]) append(rec T) error {
+	if l.path == "" {
+		return nil
+	}
+
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	file, err := os.OpenFile(l.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		return err
+	}
+
+	if err := file.Sync(); err != nil {
+		return err
+	}
+
+	if l.key == nil {
+		return nil
+	}
+	l.track(rec)
+	// запись уже в файле, неудачное сжатие её не отменяет
+	if l.records > 2*len(l.live)+compactSlack {
+		if err := l.compact(); err != nil {
+			log.Printf("compact %s: %v", l.path, err)
+		}
+	}
+	return nil
+}
+
+func (l *recordLog[T]) load(fn func(rec T)) error {
+	if l.path == "" {
+		return nil
+	}
+
+	file, err := os.Open(l.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	dec := json.NewDecoder(bufio.NewReader(file))
+	for {
+		var rec T
+		err := dec.Decode(&rec)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		fn(rec)
+
+		if l.key != nil {
+			l.track(rec)
+		}
+	}
+
+	if l.key == nil || l.records == len(l.live) {
+		return nil
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.compact()
+}
+
+func (l *recordLog[T]) track(rec T) {
+	l.records++
+
+	key, alive := l.key(rec)
+	if !alive {
+		delete(l.live, key)
+		return
+	}
+
+	l.live[key] = rec
+	if !l.ordered[key] {
+		l.ordered[key] = true
+		l.order = append(l.order, key)
+	}
+}
+
+// compact заменяет файл последними записями живых объектов через
+// временный файл, чтобы сбой посередине не потерял записи. Вызывается
+// под l.mu.
+func (l *recordLog[T]) compact() error {
+	tmp := l.path + ".tmp"
+	file, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	var (
+		w     = bufio.NewWriter(file)
+		enc   = json.NewEncoder(w)
+		order = make([]string, 0, len(l.live))
+	)
+	for _, key := range l.order {
+		rec, ok := l.live[key]
+		if !ok {
+			continue
+		}
+		if err := enc.Encode(rec); err != nil {
+			return err
+		}
+		order = append(order, key)
+	}
+
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, l.path); err != nil {
+		return err
+	}
+
+	l.order = order
+	l.ordered = make(map[string]bool, len(order))
+	for _, key := range order {
+		l.ordered[key] = true
+	}
+	l.records = len(order)
+
+	return nil
+}
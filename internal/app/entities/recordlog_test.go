@@ -0,0 +1,74 @@
+package entities
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/BazNick/shortlink/internal/app/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func countLines(t *testing.T, path string) int {
+	file, err := os.Open(path)
+	require.NoError(t, err)
+	defer file.Close()
+
+	lines := 0
+	for scanner := bufio.NewScanner(file); scanner.Scan(); {
+		lines++
+	}
+	return lines
+}
+
+func TestRecordLogCompaction(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "apikeys.json")
+
+	keys, err := NewAPIKeys(path)
+	require.NoError(t, err)
+	require.NoError(t, keys.AddAPIKey(storage.APIKey{ID: "k1", UserID: "u", Hash: "h1"}))
+	require.NoError(t, keys.AddAPIKey(storage.APIKey{ID: "k2", UserID: "u", Hash: "h2"}))
+
+	// отметки об использовании не раздувают файл
+	start := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 3*compactSlack; i++ {
+		require.NoError(t, keys.TouchAPIKey("k1", start.Add(time.Duration(i)*time.Minute)))
+	}
+	assert.LessOrEqual(t, countLines(t, path), 2*2+compactSlack+1)
+
+	// при загрузке устаревшие записи выбрасываются сразу
+	reloaded, err := NewAPIKeys(path)
+	require.NoError(t, err)
+	assert.Equal(t, 2, countLines(t, path))
+
+	key, err := reloaded.APIKeyByHash("h1")
+	require.NoError(t, err)
+	require.NotNil(t, key.LastUsedAt)
+	assert.Equal(t, start.Add(time.Duration(3*compactSlack-1)*time.Minute), key.LastUsedAt.UTC())
+}
+
+func TestRecordLogCompactionDropsRemoved(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "workspaces.json")
+
+	spaces, err := NewWorkspaces(path)
+	require.NoError(t, err)
+	require.NoError(t, spaces.AddWorkspace(
+		storage.Workspace{ID: "ws", Name: "Team"},
+		storage.Member{UserID: "owner", Role: storage.WorkspaceOwner},
+	))
+	require.NoError(t, spaces.PutMember(storage.Member{WorkspaceID: "ws", UserID: "guest", Role: storage.WorkspaceViewer}))
+	require.NoError(t, spaces.RemoveMember("ws", "guest"))
+
+	reloaded, err := NewWorkspaces(path)
+	require.NoError(t, err)
+	assert.Equal(t, 2, countLines(t, path))
+
+	_, err = reloaded.Member("ws", "guest")
+	assert.Error(t, err)
+	owner, err := reloaded.Member("ws", "owner")
+	require.NoError(t, err)
+	assert.Equal(t, storage.WorkspaceOwner, owner.Role)
+}
@@ -0,0 +1,98 @@
+package entities
+
+import (
+	"sync"
+	"time"
+
+	"github.com/BazNick/shortlink/internal/app/storage"
+)
+
+// Reports — очередь жалоб в памяти, в файловом режиме каждое изменение
+// жалобы дописывается в отдельный файл.
+type Reports struct {
+	mu      sync.RWMutex
+	reports []storage.Report
+	index   map[string]int
+	log     *recordLog[storage.Report]
+}
+
+func reportKey(report storage.Report) (string, bool) {
+	return report.ID, true
+}
+
+func NewReports(path string) (*Reports, error) {
+	r := &Reports{
+		index: make(map[string]int),
+		log:   newCompactingLog(path, reportKey),
+	}
+
+	err := r.log.load(func(report storage.Report) {
+		r.put(report)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+func (r *Reports) AddReport(report storage.Report) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.log.append(report); err != nil {
+		return err
+	}
+	r.put(report)
+
+	return nil
+}
+
+func (r *Reports) Reports(filter storage.ReportFilter, fn func(report storage.Report) error) error {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, report := range r.reports {
+		if !filter.Match(report) {
+			continue
+		}
+		if err := fn(report); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *Reports) ResolveReports(filter storage.ReportFilter, status, by string, at time.Time) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	filter.Status = storage.ReportOpen
+
+	var n int
+	for _, report := range r.reports {
+		if !filter.Match(report) {
+			continue
+		}
+
+		report.Status = status
+		report.ResolvedBy = by
+		report.ResolvedAt = &at
+		if err := r.log.append(report); err != nil {
+			return n, err
+		}
+		r.put(report)
+		n++
+	}
+	return n, nil
+}
+
+// put добавляет жалобу или заменяет прежнюю запись с тем же ID.
+func (r *Reports) put(report storage.Report) {
+	if idx, ok := r.index[report.ID]; ok {
+		r.reports[idx] = report
+		return
+	}
+	r.index[report.ID] = len(r.reports)
+	r.reports = append(r.reports, report)
+}
@@ -0,0 +1,103 @@
+package entities
+
+// schema — таблицы помимо links и поздние изменения links, NewDB
+// применяет их по порядку.
+var schema = []string{
+	`ALTER TABLE links ADD COLUMN IF NOT EXISTS is_disabled BOOLEAN NOT NULL DEFAULT FALSE`,
+	`CREATE TABLE IF NOT EXISTS api_keys (
+		id text PRIMARY KEY,
+		user_id text NOT NULL,
+		name text NOT NULL,
+		key_hash text NOT NULL UNIQUE,
+		scopes text NOT NULL,
+		created_at timestamptz NOT NULL,
+		last_used_at timestamptz,
+		revoked_at timestamptz
+	)`,
+	`CREATE INDEX IF NOT EXISTS idx_api_keys_user_id ON api_keys(user_id);`,
+	`CREATE TABLE IF NOT EXISTS users (
+		id text PRIMARY KEY,
+		login text NOT NULL UNIQUE,
+		password_hash text NOT NULL,
+		created_at timestamptz NOT NULL
+	)`,
+	`ALTER TABLE users ADD COLUMN IF NOT EXISTS subject text UNIQUE`,
+	`ALTER TABLE users ADD COLUMN IF NOT EXISTS role text NOT NULL DEFAULT ''`,
+	`ALTER TABLE links ADD COLUMN IF NOT EXISTS workspace_id text NOT NULL DEFAULT ''`,
+	`CREATE INDEX IF NOT EXISTS idx_links_workspace_id ON links(workspace_id);`,
+	`CREATE TABLE IF NOT EXISTS workspaces (
+		id text PRIMARY KEY,
+		name text NOT NULL,
+		created_at timestamptz NOT NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS workspace_members (
+		workspace_id text NOT NULL REFERENCES workspaces(id),
+		user_id text NOT NULL,
+		role text NOT NULL,
+		added_at timestamptz NOT NULL,
+		PRIMARY KEY (workspace_id, user_id)
+	)`,
+	`CREATE INDEX IF NOT EXISTS idx_workspace_members_user_id ON workspace_members(user_id);`,
+	`CREATE TABLE IF NOT EXISTS rate_limits (
+		key text PRIMARY KEY,
+		tokens double precision NOT NULL,
+		updated_at timestamptz
+	)`,
+	`CREATE TABLE IF NOT EXISTS audit_log (
+		id text PRIMARY KEY,
+		at timestamptz NOT NULL,
+		action text NOT NULL,
+		short_url text NOT NULL,
+		actor_id text NOT NULL,
+		ip text NOT NULL,
+		request_id text NOT NULL,
+		before jsonb,
+		after jsonb,
+		note text NOT NULL
+	)`,
+	`CREATE INDEX IF NOT EXISTS idx_audit_log_short_url ON audit_log(short_url);`,
+	`CREATE INDEX IF NOT EXISTS idx_audit_log_at ON audit_log(at);`,
+	`CREATE TABLE IF NOT EXISTS reports (
+		id text PRIMARY KEY,
+		short_url text NOT NULL,
+		reason text NOT NULL,
+		reporter_id text NOT NULL,
+		ip text NOT NULL,
+		created_at timestamptz NOT NULL,
+		status text NOT NULL,
+		resolved_by text NOT NULL,
+		resolved_at timestamptz
+	)`,
+	`CREATE INDEX IF NOT EXISTS idx_reports_short_url ON reports(short_url, status);`,
+	`CREATE INDEX IF NOT EXISTS idx_reports_status ON reports(status, created_at);`,
+	`ALTER TABLE links ADD COLUMN IF NOT EXISTS expires_at timestamptz`,
+	`CREATE TABLE IF NOT EXISTS link_versions (
+		short_url text NOT NULL,
+		version integer NOT NULL,
+		link jsonb NOT NULL,
+		changed_by text NOT NULL,
+		changed_at timestamptz NOT NULL,
+		restored_from integer NOT NULL DEFAULT 0,
+		PRIMARY KEY (short_url, version)
+	)`,
+	`ALTER TABLE links ADD COLUMN IF NOT EXISTS title text NOT NULL DEFAULT ''`,
+	`ALTER TABLE links ADD COLUMN IF NOT EXISTS description text NOT NULL DEFAULT ''`,
+	`ALTER TABLE links ADD COLUMN IF NOT EXISTS tags text[]`,
+	`CREATE INDEX IF NOT EXISTS idx_links_tags ON links USING gin(tags);`,
+	`ALTER TABLE links ADD COLUMN IF NOT EXISTS created_at timestamptz NOT NULL DEFAULT now()`,
+	`ALTER TABLE links ADD COLUMN IF NOT EXISTS clicks bigint NOT NULL DEFAULT 0`,
+	`CREATE INDEX IF NOT EXISTS idx_links_user_created ON links(user_id, created_at, short_url);`,
+	`CREATE INDEX IF NOT EXISTS idx_links_user_clicks ON links(user_id, clicks, short_url);`,
+	`CREATE OR REPLACE FUNCTION links_search_vector(title text, tags text[], description text, original_url text)
+	 RETURNS tsvector LANGUAGE sql IMMUTABLE AS $$
+		SELECT setweight(to_tsvector('simple', title), 'A') ||
+			setweight(to_tsvector('simple', coalesce(array_to_string(tags, ' '), '')), 'B') ||
+			setweight(to_tsvector('simple', description), 'C') ||
+			setweight(to_tsvector('simple', regexp_replace(original_url, '[^[:alnum:]]+', ' ', 'g')), 'D')
+	 $$`,
+	`ALTER TABLE links ADD COLUMN IF NOT EXISTS search tsvector
+	 GENERATED ALWAYS AS (links_search_vector(title, tags, description, original_url)) STORED`,
+	`CREATE INDEX IF NOT EXISTS idx_links_search ON links USING gin(search);`,
+	`ALTER TABLE links ADD COLUMN IF NOT EXISTS updated_at timestamptz NOT NULL DEFAULT now()`,
+	`ALTER TABLE links ADD COLUMN IF NOT EXISTS last_accessed_at timestamptz`,
+}
@@ -0,0 +1,110 @@
+package entities
+
+import (
+	"sync"
+
+	"github.com/BazNick/shortlink/internal/app/apperr"
+	"github.com/BazNick/shortlink/internal/app/storage"
+)
+
+// Users — пользователи в памяти, для файлового режима записи дописываются
+// в отдельный файл, как и ключи.
+type Users struct {
+	mu    sync.RWMutex
+	users map[string]storage.User
+	log   *recordLog[storage.User]
+}
+
+func userKey(user storage.User) (string, bool) {
+	return user.ID, true
+}
+
+func NewUsers(path string) (*Users, error) {
+	u := &Users{
+		users: make(map[string]storage.User),
+		log:   newCompactingLog(path, userKey),
+	}
+
+	err := u.log.load(func(user storage.User) {
+		u.users[user.ID] = user
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return u, nil
+}
+
+func (u *Users) AddUser(user storage.User) error {
+	// логин проверяется и записывается под одной блокировкой,
+	// иначе два одновременных запроса займут его оба
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	for _, existing := range u.users {
+		if existing.Login == user.Login || existing.ID == user.ID ||
+			(user.Subject != "" && existing.Subject == user.Subject) {
+			return apperr.ErrUserExists
+		}
+	}
+
+	if err := u.log.append(user); err != nil {
+		return err
+	}
+	u.users[user.ID] = user
+
+	return nil
+}
+
+func (u *Users) UserByLogin(login string) (storage.User, error) {
+	u.mu.RLock()
+	defer u.mu.RUnlock()
+
+	for _, user := range u.users {
+		if user.Login == login {
+			return user, nil
+		}
+	}
+	return storage.User{}, apperr.ErrUserNotFound
+}
+
+func (u *Users) UserByID(id string) (storage.User, error) {
+	u.mu.RLock()
+	defer u.mu.RUnlock()
+
+	user, ok := u.users[id]
+	if !ok {
+		return storage.User{}, apperr.ErrUserNotFound
+	}
+	return user, nil
+}
+
+func (u *Users) UserBySubject(subject string) (storage.User, error) {
+	u.mu.RLock()
+	defer u.mu.RUnlock()
+
+	for _, user := range u.users {
+		if subject != "" && user.Subject == subject {
+			return user, nil
+		}
+	}
+	return storage.User{}, apperr.ErrUserNotFound
+}
+
+func (u *Users) SetUserRole(id, role string) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	user, ok := u.users[id]
+	if !ok {
+		return apperr.ErrUserNotFound
+	}
+
+	user.Role = role
+	if err := u.log.append(user); err != nil {
+		return err
+	}
+	u.users[id] = user
+
+	return nil
+}
@@ -0,0 +1,56 @@
+package entities
+
+import (
+	"sync"
+
+	"github.com/BazNick/shortlink/internal/app/storage"
+)
+
+// Versions — история правок ссылок в памяти, в файловом режиме
+// дописывается в отдельный файл.
+type Versions struct {
+	mu       sync.RWMutex
+	versions map[string][]storage.LinkVersion
+	log      *recordLog[storage.LinkVersion]
+}
+
+func NewVersions(path string) (*Versions, error) {
+	v := &Versions{
+		versions: make(map[string][]storage.LinkVersion),
+		log:      newRecordLog[storage.LinkVersion](path),
+	}
+
+	err := v.log.load(func(version storage.LinkVersion) {
+		v.versions[version.ShortURL] = append(v.versions[version.ShortURL], version)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return v, nil
+}
+
+func (v *Versions) AddVersion(version storage.LinkVersion) (storage.LinkVersion, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	version.Version = len(v.versions[version.ShortURL]) + 1
+	if err := v.log.append(version); err != nil {
+		return storage.LinkVersion{}, err
+	}
+	v.versions[version.ShortURL] = append(v.versions[version.ShortURL], version)
+
+	return version, nil
+}
+
+func (v *Versions) Versions(hash string, fn func(version storage.LinkVersion) error) error {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+
+	for _, version := range v.versions[hash] {
+		if err := fn(version); err != nil {
+			return err
+		}
+	}
+	return nil
+}
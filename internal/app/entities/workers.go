@@ -1,27 +1,90 @@
 package entities
 
-import "database/sql"
+import (
+	"errors"
 
+	"github.com/BazNick/shortlink/internal/app/apperr"
+	"github.com/BazNick/shortlink/internal/app/audit"
+	"github.com/BazNick/shortlink/internal/app/storage"
+)
+
+// DeleteRequest — пакет кодов на удаление. С WorkspaceID удаляются ссылки
+// пространства независимо от автора, права проверяет обработчик.
 type DeleteRequest struct {
-	UserID    string
-	ShortURLs []string
+	UserID      string
+	WorkspaceID string
+	ShortURLs   []string
+	// Source — автор запроса для журнала аудита
+	Source storage.AuditSource
 }
 
 var DeleteChan = make(chan DeleteRequest, 100)
 
-func StartDeleteWorkers(db *sql.DB, workerCount int) {
+var errOtherWorkspace = errors.New("link belongs to another workspace")
+
+func StartDeleteWorkers(store storage.Storage, log *audit.Log, workerCount int) {
 	for i := 0; i < workerCount; i++ {
 		go func(id int) {
 			for req := range DeleteChan {
-				_, err := db.Exec(
-					`UPDATE links SET is_deleted = true WHERE user_id = $1 AND short_url = ANY($2);`,
-					req.UserID,
-					req.ShortURLs,
-				)
-				if err != nil {
+				if err := deleteLinks(store, log, req); err != nil {
 					panic(err)
 				}
 			}
 		}(i)
 	}
 }
+
+func deleteLinks(store storage.Storage, log *audit.Log, req DeleteRequest) error {
+	// в журнал попадают только ссылки, которые этот запрос и удалил
+	before := make(map[string]storage.Link)
+	if log != nil {
+		for _, hash := range req.ShortURLs {
+			if link, err := store.GetLink(hash); err == nil && !link.IsDeleted {
+				before[hash] = link
+			}
+		}
+	}
+
+	var err error
+	if req.WorkspaceID != "" {
+		err = DeleteWorkspaceLinks(store, req.WorkspaceID, req.ShortURLs)
+	} else {
+		err = store.DeleteLinks(req.UserID, req.ShortURLs)
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, hash := range req.ShortURLs {
+		link, ok := before[hash]
+		if !ok {
+			continue
+		}
+		delete(before, hash)
+
+		after, err := store.GetLink(hash)
+		if err == nil && after.IsDeleted {
+			log.Add(req.Source, storage.AuditDelete, &link, &after, "")
+		}
+	}
+
+	return nil
+}
+
+// DeleteWorkspaceLinks помечает удалёнными ссылки пространства, чужие и
+// несуществующие коды пропускает, как DeleteLinks.
+func DeleteWorkspaceLinks(store storage.Storage, workspaceID string, hashes []string) error {
+	for _, hash := range hashes {
+		_, err := store.UpdateLink(hash, func(link *storage.Link) error {
+			if link.WorkspaceID != workspaceID {
+				return errOtherWorkspace
+			}
+			link.IsDeleted = true
+			return nil
+		})
+		if err != nil && !errors.Is(err, errOtherWorkspace) && !errors.Is(err, apperr.ErrLinkNotFound) {
+			return err
+		}
+	}
+	return nil
+}
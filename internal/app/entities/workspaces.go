@@ -0,0 +1,181 @@
+package entities
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/BazNick/shortlink/internal/app/apperr"
+	"github.com/BazNick/shortlink/internal/app/storage"
+)
+
+type (
+	// Workspaces — пространства и их участники в памяти, в файловом режиме
+	// изменения дописываются в отдельный файл, как у пользователей.
+	Workspaces struct {
+		mu         sync.RWMutex
+		workspaces map[string]storage.Workspace
+		// участники по пространству и пользователю
+		members map[string]map[string]storage.Member
+		log     *recordLog[workspaceRecord]
+	}
+
+	// workspaceRecord — строка журнала: пространство, участник или
+	// удаление участника
+	workspaceRecord struct {
+		Workspace *storage.Workspace `json:"workspace,omitempty"`
+		Member    *storage.Member    `json:"member,omitempty"`
+		Removed   bool               `json:"removed,omitempty"`
+	}
+)
+
+func NewWorkspaces(path string) (*Workspaces, error) {
+	w := &Workspaces{
+		workspaces: make(map[string]storage.Workspace),
+		members:    make(map[string]map[string]storage.Member),
+		log:        newCompactingLog(path, workspaceRecord.key),
+	}
+
+	err := w.log.load(func(rec workspaceRecord) {
+		switch {
+		case rec.Workspace != nil:
+			w.workspaces[rec.Workspace.ID] = *rec.Workspace
+		case rec.Member != nil && rec.Removed:
+			delete(w.members[rec.Member.WorkspaceID], rec.Member.UserID)
+		case rec.Member != nil:
+			w.putMember(*rec.Member)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return w, nil
+}
+
+// key — пространство или участник, к которому относится запись.
+func (rec workspaceRecord) key() (string, bool) {
+	if rec.Workspace != nil {
+		return "workspace:" + rec.Workspace.ID, true
+	}
+	return "member:" + rec.Member.WorkspaceID + "/" + rec.Member.UserID, !rec.Removed
+}
+
+func (w *Workspaces) AddWorkspace(ws storage.Workspace, owner storage.Member) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	owner.WorkspaceID = ws.ID
+	if err := w.log.append(workspaceRecord{Workspace: &ws}); err != nil {
+		return err
+	}
+	if err := w.log.append(workspaceRecord{Member: &owner}); err != nil {
+		return err
+	}
+
+	w.workspaces[ws.ID] = ws
+	w.putMember(owner)
+
+	return nil
+}
+
+func (w *Workspaces) Workspace(id string) (storage.Workspace, error) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	ws, ok := w.workspaces[id]
+	if !ok {
+		return storage.Workspace{}, apperr.ErrWorkspaceNotFound
+	}
+	return ws, nil
+}
+
+func (w *Workspaces) UserWorkspaces(userID string) ([]storage.Member, error) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	var result []storage.Member
+	for _, members := range w.members {
+		if m, ok := members[userID]; ok {
+			result = append(result, m)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].AddedAt.Before(result[j].AddedAt)
+	})
+
+	return result, nil
+}
+
+func (w *Workspaces) Member(workspaceID, userID string) (storage.Member, error) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	m, ok := w.members[workspaceID][userID]
+	if !ok {
+		return storage.Member{}, apperr.ErrMemberNotFound
+	}
+	return m, nil
+}
+
+func (w *Workspaces) Members(workspaceID string) ([]storage.Member, error) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	if _, ok := w.workspaces[workspaceID]; !ok {
+		return nil, apperr.ErrWorkspaceNotFound
+	}
+
+	result := make([]storage.Member, 0, len(w.members[workspaceID]))
+	for _, m := range w.members[workspaceID] {
+		result = append(result, m)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].AddedAt.Before(result[j].AddedAt)
+	})
+
+	return result, nil
+}
+
+func (w *Workspaces) PutMember(m storage.Member) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if _, ok := w.workspaces[m.WorkspaceID]; !ok {
+		return apperr.ErrWorkspaceNotFound
+	}
+	// при смене роли время добавления остаётся прежним
+	if existing, ok := w.members[m.WorkspaceID][m.UserID]; ok {
+		m.AddedAt = existing.AddedAt
+	}
+
+	if err := w.log.append(workspaceRecord{Member: &m}); err != nil {
+		return err
+	}
+	w.putMember(m)
+
+	return nil
+}
+
+func (w *Workspaces) RemoveMember(workspaceID, userID string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	m, ok := w.members[workspaceID][userID]
+	if !ok {
+		return apperr.ErrMemberNotFound
+	}
+
+	if err := w.log.append(workspaceRecord{Member: &m, Removed: true}); err != nil {
+		return err
+	}
+	delete(w.members[workspaceID], userID)
+
+	return nil
+}
+
+func (w *Workspaces) putMember(m storage.Member) {
+	if w.members[m.WorkspaceID] == nil {
+		w.members[m.WorkspaceID] = make(map[string]storage.Member)
+	}
+	w.members[m.WorkspaceID][m.UserID] = m
+}
@@ -0,0 +1,22 @@
+package functions
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+const APIKeyPrefix = "slk_"
+
+// NewAPIKey выдаёт идентификатор ключа и сам ключ. Идентификатор входит
+// в ключ, чтобы пользователь мог узнать его в списке.
+func NewAPIKey() (id, key string) {
+	id = RandSeq(8)
+	return id, APIKeyPrefix + id + "_" + RandSeq(64)
+}
+
+// HashAPIKey — в хранилище лежит только этот хэш. Ключ случайный и
+// длинный, поэтому медленный хэш ему не нужен.
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,17 @@
+package functions
+
+import "golang.org/x/crypto/bcrypt"
+
+// HashPassword — пароли, в отличие от API-ключей, короткие и угадываемые,
+// поэтому хэшируются медленно.
+func HashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+func CheckPassword(hash, password string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
@@ -0,0 +1,297 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/BazNick/shortlink/cmd/middleware/auth"
+	"github.com/BazNick/shortlink/internal/app/apperr"
+	"github.com/BazNick/shortlink/internal/app/audit"
+	"github.com/BazNick/shortlink/internal/app/functions"
+	"github.com/BazNick/shortlink/internal/app/storage"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	minLoginLen    = 3
+	maxLoginLen    = 64
+	minPasswordLen = 8
+	// bcrypt не смотрит дальше 72 байт
+	maxPasswordLen = 72
+
+	oidcLoginPrefix = "oidc:"
+
+	// dummyPasswordHash сверяется вместо хеша несуществующего аккаунта,
+	// чтобы по времени ответа нельзя было узнать, есть ли такой логин
+	dummyPasswordHash = "$2a$10$aWMX.gj7oMAliaBT0nLbs.swuQLdALYTojDUrhFyOoDcY/2.8uH.S"
+)
+
+type (
+	AccountHandler struct {
+		users    storage.UserStore
+		links    storage.Storage
+		sessions *auth.Sessions
+		// логины, которые при входе получают роль администратора
+		admins map[string]bool
+		audit  *audit.Log
+	}
+
+	Credentials struct {
+		Login    string `json:"login"`
+		Password string `json:"password"`
+	}
+
+	AccountOut struct {
+		UserID string `json:"user_id"`
+		Login  string `json:"login"`
+		Role   string `json:"role,omitempty"`
+		// сколько анонимных ссылок перешло к аккаунту при входе
+		Claimed int `json:"claimed"`
+		auth.TokenResponse
+	}
+)
+
+func NewAccountHandler(
+	users storage.UserStore,
+	links storage.Storage,
+	sessions *auth.Sessions,
+	admins []string,
+	log *audit.Log,
+) *AccountHandler {
+	handler := &AccountHandler{
+		users:    users,
+		links:    links,
+		sessions: sessions,
+		admins:   make(map[string]bool, len(admins)),
+		audit:    log,
+	}
+
+	for _, login := range admins {
+		if login = strings.ToLower(strings.TrimSpace(login)); login != "" {
+			handler.admins[login] = true
+		}
+	}
+
+	return handler
+}
+
+func (handler *AccountHandler) Register(c *gin.Context) {
+	creds, ok := readCredentials(c)
+	if !ok {
+		return
+	}
+
+	if n := utf8.RuneCountInString(creds.Login); n < minLoginLen || n > maxLoginLen {
+		http.Error(c.Writer, "login must be 3 to 64 characters", http.StatusBadRequest)
+		return
+	}
+	// логины вида oidc:<sub> заняты под пользователей провайдера
+	if strings.Contains(creds.Login, ":") {
+		http.Error(c.Writer, "login must not contain ':'", http.StatusBadRequest)
+		return
+	}
+	if n := len(creds.Password); n < minPasswordLen || n > maxPasswordLen {
+		http.Error(c.Writer, "password must be 8 to 72 bytes", http.StatusBadRequest)
+		return
+	}
+
+	id, err := auth.NewUserID()
+	if err != nil {
+		http.Error(c.Writer, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	hash, err := functions.HashPassword(creds.Password)
+	if err != nil {
+		http.Error(c.Writer, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	user := storage.User{
+		ID:           id,
+		Login:        creds.Login,
+		PasswordHash: hash,
+		CreatedAt:    time.Now(),
+	}
+
+	err = handler.users.AddUser(user)
+	if errors.Is(err, apperr.ErrUserExists) {
+		http.Error(c.Writer, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(c.Writer, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	handler.signIn(c, user, http.StatusCreated)
+}
+
+func (handler *AccountHandler) Login(c *gin.Context) {
+	creds, ok := readCredentials(c)
+	if !ok {
+		return
+	}
+
+	user, err := handler.users.UserByLogin(creds.Login)
+	if err != nil && !errors.Is(err, apperr.ErrUserNotFound) {
+		http.Error(c.Writer, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// у аккаунтов провайдера пароля нет
+	hash := user.PasswordHash
+	if hash == "" {
+		hash = dummyPasswordHash
+	}
+	valid := functions.CheckPassword(hash, creds.Password)
+
+	// не говорим, что именно не совпало — логин или пароль
+	if err != nil || user.PasswordHash == "" || !valid {
+		http.Error(c.Writer, "invalid login or password", http.StatusUnauthorized)
+		return
+	}
+
+	handler.signIn(c, user, http.StatusOK)
+}
+
+// signIn забирает ссылки анонимного пользователя, с которым пришёл запрос,
+// и начинает сессию аккаунта. Ссылки забираются при каждом входе, а не
+// только при первом: всё, что браузер сократил анонимно между входами,
+// тоже переходит к аккаунту.
+func (handler *AccountHandler) signIn(c *gin.Context, user storage.User, status int) {
+	// роль администратора даёт только ADMIN_LOGINS: убранный из списка
+	// логин теряет её при следующем входе
+	role := handler.role(user)
+	if user.Role != role {
+		if err := handler.users.SetUserRole(user.ID, role); err != nil {
+			http.Error(c.Writer, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		user.Role = role
+	}
+
+	claimed, err := handler.claim(c, user.ID)
+	if err != nil {
+		http.Error(c.Writer, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	tokens, err := handler.sessions.Start(c, user.ID, user.Role)
+	if err != nil {
+		http.Error(c.Writer, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	resp, err := json.Marshal(AccountOut{
+		UserID:        user.ID,
+		Login:         user.Login,
+		Role:          user.Role,
+		Claimed:       claimed,
+		TokenResponse: tokens,
+	})
+	if err != nil {
+		http.Error(c.Writer, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	c.Writer.Header().Set("content-type", "application/json")
+	c.Writer.WriteHeader(status)
+	c.Writer.Write(resp)
+}
+
+// Role — роль пользователя по текущему ADMIN_LOGINS, для
+// auth.RequireRole: убранный из списка администратор теряет доступ сразу,
+// даже с ещё действующим токеном.
+func (handler *AccountHandler) Role(userID string) (string, error) {
+	user, err := handler.users.UserByID(userID)
+	if errors.Is(err, apperr.ErrUserNotFound) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return handler.role(user), nil
+}
+
+func (handler *AccountHandler) role(user storage.User) string {
+	if handler.admins[strings.ToLower(user.Login)] {
+		return storage.RoleAdmin
+	}
+	return ""
+}
+
+func (handler *AccountHandler) claim(c *gin.Context, accountID string) (int, error) {
+	anonymous, ok := handler.sessions.Current(c)
+	if !ok || anonymous == accountID {
+		return 0, nil
+	}
+
+	// ссылки другого зарегистрированного пользователя не забираем
+	_, err := handler.users.UserByID(anonymous)
+	if err == nil {
+		return 0, nil
+	}
+	if !errors.Is(err, apperr.ErrUserNotFound) {
+		return 0, err
+	}
+
+	n, err := handler.links.ReassignLinks(anonymous, accountID)
+	if err != nil || n == 0 {
+		return n, err
+	}
+
+	src := audit.Source(c)
+	src.ActorID = accountID
+	handler.audit.Add(src, storage.AuditClaim, nil, nil, fmt.Sprintf("%d links of %s", n, anonymous))
+
+	return n, nil
+}
+
+// subjectUser находит пользователя провайдера по sub или заводит нового.
+func (handler *AccountHandler) subjectUser(subject string) (storage.User, error) {
+	user, err := handler.users.UserBySubject(subject)
+	if !errors.Is(err, apperr.ErrUserNotFound) {
+		return user, err
+	}
+
+	id, err := auth.NewUserID()
+	if err != nil {
+		return storage.User{}, err
+	}
+
+	user = storage.User{
+		ID:        id,
+		Login:     oidcLoginPrefix + subject,
+		Subject:   subject,
+		CreatedAt: time.Now(),
+	}
+
+	err = handler.users.AddUser(user)
+	if errors.Is(err, apperr.ErrUserExists) {
+		// параллельный вход того же пользователя успел его завести
+		return handler.users.UserBySubject(subject)
+	}
+	return user, err
+}
+
+func readCredentials(c *gin.Context) (Credentials, bool) {
+	var creds Credentials
+	if err := json.NewDecoder(c.Request.Body).Decode(&creds); err != nil {
+		http.Error(c.Writer, err.Error(), http.StatusBadRequest)
+		return Credentials{}, false
+	}
+
+	creds.Login = strings.ToLower(strings.TrimSpace(creds.Login))
+	if creds.Login == "" || creds.Password == "" {
+		http.Error(c.Writer, "login and password are required", http.StatusBadRequest)
+		return Credentials{}, false
+	}
+
+	return creds, true
+}
@@ -0,0 +1,145 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/BazNick/shortlink/cmd/middleware/auth"
+	"github.com/BazNick/shortlink/internal/app/entities"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestAccounts(t *testing.T) {
+	secret := "secret_key"
+
+	users, err := entities.NewUsers("")
+	require.NoError(t, err)
+	keyring, err := auth.LoadKeyring(secret, "", "")
+	require.NoError(t, err)
+
+	var (
+		store      = entities.NewHashDict()
+		handler    = NewAccountHandler(users, store, auth.NewSessions(keyring), nil, nil)
+		urlHandler = NewURLHandler(store, "test.json", "")
+	)
+
+	router := gin.Default()
+	router.POST("/api/auth/register", handler.Register)
+	router.POST("/api/auth/login", handler.Login)
+
+	linkRoutes := router.Group("", auth.Auth(secret))
+	linkRoutes.POST("/", urlHandler.AddLink)
+	linkRoutes.GET("/api/user/urls", auth.Required(), urlHandler.GetUserLinks)
+
+	do := func(method, target, body, token string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(method, "http://localhost:8080"+target, strings.NewReader(body))
+		if token != "" {
+			request.Header.Set(auth.HeaderName, auth.BearerPrefix+token)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, request)
+		return w
+	}
+
+	// анонимный пользователь в двух браузерах
+	anonymous := func(link string) string {
+		w := do(http.MethodPost, "/", link, "")
+		require.Equal(t, http.StatusCreated, w.Code)
+		return strings.TrimPrefix(w.Header().Get(auth.HeaderName), auth.BearerPrefix)
+	}
+	first := anonymous("https://first.ru")
+	second := anonymous("https://second.ru")
+
+	tests := []struct {
+		name         string
+		target       string
+		body         string
+		token        string
+		expectedCode int
+		claimed      int
+	}{
+		{
+			name:         "Register claims links of the anonymous user",
+			target:       "/api/auth/register",
+			body:         `{"login":"Alice","password":"correct horse"}`,
+			token:        first,
+			expectedCode: http.StatusCreated,
+			claimed:      1,
+		},
+		{
+			name:         "Login is taken",
+			target:       "/api/auth/register",
+			body:         `{"login":"alice ","password":"other password"}`,
+			expectedCode: http.StatusConflict,
+		},
+		{
+			name:         "Short password",
+			target:       "/api/auth/register",
+			body:         `{"login":"bob","password":"short"}`,
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "Wrong password",
+			target:       "/api/auth/login",
+			body:         `{"login":"alice","password":"wrong password"}`,
+			token:        second,
+			expectedCode: http.StatusUnauthorized,
+		},
+		{
+			name:         "Unknown login",
+			target:       "/api/auth/login",
+			body:         `{"login":"carol","password":"correct horse"}`,
+			expectedCode: http.StatusUnauthorized,
+		},
+		{
+			name:         "Login from another browser claims its links",
+			target:       "/api/auth/login",
+			body:         `{"login":"alice","password":"correct horse"}`,
+			token:        second,
+			expectedCode: http.StatusOK,
+			claimed:      1,
+		},
+	}
+
+	var account AccountOut
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := do(http.MethodPost, tt.target, tt.body, tt.token)
+			require.Equal(t, tt.expectedCode, w.Code)
+			if tt.expectedCode >= http.StatusBadRequest {
+				return
+			}
+
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &account))
+			assert.Equal(t, "alice", account.Login)
+			assert.Equal(t, tt.claimed, account.Claimed)
+			assert.NotEmpty(t, account.AccessToken)
+		})
+	}
+
+	w := do(http.MethodGet, "/api/user/urls", "", account.AccessToken)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var links []map[string]any
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &links))
+	assert.Len(t, links, 2)
+
+	// повторный вход под аккаунтом ничего не переносит
+	w = do(http.MethodPost, "/api/auth/login", `{"login":"alice","password":"correct horse"}`, account.AccessToken)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &account))
+	assert.Zero(t, account.Claimed)
+}
+
+func TestDummyPasswordHash(t *testing.T) {
+	// заглушка должна стоить столько же, сколько настоящий хеш
+	cost, err := bcrypt.Cost([]byte(dummyPasswordHash))
+	require.NoError(t, err)
+	assert.Equal(t, bcrypt.DefaultCost, cost)
+}
@@ -5,8 +5,8 @@ import (
 	"net/http"
 
 	"github.com/BazNick/shortlink/internal/app/apperr"
-	"github.com/BazNick/shortlink/internal/app/entities"
 	"github.com/BazNick/shortlink/internal/app/functions"
+	"github.com/BazNick/shortlink/internal/app/storage"
 	"github.com/gin-gonic/gin"
 )
 
@@ -28,21 +28,37 @@ func (handler *URLHandler) PostJSONLink(c *gin.Context) {
 		return
 	}
 
-	if _, ok := handler.storage.(*entities.DB); !ok {
-		alreadyExst := handler.storage.CheckValExists(link.Link)
+	original, ok := normalizeLink(c, handler.urls, link.Link)
+	if !ok || !checkSafety(c, handler.safety, original) {
+		return
+	}
+
+	meta, ok := linkMetadata(c, link.LinkMetadata)
+	if !ok {
+		return
+	}
+
+	if handler.db == nil {
+		alreadyExst := handler.storage.CheckValExists(user, original)
 		if alreadyExst {
 			http.Error(c.Writer, apperr.ErrLinkExists.Error(), http.StatusBadRequest)
 			return
 		}
 	}
 
+	reservation, ok := reserveQuota(c, handler.quota, user, 1)
+	if !ok {
+		return
+	}
+
 	var (
 		randStr  = functions.RandSeq(8)
 		hashLink = functions.SchemeAndHost(c.Request) + "/" + randStr
 	)
 
-	shortURL, err := handler.storage.AddHash(randStr, link.Link, user)
+	shortURL, err := handler.storage.AddHash(randStr, original, user)
 	if err != nil {
+		reservation.Done(0)
 		if err.Error() == "conflict" {
 			resp, err := json.Marshal(map[string]string{
 				"result": functions.SchemeAndHost(c.Request) + "/" + shortURL,
@@ -60,6 +76,24 @@ func (handler *URLHandler) PostJSONLink(c *gin.Context) {
 		http.Error(c.Writer, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	reservation.Done(1)
+
+	created := storage.Link{
+		ShortURL:    randStr,
+		OriginalURL: original,
+		UserID:      user,
+	}
+	if !meta.empty() {
+		created, err = handler.storage.UpdateLink(randStr, func(l *storage.Link) error {
+			meta.apply(l)
+			return nil
+		})
+		if err != nil {
+			http.Error(c.Writer, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	handler.audit.Record(c, storage.AuditCreate, nil, &created)
 
 	resp, err := json.Marshal(map[string]string{"result": hashLink})
 	if err != nil {
@@ -10,6 +10,7 @@ import (
 
 	"github.com/BazNick/shortlink/cmd/middleware/auth"
 	"github.com/BazNick/shortlink/internal/app/entities"
+	"github.com/BazNick/shortlink/internal/app/urlnorm"
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -31,6 +32,7 @@ func TestPostJSONLink(t *testing.T) {
 		body         string
 		expectedCode int
 		expectResult bool
+		reason       string
 	}
 	tests := []struct {
 		name string
@@ -72,6 +74,33 @@ func TestPostJSONLink(t *testing.T) {
 				expectResult: false,
 			},
 		},
+		{
+			name: "Test POST fail (same URL spelled differently)",
+			want: want{
+				method:       http.MethodPost,
+				body:         `{"url": " HTTPS://Duplicate1.RU\n"}`,
+				expectedCode: http.StatusBadRequest,
+				expectResult: false,
+			},
+		},
+		{
+			name: "Test POST fail (javascript URL)",
+			want: want{
+				method:       http.MethodPost,
+				body:         `{"url": "javascript:alert(1)"}`,
+				expectedCode: http.StatusBadRequest,
+				reason:       "scheme",
+			},
+		},
+		{
+			name: "Test POST fail (empty URL)",
+			want: want{
+				method:       http.MethodPost,
+				body:         `{"url": "  "}`,
+				expectedCode: http.StatusBadRequest,
+				reason:       "empty",
+			},
+		},
 	}
 
 	for _, test := range tests {
@@ -103,6 +132,12 @@ func TestPostJSONLink(t *testing.T) {
 				assert.Contains(t, resBody["result"], "http://localhost:8080/")
 			}
 
+			if test.want.reason != "" {
+				var urlErr urlnorm.Error
+				require.NoError(t, json.NewDecoder(res.Body).Decode(&urlErr))
+				assert.Equal(t, test.want.reason, urlErr.Reason)
+			}
+
 		})
 	}
 }
@@ -5,8 +5,8 @@ import (
 	"net/http"
 
 	"github.com/BazNick/shortlink/internal/app/apperr"
-	"github.com/BazNick/shortlink/internal/app/entities"
 	"github.com/BazNick/shortlink/internal/app/functions"
+	"github.com/BazNick/shortlink/internal/app/storage"
 	"github.com/gin-gonic/gin"
 )
 
@@ -29,21 +29,32 @@ func (handler *URLHandler) AddLink(c *gin.Context) {
 	}
 	defer c.Request.Body.Close()
 
-	if _, ok := handler.storage.(*entities.DB); !ok {
-		alreadyExst := handler.storage.CheckValExists(string(body))
+	link, ok := normalizeLink(c, handler.urls, string(body))
+	if !ok || !checkSafety(c, handler.safety, link) {
+		return
+	}
+
+	if handler.db == nil {
+		alreadyExst := handler.storage.CheckValExists(user, link)
 		if alreadyExst {
 			http.Error(c.Writer, apperr.ErrLinkExists.Error(), http.StatusBadRequest)
 			return
 		}
 	}
 
+	reservation, ok := reserveQuota(c, handler.quota, user, 1)
+	if !ok {
+		return
+	}
+
 	var (
 		randStr  = functions.RandSeq(8)
 		hashLink = functions.SchemeAndHost(c.Request) + "/" + randStr
 	)
 
-	shortURL, err := handler.storage.AddHash(randStr, string(body), user)
+	shortURL, err := handler.storage.AddHash(randStr, link, user)
 	if err != nil {
+		reservation.Done(0)
 		if err.Error() == apperr.ErrValAlreadyExists.Error() {
 			c.Writer.WriteHeader(http.StatusConflict)
 			c.Writer.Write([]byte(functions.SchemeAndHost(c.Request) + "/" + shortURL))
@@ -52,6 +63,13 @@ func (handler *URLHandler) AddLink(c *gin.Context) {
 		http.Error(c.Writer, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	reservation.Done(1)
+
+	handler.audit.Record(c, storage.AuditCreate, nil, &storage.Link{
+		ShortURL:    randStr,
+		OriginalURL: link,
+		UserID:      user,
+	})
 
 	c.Writer.Header().Set("content-type", "text/plain")
 	c.Writer.WriteHeader(http.StatusCreated)
@@ -0,0 +1,248 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/BazNick/shortlink/internal/app/apperr"
+	"github.com/BazNick/shortlink/internal/app/audit"
+	"github.com/BazNick/shortlink/internal/app/storage"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	adminDefaultLimit = 100
+	adminMaxLimit     = 1000
+)
+
+// errLimit останавливает обход хранилища, когда набрано достаточно записей
+var errLimit = errors.New("limit reached")
+
+type (
+	// AdminHandler — управление чужими ссылками. Работает поверх общего
+	// интерфейса хранилища, поэтому одинаково для всех бэкендов.
+	AdminHandler struct {
+		links storage.Storage
+		users storage.UserStore
+		audit *audit.Log
+	}
+
+	OwnerIn struct {
+		UserID string `json:"user_id"`
+		Login  string `json:"login"`
+	}
+
+	UserLinkCount struct {
+		UserID   string `json:"user_id"`
+		Login    string `json:"login,omitempty"`
+		Links    int    `json:"links"`
+		Active   int    `json:"active"`
+		Deleted  int    `json:"deleted"`
+		Disabled int    `json:"disabled"`
+	}
+)
+
+func NewAdminHandler(links storage.Storage, users storage.UserStore, log *audit.Log) *AdminHandler {
+	return &AdminHandler{
+		links: links,
+		users: users,
+		audit: log,
+	}
+}
+
+// SearchLinks ищет ссылки по коду (?code=), части исходного адреса (?url=)
+// и владельцу (?owner= — UserID или логин). Условия складываются.
+func (handler *AdminHandler) SearchLinks(c *gin.Context) {
+	limit, ok := adminLimit(c)
+	if !ok {
+		return
+	}
+
+	var (
+		// вместо кода можно вставить короткую ссылку целиком
+		code  = shortCodeFrom(c.Query("code"))
+		url   = strings.ToLower(c.Query("url"))
+		owner = c.Query("owner")
+	)
+	if owner != "" {
+		if user, err := handler.users.UserByLogin(strings.ToLower(owner)); err == nil {
+			owner = user.ID
+		}
+	}
+
+	result := []storage.Link{}
+
+	err := handler.links.Links(func(link storage.Link) error {
+		switch {
+		case code != "" && link.ShortURL != code:
+			return nil
+		case url != "" && !strings.Contains(strings.ToLower(link.OriginalURL), url):
+			return nil
+		case owner != "" && link.UserID != owner:
+			return nil
+		}
+
+		result = append(result, link)
+		if len(result) >= limit {
+			return errLimit
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errLimit) {
+		http.Error(c.Writer, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(c, http.StatusOK, result)
+}
+
+func (handler *AdminHandler) DisableLink(c *gin.Context) {
+	handler.updateLink(c, storage.AuditDisable, func(link *storage.Link) error {
+		link.IsDisabled = true
+		return nil
+	})
+}
+
+func (handler *AdminHandler) EnableLink(c *gin.Context) {
+	handler.updateLink(c, storage.AuditEnable, func(link *storage.Link) error {
+		link.IsDisabled = false
+		return nil
+	})
+}
+
+// SetOwner передаёт ссылку другому пользователю, заданному UserID или
+// логином.
+func (handler *AdminHandler) SetOwner(c *gin.Context) {
+	var in OwnerIn
+	if err := json.NewDecoder(c.Request.Body).Decode(&in); err != nil {
+		http.Error(c.Writer, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if in.Login != "" {
+		user, err := handler.users.UserByLogin(strings.ToLower(strings.TrimSpace(in.Login)))
+		if errors.Is(err, apperr.ErrUserNotFound) {
+			http.Error(c.Writer, err.Error(), http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(c.Writer, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		in.UserID = user.ID
+	}
+
+	if in.UserID == "" {
+		http.Error(c.Writer, "user_id or login is required", http.StatusBadRequest)
+		return
+	}
+
+	handler.updateLink(c, storage.AuditOwner, func(link *storage.Link) error {
+		link.UserID = in.UserID
+		return nil
+	})
+}
+
+// UserCounts — сколько ссылок у каждого пользователя, больше всего сверху.
+func (handler *AdminHandler) UserCounts(c *gin.Context) {
+	limit, ok := adminLimit(c)
+	if !ok {
+		return
+	}
+
+	counts := make(map[string]*UserLinkCount)
+	err := handler.links.Links(func(link storage.Link) error {
+		count, ok := counts[link.UserID]
+		if !ok {
+			count = &UserLinkCount{UserID: link.UserID}
+			counts[link.UserID] = count
+		}
+
+		count.Links++
+		switch {
+		case link.IsDeleted:
+			count.Deleted++
+		case link.IsDisabled:
+			count.Disabled++
+		default:
+			count.Active++
+		}
+		return nil
+	})
+	if err != nil {
+		http.Error(c.Writer, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	result := make([]UserLinkCount, 0, len(counts))
+	for _, count := range counts {
+		result = append(result, *count)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Links != result[j].Links {
+			return result[i].Links > result[j].Links
+		}
+		return result[i].UserID < result[j].UserID
+	})
+	if len(result) > limit {
+		result = result[:limit]
+	}
+
+	// логины ищем только для тех, кто попал в ответ
+	for i := range result {
+		if user, err := handler.users.UserByID(result[i].UserID); err == nil {
+			result[i].Login = user.Login
+		}
+	}
+
+	writeJSON(c, http.StatusOK, result)
+}
+
+func (handler *AdminHandler) updateLink(c *gin.Context, action string, fn func(link *storage.Link) error) {
+	var before storage.Link
+	link, err := handler.links.UpdateLink(c.Param("id"), func(link *storage.Link) error {
+		before = *link
+		return fn(link)
+	})
+	if errors.Is(err, apperr.ErrLinkNotFound) {
+		http.Error(c.Writer, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(c.Writer, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	handler.audit.Record(c, action, &before, &link)
+
+	writeJSON(c, http.StatusOK, link)
+}
+
+func adminLimit(c *gin.Context) (int, bool) {
+	raw := c.Query("limit")
+	if raw == "" {
+		return adminDefaultLimit, true
+	}
+
+	limit, err := strconv.Atoi(raw)
+	if err != nil || limit <= 0 || limit > adminMaxLimit {
+		http.Error(c.Writer, "limit must be between 1 and 1000", http.StatusBadRequest)
+		return 0, false
+	}
+	return limit, true
+}
+
+func writeJSON(c *gin.Context, status int, v any) {
+	resp, err := json.Marshal(v)
+	if err != nil {
+		http.Error(c.Writer, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	c.Writer.Header().Set("content-type", "application/json")
+	c.Writer.WriteHeader(status)
+	c.Writer.Write(resp)
+}
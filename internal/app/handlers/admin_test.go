@@ -0,0 +1,300 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/BazNick/shortlink/cmd/middleware/auth"
+	"github.com/BazNick/shortlink/internal/app/audit"
+	"github.com/BazNick/shortlink/internal/app/entities"
+	"github.com/BazNick/shortlink/internal/app/storage"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdmin(t *testing.T) {
+	secret := "secret_key"
+
+	users, err := entities.NewUsers("")
+	require.NoError(t, err)
+	keyring, err := auth.LoadKeyring(secret, "", "")
+	require.NoError(t, err)
+	entries, err := entities.NewAuditLog("")
+	require.NoError(t, err)
+	auditLog := audit.New(entries)
+
+	store := entities.NewHashDict()
+	store.AddHash("abusive1", "https://phishing.example.com/login", "anon")
+	store.AddHash("good0001", "https://ya.ru", "anon")
+	store.AddHash("good0002", "https://go.dev", "other")
+
+	var (
+		accounts   = NewAccountHandler(users, store, auth.NewSessions(keyring), []string{"Root"}, auditLog)
+		admin      = NewAdminHandler(store, users, auditLog)
+		urlHandler = NewURLHandler(store, "test.json", "")
+	)
+
+	router := gin.Default()
+	router.POST("/api/auth/register", accounts.Register)
+	router.Use(auth.Middleware(keyring))
+	router.GET("/:id", urlHandler.GetLink)
+	group := router.Group("/api/admin", auth.RequireRole(storage.RoleAdmin, accounts.Role))
+	group.GET("/links", admin.SearchLinks)
+	group.POST("/links/:id/disable", admin.DisableLink)
+	group.POST("/links/:id/enable", admin.EnableLink)
+	group.POST("/links/:id/owner", admin.SetOwner)
+	group.GET("/users", admin.UserCounts)
+	group.GET("/audit", admin.SearchAudit)
+	group.GET("/audit/export", admin.ExportAudit)
+
+	do := func(method, target, body, token string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(method, "http://localhost:8080"+target, strings.NewReader(body))
+		if token != "" {
+			request.Header.Set(auth.HeaderName, auth.BearerPrefix+token)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, request)
+		return w
+	}
+	register := func(login string) AccountOut {
+		w := do(http.MethodPost, "/api/auth/register", `{"login":"`+login+`","password":"long enough"}`, "")
+		require.Equal(t, http.StatusCreated, w.Code)
+
+		var account AccountOut
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &account))
+		return account
+	}
+
+	root := register("root")
+	require.Equal(t, storage.RoleAdmin, root.Role)
+	user := register("user")
+	require.Empty(t, user.Role)
+
+	tests := []struct {
+		name         string
+		method       string
+		target       string
+		body         string
+		token        string
+		expectedCode int
+		check        func(t *testing.T, body []byte)
+	}{
+		{
+			name:         "Regular user is forbidden",
+			method:       http.MethodGet,
+			target:       "/api/admin/links?url=phishing",
+			token:        user.AccessToken,
+			expectedCode: http.StatusForbidden,
+		},
+		{
+			name:         "Search by original URL",
+			method:       http.MethodGet,
+			target:       "/api/admin/links?url=PHISHING",
+			token:        root.AccessToken,
+			expectedCode: http.StatusOK,
+			check: func(t *testing.T, body []byte) {
+				var links []storage.Link
+				require.NoError(t, json.Unmarshal(body, &links))
+				require.Len(t, links, 1)
+				assert.Equal(t, "abusive1", links[0].ShortURL)
+			},
+		},
+		{
+			name:         "Search by full short URL and owner",
+			method:       http.MethodGet,
+			target:       "/api/admin/links?code=http://localhost:8080/good0001&owner=anon",
+			token:        root.AccessToken,
+			expectedCode: http.StatusOK,
+			check: func(t *testing.T, body []byte) {
+				var links []storage.Link
+				require.NoError(t, json.Unmarshal(body, &links))
+				require.Len(t, links, 1)
+				assert.Equal(t, "https://ya.ru", links[0].OriginalURL)
+			},
+		},
+		{
+			name:         "Disable link",
+			method:       http.MethodPost,
+			target:       "/api/admin/links/abusive1/disable",
+			token:        root.AccessToken,
+			expectedCode: http.StatusOK,
+			check: func(t *testing.T, body []byte) {
+				assert.Equal(t, http.StatusGone, do(http.MethodGet, "/abusive1", "", "").Code)
+			},
+		},
+		{
+			name:         "Disable unknown link",
+			method:       http.MethodPost,
+			target:       "/api/admin/links/nope/disable",
+			token:        root.AccessToken,
+			expectedCode: http.StatusNotFound,
+		},
+		{
+			name:         "Reassign by login",
+			method:       http.MethodPost,
+			target:       "/api/admin/links/good0001/owner",
+			body:         `{"login":"user"}`,
+			token:        root.AccessToken,
+			expectedCode: http.StatusOK,
+			check: func(t *testing.T, body []byte) {
+				var link storage.Link
+				require.NoError(t, json.Unmarshal(body, &link))
+				assert.Equal(t, user.UserID, link.UserID)
+			},
+		},
+		{
+			name:         "Per-user counts",
+			method:       http.MethodGet,
+			target:       "/api/admin/users",
+			token:        root.AccessToken,
+			expectedCode: http.StatusOK,
+			check: func(t *testing.T, body []byte) {
+				var counts []UserLinkCount
+				require.NoError(t, json.Unmarshal(body, &counts))
+				require.Len(t, counts, 3)
+
+				byUser := make(map[string]UserLinkCount)
+				for _, count := range counts {
+					byUser[count.UserID] = count
+				}
+				assert.Equal(t, UserLinkCount{UserID: "anon", Links: 1, Disabled: 1}, byUser["anon"])
+				assert.Equal(t, UserLinkCount{UserID: user.UserID, Login: "user", Links: 1, Active: 1}, byUser[user.UserID])
+			},
+		},
+		{
+			name:         "Enable link",
+			method:       http.MethodPost,
+			target:       "/api/admin/links/abusive1/enable",
+			token:        root.AccessToken,
+			expectedCode: http.StatusOK,
+			check: func(t *testing.T, body []byte) {
+				assert.Equal(t, http.StatusTemporaryRedirect, do(http.MethodGet, "/abusive1", "", "").Code)
+			},
+		},
+		{
+			name:         "Audit of a link",
+			method:       http.MethodGet,
+			target:       "/api/admin/audit?code=abusive1&actor=root",
+			token:        root.AccessToken,
+			expectedCode: http.StatusOK,
+			check: func(t *testing.T, body []byte) {
+				var entries []storage.AuditEntry
+				require.NoError(t, json.Unmarshal(body, &entries))
+				require.Len(t, entries, 2)
+
+				assert.Equal(t, storage.AuditEnable, entries[0].Action)
+				assert.Equal(t, storage.AuditDisable, entries[1].Action)
+				assert.Equal(t, root.UserID, entries[1].ActorID)
+				assert.NotEmpty(t, entries[1].IP)
+				assert.False(t, entries[1].Before.IsDisabled)
+				assert.True(t, entries[1].After.IsDisabled)
+			},
+		},
+		{
+			name:         "Audit filtered by action",
+			method:       http.MethodGet,
+			target:       "/api/admin/audit?action=owner",
+			token:        root.AccessToken,
+			expectedCode: http.StatusOK,
+			check: func(t *testing.T, body []byte) {
+				var entries []storage.AuditEntry
+				require.NoError(t, json.Unmarshal(body, &entries))
+				require.Len(t, entries, 1)
+				assert.Equal(t, "anon", entries[0].Before.UserID)
+				assert.Equal(t, user.UserID, entries[0].After.UserID)
+			},
+		},
+		{
+			name:         "Audit with broken time",
+			method:       http.MethodGet,
+			target:       "/api/admin/audit?since=yesterday",
+			token:        root.AccessToken,
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "Audit export",
+			method:       http.MethodGet,
+			target:       "/api/admin/audit/export?format=csv&until=2999-01-01T00:00:00Z",
+			token:        root.AccessToken,
+			expectedCode: http.StatusOK,
+			check: func(t *testing.T, body []byte) {
+				lines := strings.Split(strings.TrimSpace(string(body)), "\n")
+				require.Len(t, lines, 4)
+				assert.True(t, strings.HasPrefix(lines[0], "id,time,action"))
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := do(tt.method, tt.target, tt.body, tt.token)
+			require.Equal(t, tt.expectedCode, w.Code)
+			if tt.check != nil {
+				tt.check(t, w.Body.Bytes())
+			}
+		})
+	}
+}
+
+func TestAdminRevoked(t *testing.T) {
+	users, err := entities.NewUsers("")
+	require.NoError(t, err)
+	keyring, err := auth.LoadKeyring("secret_key", "", "")
+	require.NoError(t, err)
+
+	var (
+		store   = entities.NewHashDict()
+		before  = NewAccountHandler(users, store, auth.NewSessions(keyring), []string{"root"}, nil)
+		after   = NewAccountHandler(users, store, auth.NewSessions(keyring), nil, nil)
+		refresh = auth.Refresh(keyring, auth.WithUsers(users))
+	)
+
+	router := gin.Default()
+	router.POST("/before/register", before.Register)
+	router.POST("/after/login", after.Login)
+	router.POST("/refresh", refresh)
+	for prefix, accounts := range map[string]*AccountHandler{"/before": before, "/after": after} {
+		router.GET(prefix+"/admin",
+			auth.Middleware(keyring),
+			auth.RequireRole(storage.RoleAdmin, accounts.Role),
+			func(c *gin.Context) { c.Status(http.StatusNoContent) },
+		)
+	}
+
+	do := func(target, body string) []byte {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, target, strings.NewReader(body)))
+		require.Less(t, w.Code, http.StatusBadRequest, w.Body.String())
+		return w.Body.Bytes()
+	}
+
+	var root AccountOut
+	require.NoError(t, json.Unmarshal(do("/before/register", `{"login":"root","password":"long enough"}`), &root))
+	require.Equal(t, storage.RoleAdmin, root.Role)
+
+	// токен с ролью администратора не пускает, если логина уже нет в списке
+	admin := func(prefix string) int {
+		request := httptest.NewRequest(http.MethodGet, prefix+"/admin", nil)
+		request.Header.Set(auth.HeaderName, auth.BearerPrefix+root.AccessToken)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, request)
+		return w.Code
+	}
+	assert.Equal(t, http.StatusNoContent, admin("/before"))
+	assert.Equal(t, http.StatusForbidden, admin("/after"))
+
+	// логин убрали из ADMIN_LOGINS: прежняя сессия при обновлении теряет роль
+	var login AccountOut
+	require.NoError(t, json.Unmarshal(do("/after/login", `{"login":"root","password":"long enough"}`), &login))
+	assert.Empty(t, login.Role)
+
+	var tokens auth.TokenResponse
+	require.NoError(t, json.Unmarshal(do("/refresh", `{"refresh_token":"`+root.RefreshToken+`"}`), &tokens))
+	claims, err := keyring.Parse(tokens.AccessToken)
+	require.NoError(t, err)
+	assert.Empty(t, claims.Role)
+}
@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/BazNick/shortlink/internal/app/apperr"
+	"github.com/BazNick/shortlink/internal/app/functions"
+	"github.com/BazNick/shortlink/internal/app/storage"
+	"github.com/gin-gonic/gin"
+)
+
+type (
+	APIKeyHandler struct {
+		keys storage.APIKeyStore
+	}
+
+	APIKeyIn struct {
+		Name   string   `json:"name"`
+		Scopes []string `json:"scopes"`
+	}
+
+	APIKeyOut struct {
+		ID         string     `json:"id"`
+		Name       string     `json:"name"`
+		Scopes     []string   `json:"scopes"`
+		Key        string     `json:"key,omitempty"`
+		CreatedAt  time.Time  `json:"created_at"`
+		LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+		RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	}
+)
+
+func NewAPIKeyHandler(keys storage.APIKeyStore) *APIKeyHandler {
+	return &APIKeyHandler{keys: keys}
+}
+
+func (handler *APIKeyHandler) CreateAPIKey(c *gin.Context) {
+	user, err := functions.GetUser(c)
+	if err != nil {
+		c.Writer.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	var in APIKeyIn
+	if err := json.NewDecoder(c.Request.Body).Decode(&in); err != nil {
+		http.Error(c.Writer, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if in.Name == "" {
+		http.Error(c.Writer, "name is required", http.StatusBadRequest)
+		return
+	}
+
+	if len(in.Scopes) == 0 {
+		in.Scopes = []string{storage.ScopeRead, storage.ScopeWrite}
+	}
+	for _, scope := range in.Scopes {
+		if !slices.Contains(storage.Scopes, scope) {
+			http.Error(c.Writer, "unknown scope "+scope, http.StatusBadRequest)
+			return
+		}
+	}
+
+	id, secret := functions.NewAPIKey()
+	key := storage.APIKey{
+		ID:        id,
+		UserID:    user,
+		Name:      in.Name,
+		Hash:      functions.HashAPIKey(secret),
+		Scopes:    in.Scopes,
+		CreatedAt: time.Now(),
+	}
+
+	if err := handler.keys.AddAPIKey(key); err != nil {
+		http.Error(c.Writer, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// ключ показывается один раз, потом его не восстановить
+	out := apiKeyOut(key)
+	out.Key = secret
+
+	resp, err := json.Marshal(out)
+	if err != nil {
+		http.Error(c.Writer, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	c.Writer.Header().Set("content-type", "application/json")
+	c.Writer.WriteHeader(http.StatusCreated)
+	c.Writer.Write(resp)
+}
+
+func (handler *APIKeyHandler) ListAPIKeys(c *gin.Context) {
+	user, err := functions.GetUser(c)
+	if err != nil {
+		c.Writer.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	keys, err := handler.keys.UserAPIKeys(user)
+	if err != nil {
+		http.Error(c.Writer, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	out := make([]APIKeyOut, 0, len(keys))
+	for _, key := range keys {
+		out = append(out, apiKeyOut(key))
+	}
+
+	resp, err := json.Marshal(out)
+	if err != nil {
+		http.Error(c.Writer, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	c.Writer.Header().Set("content-type", "application/json")
+	c.Writer.WriteHeader(http.StatusOK)
+	c.Writer.Write(resp)
+}
+
+func (handler *APIKeyHandler) RevokeAPIKey(c *gin.Context) {
+	user, err := functions.GetUser(c)
+	if err != nil {
+		c.Writer.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	err = handler.keys.RevokeAPIKey(user, c.Param("id"))
+	if errors.Is(err, apperr.ErrAPIKeyNotFound) {
+		http.Error(c.Writer, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(c.Writer, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	c.Writer.WriteHeader(http.StatusNoContent)
+}
+
+func apiKeyOut(key storage.APIKey) APIKeyOut {
+	return APIKeyOut{
+		ID:         key.ID,
+		Name:       key.Name,
+		Scopes:     key.Scopes,
+		CreatedAt:  key.CreatedAt,
+		LastUsedAt: key.LastUsedAt,
+		RevokedAt:  key.RevokedAt,
+	}
+}
@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/BazNick/shortlink/cmd/middleware/auth"
+	"github.com/BazNick/shortlink/internal/app/entities"
+	"github.com/BazNick/shortlink/internal/app/storage"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAPIKeys(t *testing.T) {
+	keys, err := entities.NewAPIKeys("")
+	require.NoError(t, err)
+
+	var (
+		handler    = NewAPIKeyHandler(keys)
+		urlHandler = NewURLHandler(entities.NewHashDict(), "test.json", "")
+		secret     = "secret_key"
+	)
+
+	token, err := auth.GenToken(secret)
+	require.NoError(t, err)
+
+	router := gin.Default()
+	router.Use(auth.Auth(secret, auth.WithAPIKeys(keys)))
+	router.POST("/", auth.RequireScope(storage.ScopeWrite), urlHandler.AddLink)
+	router.DELETE("/api/user/urls", auth.RequireScope(storage.ScopeDelete), urlHandler.DeleteUserLinks)
+	router.POST("/api/user/keys", auth.SessionOnly(), handler.CreateAPIKey)
+	router.GET("/api/user/keys", auth.SessionOnly(), handler.ListAPIKeys)
+	router.DELETE("/api/user/keys/:id", auth.SessionOnly(), handler.RevokeAPIKey)
+
+	do := func(method, target, body string, header, value string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(method, "http://localhost:8080"+target, strings.NewReader(body))
+		request.Header.Set(header, value)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, request)
+		return w
+	}
+	session := auth.BearerPrefix + token
+
+	w := do(http.MethodPost, "/api/user/keys", `{"name":"ci","scopes":["write"]}`, auth.HeaderName, session)
+	require.Equal(t, http.StatusCreated, w.Code)
+
+	var created APIKeyOut
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+	require.NotEmpty(t, created.Key)
+
+	w = do(http.MethodPost, "/", "https://ci.ru/release", auth.APIKeyHeader, created.Key)
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	w = do(http.MethodDelete, "/api/user/urls", `[]`, auth.APIKeyHeader, created.Key)
+	assert.Equal(t, http.StatusForbidden, w.Code, "key has no delete scope")
+
+	w = do(http.MethodGet, "/api/user/keys", "", auth.APIKeyHeader, created.Key)
+	assert.Equal(t, http.StatusForbidden, w.Code, "keys cannot manage keys")
+
+	w = do(http.MethodGet, "/api/user/keys", "", auth.HeaderName, session)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var listed []APIKeyOut
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &listed))
+	require.Len(t, listed, 1)
+	assert.Empty(t, listed[0].Key)
+	assert.NotNil(t, listed[0].LastUsedAt)
+
+	w = do(http.MethodDelete, "/api/user/keys/"+created.ID, "", auth.HeaderName, session)
+	require.Equal(t, http.StatusNoContent, w.Code)
+
+	w = do(http.MethodPost, "/", "https://ci.ru/other", auth.APIKeyHeader, created.Key)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
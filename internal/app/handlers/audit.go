@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/BazNick/shortlink/internal/app/storage"
+	"github.com/gin-gonic/gin"
+)
+
+var auditCSVHeader = []string{
+	"id", "time", "action", "short_url", "actor_id", "ip", "request_id", "before", "after", "note",
+}
+
+// SearchAudit ищет записи журнала по коду (?code=), автору (?actor= —
+// UserID или логин), действию (?action=) и времени (?since=, ?until= в
+// RFC 3339). Новые записи идут первыми.
+func (handler *AdminHandler) SearchAudit(c *gin.Context) {
+	limit, ok := adminLimit(c)
+	if !ok {
+		return
+	}
+	filter, ok := handler.auditFilter(c)
+	if !ok {
+		return
+	}
+
+	result := []storage.AuditEntry{}
+	err := handler.audit.Entries(filter, func(entry storage.AuditEntry) error {
+		result = append(result, entry)
+		if len(result) >= limit {
+			return errLimit
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errLimit) {
+		http.Error(c.Writer, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(c, http.StatusOK, result)
+}
+
+// ExportAudit выгружает записи журнала целиком, с теми же фильтрами, что
+// и SearchAudit.
+func (handler *AdminHandler) ExportAudit(c *gin.Context) {
+	filter, ok := handler.auditFilter(c)
+	if !ok {
+		return
+	}
+
+	format := c.DefaultQuery("format", FormatJSONL)
+
+	var write func(entry storage.AuditEntry) error
+
+	switch format {
+	case FormatCSV:
+		c.Writer.Header().Set("content-type", "text/csv")
+		w := csv.NewWriter(c.Writer)
+		defer w.Flush()
+
+		write = func(entry storage.AuditEntry) error {
+			w.Write([]string{
+				entry.ID,
+				entry.Time.UTC().Format(time.RFC3339Nano),
+				entry.Action,
+				entry.ShortURL,
+				entry.ActorID,
+				entry.IP,
+				entry.RequestID,
+				auditCSVLink(entry.Before),
+				auditCSVLink(entry.After),
+				entry.Note,
+			})
+			return w.Error()
+		}
+		if err := w.Write(auditCSVHeader); err != nil {
+			http.Error(c.Writer, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	case FormatJSONL:
+		c.Writer.Header().Set("content-type", "application/jsonl")
+		enc := json.NewEncoder(c.Writer)
+
+		write = func(entry storage.AuditEntry) error {
+			return enc.Encode(entry)
+		}
+	default:
+		http.Error(c.Writer, "unknown format, use csv or jsonl", http.StatusBadRequest)
+		return
+	}
+
+	c.Writer.Header().Set("Content-Disposition", `attachment; filename="audit.`+format+`"`)
+	c.Writer.WriteHeader(http.StatusOK)
+
+	// как и выгрузка ссылок, ошибку посреди ответа клиенту уже не сообщить
+	handler.audit.Entries(filter, write)
+}
+
+func (handler *AdminHandler) auditFilter(c *gin.Context) (storage.AuditFilter, bool) {
+	filter := storage.AuditFilter{
+		ShortURL: shortCodeFrom(c.Query("code")),
+		ActorID:  c.Query("actor"),
+		Action:   c.Query("action"),
+	}
+
+	if filter.ActorID != "" {
+		if user, err := handler.users.UserByLogin(strings.ToLower(filter.ActorID)); err == nil {
+			filter.ActorID = user.ID
+		}
+	}
+
+	for param, dst := range map[string]*time.Time{"since": &filter.Since, "until": &filter.Until} {
+		raw := c.Query(param)
+		if raw == "" {
+			continue
+		}
+
+		t, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			http.Error(c.Writer, param+" must be an RFC 3339 time", http.StatusBadRequest)
+			return storage.AuditFilter{}, false
+		}
+		*dst = t
+	}
+
+	return filter, true
+}
+
+func auditCSVLink(link *storage.Link) string {
+	if link == nil {
+		return ""
+	}
+
+	data, err := json.Marshal(link)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
@@ -1,13 +1,14 @@
 package handlers
 
 import (
-	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 
 	"github.com/BazNick/shortlink/internal/app/apperr"
-	"github.com/BazNick/shortlink/internal/app/entities"
 	"github.com/BazNick/shortlink/internal/app/functions"
+	"github.com/BazNick/shortlink/internal/app/storage"
+	"github.com/BazNick/shortlink/internal/app/urlnorm"
 	"github.com/gin-gonic/gin"
 )
 
@@ -30,51 +31,95 @@ func (handler *URLHandler) BatchLinks(c *gin.Context) {
 		return
 	}
 
-	for _, link := range links {
-		if handler.storage.CheckValExists(link.OriginalURL) {
-			http.Error(c.Writer, apperr.ErrLinkExists.Error(), http.StatusBadRequest)
+	if err := handler.quota.CheckBatch(len(links)); err != nil {
+		writeQuotaError(c, err)
+		return
+	}
+
+	for idx := range links {
+		original, err := handler.urls.Normalize(links[idx].OriginalURL)
+		if err != nil {
+			var urlErr *urlnorm.Error
+			if !errors.As(err, &urlErr) {
+				http.Error(c.Writer, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			writeJSON(c, http.StatusBadRequest, BatchURLError{
+				Error:         urlErr,
+				Index:         idx,
+				CorrelationID: links[idx].CorrelationID,
+			})
 			return
 		}
-	}
-	
-	out := make([]BatchOut, len(links))
+		links[idx].OriginalURL = original
 
-	// если это БД
-	if _, ok := handler.storage.(*entities.DB); ok {
-		tx, err := handler.db.Begin()
+		meta, err := links[idx].LinkMetadata.normalize()
 		if err != nil {
-			http.Error(c.Writer, err.Error(), http.StatusInternalServerError)
+			http.Error(c.Writer, links[idx].CorrelationID+": "+err.Error(), http.StatusBadRequest)
+			return
 		}
+		links[idx].LinkMetadata = meta
 
-		for idx, link := range links {
-			shortURL := functions.RandSeq(8)
-			_, err := tx.ExecContext(
-				context.Background(),
-				`INSERT INTO links (short_url, original_url, user_id) VALUES ($1, $2, $3)`,
-				shortURL,
-				link.OriginalURL,
-				user,
-			)
-			if err != nil {
-				tx.Rollback()
-				http.Error(c.Writer, err.Error(), http.StatusInternalServerError)
-			}
-			out[idx].CorrelationID = link.CorrelationID
-			out[idx].ShortURL = functions.SchemeAndHost(c.Request) + "/" + shortURL
+		if verdict := flagged(c, handler.safety, original); verdict.Flagged() {
+			writeJSON(c, http.StatusBadRequest, UnsafeURLError{
+				Message:       "url is flagged as unsafe",
+				Verdict:       verdict,
+				URL:           original,
+				Index:         &idx,
+				CorrelationID: links[idx].CorrelationID,
+			})
+			return
+		}
+	}
 
+	// все ссылки пакета одного пользователя, так что повтор внутри пакета
+	// не допускается ни в каком режиме, кроме DedupOff
+	seen := make(map[string]bool, len(links))
+	for _, link := range links {
+		repeated := handler.dedup != storage.DedupOff && seen[link.OriginalURL]
+		if repeated || handler.storage.CheckValExists(user, link.OriginalURL) {
+			http.Error(c.Writer, apperr.ErrLinkExists.Error(), http.StatusBadRequest)
+			return
 		}
+		seen[link.OriginalURL] = true
+	}
+	
+	reservation, ok := reserveQuota(c, handler.quota, user, len(links))
+	if !ok {
+		return
+	}
+
+	var (
+		out     = make([]BatchOut, len(links))
+		records = make([]storage.Link, len(links))
+	)
 
-		if err := tx.Commit(); err != nil {
-			http.Error(c.Writer, err.Error(), http.StatusInternalServerError)
+	for idx, link := range links {
+		shortURL := functions.RandSeq(8)
+		records[idx] = storage.Link{
+			ShortURL:    shortURL,
+			OriginalURL: link.OriginalURL,
+			UserID:      user,
 		}
-	} else {
-		// если это не БД, то сохраняем в файл или в мапу
-		for idx, link := range links {
-			shortURL := functions.RandSeq(8)
-			handler.storage.AddHash(shortURL, link.OriginalURL, user)
-			out[idx].CorrelationID = link.CorrelationID
-			out[idx].ShortURL = functions.SchemeAndHost(c.Request) + "/" + shortURL
+		link.apply(&records[idx])
+		out[idx].CorrelationID = link.CorrelationID
+		out[idx].ShortURL = functions.SchemeAndHost(c.Request) + "/" + shortURL
+	}
+
+	if err := handler.storage.AddBatch(records); err != nil {
+		reservation.Done(0)
+		// ту же ссылку мог успеть сократить параллельный запрос
+		if errors.Is(err, apperr.ErrValAlreadyExists) {
+			http.Error(c.Writer, apperr.ErrLinkExists.Error(), http.StatusBadRequest)
+			return
 		}
+		http.Error(c.Writer, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	reservation.Done(len(records))
+
+	for idx := range records {
+		handler.audit.Record(c, storage.AuditCreate, nil, &records[idx])
 	}
 
 	resp, err := json.Marshal(out)
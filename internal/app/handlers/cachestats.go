@@ -0,0 +1,27 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/BazNick/shortlink/internal/app/storage"
+	"github.com/gin-gonic/gin"
+)
+
+func (handler *URLHandler) CacheStats(c *gin.Context) {
+	cache, ok := storage.As[*storage.Cache](handler.storage)
+	if !ok {
+		http.Error(c.Writer, "cache is disabled", http.StatusNotFound)
+		return
+	}
+
+	resp, err := json.Marshal(cache.Stats())
+	if err != nil {
+		http.Error(c.Writer, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	c.Writer.Header().Set("content-type", "application/json")
+	c.Writer.WriteHeader(http.StatusOK)
+	c.Writer.Write(resp)
+}
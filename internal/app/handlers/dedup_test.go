@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/BazNick/shortlink/cmd/middleware/auth"
+	"github.com/BazNick/shortlink/internal/app/entities"
+	"github.com/BazNick/shortlink/internal/app/storage"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDedup(t *testing.T) {
+	keyring, err := auth.LoadKeyring("secret_key", "", "")
+	require.NoError(t, err)
+	alice, err := auth.NewToken(keyring)
+	require.NoError(t, err)
+	bob, err := auth.NewToken(keyring)
+	require.NoError(t, err)
+
+	type step struct {
+		token        string
+		target       string
+		body         string
+		expectedCode int
+	}
+
+	var (
+		link  = `{"url":"https://ya.ru"}`
+		batch = `[{"correlation_id":"1","original_url":"https://vk.ru"},{"correlation_id":"2","original_url":"https://vk.ru"}]`
+	)
+
+	tests := []struct {
+		name  string
+		mode  storage.Dedup
+		steps []step
+	}{
+		{
+			name: "Global",
+			mode: storage.DedupGlobal,
+			steps: []step{
+				{alice, "/api/shorten", link, http.StatusCreated},
+				{alice, "/api/shorten", link, http.StatusBadRequest},
+				{bob, "/api/shorten", link, http.StatusBadRequest},
+				{bob, "/api/shorten/batch", batch, http.StatusBadRequest},
+			},
+		},
+		{
+			name: "Per user",
+			mode: storage.DedupUser,
+			steps: []step{
+				{alice, "/api/shorten", link, http.StatusCreated},
+				{alice, "/api/shorten", link, http.StatusBadRequest},
+				{bob, "/api/shorten", link, http.StatusCreated},
+				{bob, "/api/shorten/batch", batch, http.StatusBadRequest},
+				{bob, "/api/shorten/batch", `[{"correlation_id":"1","original_url":"https://ya.ru"}]`, http.StatusBadRequest},
+			},
+		},
+		{
+			name: "Off",
+			mode: storage.DedupOff,
+			steps: []step{
+				{alice, "/api/shorten", link, http.StatusCreated},
+				{alice, "/api/shorten", link, http.StatusCreated},
+				{bob, "/api/shorten/batch", batch, http.StatusCreated},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := entities.NewHashDict()
+			require.NoError(t, storage.SetDedup(store, tt.mode))
+			handler := NewURLHandler(store, "test.json", "", WithDedup(tt.mode))
+
+			router := gin.Default()
+			router.Use(auth.Middleware(keyring))
+			router.POST("/api/shorten", handler.PostJSONLink)
+			router.POST("/api/shorten/batch", handler.BatchLinks)
+
+			for i, step := range tt.steps {
+				request := httptest.NewRequest(http.MethodPost, "http://localhost:8080"+step.target, strings.NewReader(step.body))
+				request.Header.Set(auth.HeaderName, auth.BearerPrefix+step.token)
+				w := httptest.NewRecorder()
+				router.ServeHTTP(w, request)
+
+				assert.Equal(t, step.expectedCode, w.Code, "step %d: %s", i, w.Body.String())
+			}
+		})
+	}
+}
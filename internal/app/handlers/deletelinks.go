@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"net/http"
 
+	"github.com/BazNick/shortlink/internal/app/audit"
 	"github.com/BazNick/shortlink/internal/app/entities"
 	"github.com/BazNick/shortlink/internal/app/functions"
 	"github.com/gin-gonic/gin"
@@ -25,6 +26,7 @@ func (handler *URLHandler) DeleteUserLinks(c *gin.Context) {
 	entities.DeleteChan <- entities.DeleteRequest{
 		UserID:    user,
 		ShortURLs: links,
+		Source:    audit.Source(c),
 	}
 
 	c.Writer.WriteHeader(http.StatusAccepted)
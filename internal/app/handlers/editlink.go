@@ -0,0 +1,277 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/BazNick/shortlink/internal/app/apperr"
+	"github.com/BazNick/shortlink/internal/app/functions"
+	"github.com/BazNick/shortlink/internal/app/storage"
+	"github.com/gin-gonic/gin"
+)
+
+// LinkPatch — что поменять в ссылке, отсутствующие поля не меняются.
+type LinkPatch struct {
+	OriginalURL *string `json:"original_url"`
+	// время в RFC 3339, null снимает срок
+	ExpiresAt   json.RawMessage `json:"expires_at"`
+	Title       *string         `json:"title"`
+	Description *string         `json:"description"`
+	// пустой список снимает все метки
+	Tags *[]string `json:"tags"`
+}
+
+// PatchUserLink меняет адрес, срок и подписи ссылки владельца. Каждая правка
+// сохраняется новой версией, к любой из них можно откатиться.
+func (handler *URLHandler) PatchUserLink(c *gin.Context) {
+	user, err := functions.GetUser(c)
+	if err != nil {
+		c.Writer.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	var patch LinkPatch
+	if err := json.NewDecoder(c.Request.Body).Decode(&patch); err != nil {
+		http.Error(c.Writer, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if patch.OriginalURL == nil && patch.ExpiresAt == nil &&
+		patch.Title == nil && patch.Description == nil && patch.Tags == nil {
+		http.Error(c.Writer, "nothing to change", http.StatusBadRequest)
+		return
+	}
+
+	current, ok := handler.ownLink(c, user)
+	if !ok {
+		return
+	}
+
+	next := current
+	if patch.OriginalURL != nil {
+		next.OriginalURL, ok = normalizeLink(c, handler.urls, *patch.OriginalURL)
+		if !ok || !checkSafety(c, handler.safety, next.OriginalURL) {
+			return
+		}
+	}
+
+	if patch.ExpiresAt != nil {
+		next.ExpiresAt = nil
+		if !bytes.Equal(patch.ExpiresAt, []byte("null")) {
+			var expiresAt time.Time
+			if err := json.Unmarshal(patch.ExpiresAt, &expiresAt); err != nil {
+				http.Error(c.Writer, "expires_at must be an RFC 3339 time or null", http.StatusBadRequest)
+				return
+			}
+			if !checkExpiry(c, &expiresAt) {
+				return
+			}
+			next.ExpiresAt = &expiresAt
+		}
+	}
+
+	meta := LinkMetadata{Title: next.Title, Description: next.Description, Tags: next.Tags}
+	if patch.Title != nil {
+		meta.Title = *patch.Title
+	}
+	if patch.Description != nil {
+		meta.Description = *patch.Description
+	}
+	if patch.Tags != nil {
+		meta.Tags = *patch.Tags
+	}
+	if meta, ok = linkMetadata(c, meta); !ok {
+		return
+	}
+	meta.apply(&next)
+
+	handler.editLink(c, user, current, next, storage.AuditUpdate, 0)
+}
+
+// LinkVersions — история правок ссылки владельца, от первой версии к
+// последней.
+func (handler *URLHandler) LinkVersions(c *gin.Context) {
+	user, err := functions.GetUser(c)
+	if err != nil {
+		c.Writer.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	current, ok := handler.ownLink(c, user)
+	if !ok {
+		return
+	}
+
+	versions, err := handler.linkVersions(current.ShortURL)
+	if err != nil {
+		http.Error(c.Writer, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(c, http.StatusOK, versions)
+}
+
+// RestoreLinkVersion откатывает ссылку к версии :version. Откат — тоже
+// правка, он добавляет в историю новую версию.
+func (handler *URLHandler) RestoreLinkVersion(c *gin.Context) {
+	user, err := functions.GetUser(c)
+	if err != nil {
+		c.Writer.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	number, err := strconv.Atoi(c.Param("version"))
+	if err != nil {
+		http.Error(c.Writer, "version must be a number", http.StatusBadRequest)
+		return
+	}
+
+	current, ok := handler.ownLink(c, user)
+	if !ok {
+		return
+	}
+
+	versions, err := handler.linkVersions(current.ShortURL)
+	if err != nil {
+		http.Error(c.Writer, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if number < 1 || number > len(versions) {
+		http.Error(c.Writer, "version not found", http.StatusNotFound)
+		return
+	}
+
+	next := current
+	next.SetEditable(versions[number-1].Link)
+	// список блокировки мог пополниться, а срок — пройти после того, как
+	// версию сохранили
+	if !checkSafety(c, handler.safety, next.OriginalURL) || !checkExpiry(c, next.ExpiresAt) {
+		return
+	}
+
+	handler.editLink(c, user, current, next, storage.AuditRestore, number)
+}
+
+// checkExpiry отвечает 400, если срок ссылки уже прошёл.
+func checkExpiry(c *gin.Context, expiresAt *time.Time) bool {
+	if expiresAt != nil && !expiresAt.After(time.Now()) {
+		http.Error(c.Writer, "expires_at must be in the future", http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+// ownLink — ссылка :id текущего пользователя. Чужие и удалённые ссылки
+// для него не существуют.
+func (handler *URLHandler) ownLink(c *gin.Context, user string) (storage.Link, bool) {
+	link, err := handler.storage.GetLink(c.Param("id"))
+	if err == nil && (link.UserID != user || link.IsDeleted) {
+		err = apperr.ErrLinkNotFound
+	}
+	if errors.Is(err, apperr.ErrLinkNotFound) {
+		http.Error(c.Writer, err.Error(), http.StatusNotFound)
+		return storage.Link{}, false
+	}
+	if err != nil {
+		http.Error(c.Writer, err.Error(), http.StatusInternalServerError)
+		return storage.Link{}, false
+	}
+	return link, true
+}
+
+func (handler *URLHandler) editLink(
+	c *gin.Context,
+	user string,
+	current, next storage.Link,
+	action string,
+	restoredFrom int,
+) {
+	if next.OriginalURL != current.OriginalURL && handler.db == nil {
+		if handler.storage.CheckValExists(user, next.OriginalURL) {
+			http.Error(c.Writer, apperr.ErrLinkExists.Error(), http.StatusConflict)
+			return
+		}
+	}
+
+	var before storage.Link
+	link, err := handler.storage.UpdateLink(current.ShortURL, func(link *storage.Link) error {
+		// ссылку могли удалить, пока шёл запрос
+		if link.UserID != user || link.IsDeleted {
+			return apperr.ErrLinkNotFound
+		}
+		before = *link
+		link.SetEditable(next)
+		return nil
+	})
+	switch {
+	case errors.Is(err, apperr.ErrLinkNotFound):
+		http.Error(c.Writer, err.Error(), http.StatusNotFound)
+		return
+	case errors.Is(err, apperr.ErrValAlreadyExists):
+		http.Error(c.Writer, apperr.ErrLinkExists.Error(), http.StatusConflict)
+		return
+	case err != nil:
+		http.Error(c.Writer, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	handler.audit.Record(c, action, &before, &link)
+
+	version := storage.LinkVersion{
+		ShortURL:     link.ShortURL,
+		Link:         link,
+		ChangedBy:    user,
+		ChangedAt:    time.Now(),
+		RestoredFrom: restoredFrom,
+	}
+	if handler.versions != nil {
+		// ссылка уже изменена, без истории правка всё равно состоялась
+		if saved, err := handler.saveVersion(before, version); err != nil {
+			log.Printf("versions of %s: %v", link.ShortURL, err)
+		} else {
+			version = saved
+		}
+	}
+
+	writeJSON(c, http.StatusOK, version)
+}
+
+// saveVersion добавляет версию в историю. У ссылки, которую ещё не
+// правили, сначала сохраняется исходное состояние.
+func (handler *URLHandler) saveVersion(before storage.Link, version storage.LinkVersion) (storage.LinkVersion, error) {
+	versions, err := handler.linkVersions(before.ShortURL)
+	if err != nil {
+		return storage.LinkVersion{}, err
+	}
+
+	if len(versions) == 0 {
+		_, err := handler.versions.AddVersion(storage.LinkVersion{
+			ShortURL:  before.ShortURL,
+			Link:      before,
+			ChangedBy: before.UserID,
+			ChangedAt: version.ChangedAt,
+		})
+		if err != nil {
+			return storage.LinkVersion{}, err
+		}
+	}
+
+	return handler.versions.AddVersion(version)
+}
+
+func (handler *URLHandler) linkVersions(hash string) ([]storage.LinkVersion, error) {
+	versions := []storage.LinkVersion{}
+	if handler.versions == nil {
+		return versions, nil
+	}
+
+	err := handler.versions.Versions(hash, func(v storage.LinkVersion) error {
+		versions = append(versions, v)
+		return nil
+	})
+	return versions, err
+}
@@ -0,0 +1,241 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/BazNick/shortlink/cmd/middleware/auth"
+	"github.com/BazNick/shortlink/internal/app/audit"
+	"github.com/BazNick/shortlink/internal/app/entities"
+	"github.com/BazNick/shortlink/internal/app/storage"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEditLink(t *testing.T) {
+	keyring, err := auth.LoadKeyring("secret_key", "", "")
+	require.NoError(t, err)
+
+	var tokens []string
+	for i := 0; i < 2; i++ {
+		token, err := auth.NewToken(keyring)
+		require.NoError(t, err)
+		tokens = append(tokens, token)
+	}
+
+	store := entities.NewHashDict()
+	versions, err := entities.NewVersions("")
+	require.NoError(t, err)
+	entries, err := entities.NewAuditLog("")
+	require.NoError(t, err)
+
+	handler := NewURLHandler(
+		store,
+		"test.json",
+		"",
+		WithVersions(versions),
+		WithAudit(audit.New(entries)),
+	)
+
+	router := gin.Default()
+	router.Use(auth.Middleware(keyring))
+	router.GET("/:id", handler.GetLink)
+	router.POST("/api/shorten", handler.PostJSONLink)
+	router.PATCH("/api/user/urls/:id", handler.PatchUserLink)
+	router.GET("/api/user/urls/:id/versions", handler.LinkVersions)
+	router.POST("/api/user/urls/:id/versions/:version/restore", handler.RestoreLinkVersion)
+
+	do := func(method, target, body string, token int) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(method, "http://localhost:8080"+target, strings.NewReader(body))
+		request.Header.Set(auth.HeaderName, auth.BearerPrefix+tokens[token])
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, request)
+		return w
+	}
+
+	shorten := func(url string) string {
+		w := do(http.MethodPost, "/api/shorten", `{"url":"`+url+`"}`, 0)
+		require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+		var resp map[string]string
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		return strings.TrimPrefix(resp["result"], "http://localhost:8080/")
+	}
+
+	var (
+		code   = shorten("https://old.example/qr")
+		future = time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+	)
+	shorten("https://taken.example/")
+
+	tests := []struct {
+		name         string
+		method       string
+		target       string
+		body         string
+		token        int
+		expectedCode int
+		check        func(t *testing.T, w *httptest.ResponseRecorder)
+	}{
+		{
+			name:         "Empty patch",
+			method:       http.MethodPatch,
+			target:       "/api/user/urls/" + code,
+			body:         `{}`,
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "Someone else's link",
+			method:       http.MethodPatch,
+			target:       "/api/user/urls/" + code,
+			body:         `{"original_url":"https://evil.example/"}`,
+			token:        1,
+			expectedCode: http.StatusNotFound,
+		},
+		{
+			name:         "Invalid destination",
+			method:       http.MethodPatch,
+			target:       "/api/user/urls/" + code,
+			body:         `{"original_url":"javascript:alert(1)"}`,
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "Destination of another link",
+			method:       http.MethodPatch,
+			target:       "/api/user/urls/" + code,
+			body:         `{"original_url":"https://taken.example/"}`,
+			expectedCode: http.StatusConflict,
+		},
+		{
+			name:         "Expiry in the past",
+			method:       http.MethodPatch,
+			target:       "/api/user/urls/" + code,
+			body:         `{"expires_at":"2000-01-01T00:00:00Z"}`,
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "New destination",
+			method:       http.MethodPatch,
+			target:       "/api/user/urls/" + code,
+			body:         `{"original_url":"HTTPS://New.example/qr"}`,
+			expectedCode: http.StatusOK,
+			check: func(t *testing.T, w *httptest.ResponseRecorder) {
+				var version storage.LinkVersion
+				require.NoError(t, json.Unmarshal(w.Body.Bytes(), &version))
+				assert.Equal(t, 2, version.Version)
+				assert.Equal(t, "https://new.example/qr", version.Link.OriginalURL)
+
+				assert.Equal(t, "https://new.example/qr", do(http.MethodGet, "/"+code, "", 0).Header().Get("Location"))
+				// старый адрес освободился
+				assert.Equal(t, http.StatusCreated, do(http.MethodPost, "/api/shorten", `{"url":"https://old.example/qr"}`, 0).Code)
+			},
+		},
+		{
+			name:         "Expiry",
+			method:       http.MethodPatch,
+			target:       "/api/user/urls/" + code,
+			body:         `{"expires_at":"` + future + `"}`,
+			expectedCode: http.StatusOK,
+			check: func(t *testing.T, w *httptest.ResponseRecorder) {
+				var version storage.LinkVersion
+				require.NoError(t, json.Unmarshal(w.Body.Bytes(), &version))
+				require.NotNil(t, version.Link.ExpiresAt)
+				assert.Equal(t, "https://new.example/qr", version.Link.OriginalURL)
+			},
+		},
+		{
+			name:         "History",
+			method:       http.MethodGet,
+			target:       "/api/user/urls/" + code + "/versions",
+			expectedCode: http.StatusOK,
+			check: func(t *testing.T, w *httptest.ResponseRecorder) {
+				var history []storage.LinkVersion
+				require.NoError(t, json.Unmarshal(w.Body.Bytes(), &history))
+				require.Len(t, history, 3)
+				assert.Equal(t, "https://old.example/qr", history[0].Link.OriginalURL)
+				assert.Nil(t, history[1].Link.ExpiresAt)
+				assert.NotNil(t, history[2].Link.ExpiresAt)
+			},
+		},
+		{
+			name:         "Unknown version",
+			method:       http.MethodPost,
+			target:       "/api/user/urls/" + code + "/versions/9/restore",
+			expectedCode: http.StatusNotFound,
+		},
+		{
+			name:         "Restore of a taken destination",
+			method:       http.MethodPost,
+			target:       "/api/user/urls/" + code + "/versions/1/restore",
+			expectedCode: http.StatusConflict,
+		},
+		{
+			name:         "Restore",
+			method:       http.MethodPost,
+			target:       "/api/user/urls/" + code + "/versions/2/restore",
+			expectedCode: http.StatusOK,
+			check: func(t *testing.T, w *httptest.ResponseRecorder) {
+				var version storage.LinkVersion
+				require.NoError(t, json.Unmarshal(w.Body.Bytes(), &version))
+				assert.Equal(t, 4, version.Version)
+				assert.Equal(t, 2, version.RestoredFrom)
+				assert.Nil(t, version.Link.ExpiresAt)
+
+				var restored []storage.AuditEntry
+				entries.AuditEntries(storage.AuditFilter{Action: storage.AuditRestore}, func(e storage.AuditEntry) error {
+					restored = append(restored, e)
+					return nil
+				})
+				require.Len(t, restored, 1)
+				assert.NotNil(t, restored[0].Before.ExpiresAt)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := do(tt.method, tt.target, tt.body, tt.token)
+			require.Equal(t, tt.expectedCode, w.Code, w.Body.String())
+			if tt.check != nil {
+				tt.check(t, w)
+			}
+		})
+	}
+
+	t.Run("Expired link", func(t *testing.T) {
+		past := time.Now().Add(-time.Minute)
+		_, err := store.UpdateLink(code, func(link *storage.Link) error {
+			link.ExpiresAt = &past
+			return nil
+		})
+		require.NoError(t, err)
+
+		w := do(http.MethodGet, "/"+code, "", 0)
+		assert.Equal(t, http.StatusGone, w.Code)
+		assert.Contains(t, w.Body.String(), "expired")
+	})
+
+	t.Run("Restore of an expired version", func(t *testing.T) {
+		current, err := store.GetLink(code)
+		require.NoError(t, err)
+
+		past := time.Now().Add(-time.Hour)
+		current.ExpiresAt = &past
+		saved, err := versions.AddVersion(storage.LinkVersion{
+			ShortURL:  code,
+			Link:      current,
+			ChangedBy: current.UserID,
+			ChangedAt: past,
+		})
+		require.NoError(t, err)
+
+		w := do(http.MethodPost, "/api/user/urls/"+code+"/versions/"+strconv.Itoa(saved.Version)+"/restore", "", 0)
+		assert.Equal(t, http.StatusBadRequest, w.Code, w.Body.String())
+	})
+}
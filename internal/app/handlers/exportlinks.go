@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BazNick/shortlink/internal/app/functions"
+	"github.com/BazNick/shortlink/internal/app/storage"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	FormatCSV   = "csv"
+	FormatJSONL = "jsonl"
+)
+
+var exportCSVHeader = []string{
+	"short_code", "short_url", "original_url", "is_deleted",
+	"title", "description", "tags", "created_at", "updated_at", "last_accessed_at",
+}
+
+func (handler *URLHandler) ExportUserLinks(c *gin.Context) {
+	user, err := functions.GetUser(c)
+	if err != nil {
+		c.Writer.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	format := c.DefaultQuery("format", FormatJSONL)
+
+	var write func(rec ExportLink) error
+
+	switch format {
+	case FormatCSV:
+		c.Writer.Header().Set("content-type", "text/csv")
+		w := csv.NewWriter(c.Writer)
+		defer w.Flush()
+
+		write = func(rec ExportLink) error {
+			w.Write([]string{
+				rec.ShortCode,
+				rec.ShortURL,
+				rec.OriginalURL,
+				strconv.FormatBool(rec.IsDeleted),
+				rec.Title,
+				rec.Description,
+				strings.Join(rec.Tags, ","),
+				formatExportTime(&rec.CreatedAt),
+				formatExportTime(&rec.UpdatedAt),
+				formatExportTime(rec.LastAccessedAt),
+			})
+			return w.Error()
+		}
+		if err := w.Write(exportCSVHeader); err != nil {
+			http.Error(c.Writer, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	case FormatJSONL:
+		c.Writer.Header().Set("content-type", "application/jsonl")
+		enc := json.NewEncoder(c.Writer)
+
+		write = func(rec ExportLink) error {
+			return enc.Encode(rec)
+		}
+	default:
+		http.Error(c.Writer, "unknown format, use csv or jsonl", http.StatusBadRequest)
+		return
+	}
+
+	c.Writer.Header().Set("Content-Disposition", `attachment; filename="links.`+format+`"`)
+	c.Writer.WriteHeader(http.StatusOK)
+
+	// ссылки пишутся в ответ по мере чтения из хранилища, поэтому
+	// ошибку посреди выгрузки клиенту уже не сообщить
+	handler.storage.UserLinks(user, func(link storage.Link) error {
+		return write(ExportLink{
+			ShortCode:   link.ShortURL,
+			ShortURL:    functions.SchemeAndHost(c.Request) + "/" + link.ShortURL,
+			OriginalURL: link.OriginalURL,
+			IsDeleted:   link.IsDeleted,
+			LinkMetadata: LinkMetadata{
+				Title:       link.Title,
+				Description: link.Description,
+				Tags:        link.Tags,
+			},
+			CreatedAt:      link.CreatedAt,
+			UpdatedAt:      link.UpdatedAt,
+			LastAccessedAt: link.LastAccessedAt,
+		})
+	})
+}
+
+func formatExportTime(t *time.Time) string {
+	if t == nil || t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339Nano)
+}
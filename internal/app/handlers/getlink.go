@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"net/http"
+	"time"
 
 	"github.com/BazNick/shortlink/internal/app/apperr"
 	"github.com/gin-gonic/gin"
@@ -19,10 +20,32 @@ func (handler *URLHandler) GetLink(c *gin.Context) {
 	)
 
 	if pageID == "" {
+		// отключённой ссылке — своя страница, чтобы было понятно, что
+		// ссылка была, но больше не работает
+		link, err := handler.storage.GetLink(id)
+		if err == nil && link.IsDisabled && !link.IsDeleted {
+			writeDisabledPage(c)
+			return
+		}
+		if err == nil && link.Expired(time.Now()) && !link.IsDeleted {
+			http.Error(c.Writer, "link expired", http.StatusGone)
+			return
+		}
 		http.Error(c.Writer, apperr.ErrLinkNotFound.Error(), http.StatusGone)
 		return
 	}
 
+	// список блокировки мог пополниться уже после создания ссылки;
+	// confirm=1 — пользователь прочитал предупреждение и всё же идёт дальше
+	if c.Query("confirm") == "" {
+		if verdict := flagged(c, handler.safety, pageID); verdict.Flagged() {
+			writeWarningPage(c, pageID, verdict)
+			return
+		}
+	}
+
+	handler.clicks.Hit(id)
+
 	c.Writer.Header().Set("Location", pageID)
 	c.Writer.Header().Set("Content-Type", "text/html")
 	c.Writer.WriteHeader(http.StatusTemporaryRedirect)
@@ -2,51 +2,151 @@ package handlers
 
 import (
 	"database/sql"
+	"time"
 
+	"github.com/BazNick/shortlink/internal/app/audit"
+	"github.com/BazNick/shortlink/internal/app/clicks"
 	"github.com/BazNick/shortlink/internal/app/entities"
+	"github.com/BazNick/shortlink/internal/app/quota"
+	"github.com/BazNick/shortlink/internal/app/safety"
 	"github.com/BazNick/shortlink/internal/app/storage"
+	"github.com/BazNick/shortlink/internal/app/urlnorm"
 	_ "github.com/jackc/pgx/v5/stdlib"
 )
 
 type (
 	JSONLink struct {
 		Link string `json:"url"`
+		LinkMetadata
 	}
 
 	URLHandler struct {
-		storage storage.Storage
-		path    string
-		dbPath  string
-		db      *sql.DB
+		storage  storage.Storage
+		path     string
+		dbPath   string
+		db       *sql.DB
+		quota    *quota.Quota
+		audit    *audit.Log
+		urls     *urlnorm.Normalizer
+		safety   safety.Checker
+		versions storage.VersionStore
+		dedup    storage.Dedup
+		clicks   *clicks.Counter
 	}
 
+	URLOption func(handler *URLHandler)
+
 	BatchIn struct {
 		CorrelationID string `json:"correlation_id"`
 		OriginalURL   string `json:"original_url"`
+		LinkMetadata
 	}
 
 	BatchOut struct {
 		CorrelationID string `json:"correlation_id"`
 		ShortURL      string `json:"short_url"`
 	}
+
+	ExportLink struct {
+		ShortCode   string `json:"short_code"`
+		ShortURL    string `json:"short_url"`
+		OriginalURL string `json:"original_url"`
+		IsDeleted   bool   `json:"is_deleted,omitempty"`
+		LinkMetadata
+		CreatedAt      time.Time  `json:"created_at"`
+		UpdatedAt      time.Time  `json:"updated_at"`
+		LastAccessedAt *time.Time `json:"last_accessed_at,omitempty"`
+	}
+
+	ImportRow struct {
+		Row         int    `json:"row"`
+		ShortCode   string `json:"short_code,omitempty"`
+		ShortURL    string `json:"short_url,omitempty"`
+		OriginalURL string `json:"original_url,omitempty"`
+		Status      string `json:"status"`
+		Error       string `json:"error,omitempty"`
+	}
+
+	ImportResult struct {
+		Imported  int         `json:"imported"`
+		Renamed   int         `json:"renamed"`
+		Conflicts int         `json:"conflicts"`
+		Invalid   int         `json:"invalid"`
+		Rows      []ImportRow `json:"rows"`
+	}
 )
 
 func NewURLHandler(
-	storage storage.Storage,
+	store storage.Storage,
 	filePath, dbPath string,
+	opts ...URLOption,
 ) *URLHandler {
 	var db *sql.DB
 
-	if dbStorage, ok := storage.(*entities.DB); ok {
+	if dbStorage, ok := storage.As[*entities.DB](store); ok {
 		db = dbStorage.Database
 	}
 
 	handler := &URLHandler{
-		storage: storage,
+		storage: store,
 		path:    filePath,
 		dbPath:  dbPath,
 		db:      db,
 	}
+	for _, opt := range opts {
+		opt(handler)
+	}
 
 	return handler
-}
\ No newline at end of file
+}
+
+// WithQuota включает лимиты на создание ссылок.
+func WithQuota(q *quota.Quota) URLOption {
+	return func(handler *URLHandler) {
+		handler.quota = q
+	}
+}
+
+// WithAudit включает запись созданных ссылок в журнал аудита.
+func WithAudit(log *audit.Log) URLOption {
+	return func(handler *URLHandler) {
+		handler.audit = log
+	}
+}
+
+// WithURLNormalizer задаёт, какие ссылки принимаются и как приводятся к
+// одному виду. Без него действуют urlnorm.Options по умолчанию.
+func WithURLNormalizer(n *urlnorm.Normalizer) URLOption {
+	return func(handler *URLHandler) {
+		handler.urls = n
+	}
+}
+
+// WithVersions сохраняет историю правок ссылок.
+func WithVersions(versions storage.VersionStore) URLOption {
+	return func(handler *URLHandler) {
+		handler.versions = versions
+	}
+}
+
+// WithSafety проверяет ссылки на опасность при создании и при переходе.
+func WithSafety(checker safety.Checker) URLOption {
+	return func(handler *URLHandler) {
+		handler.safety = checker
+	}
+}
+
+// WithDedup задаёт, какие ссылки считаются повтором. Без него — глобальная
+// дедупликация, как у хранилища по умолчанию.
+func WithDedup(mode storage.Dedup) URLOption {
+	return func(handler *URLHandler) {
+		handler.dedup = mode
+	}
+}
+
+// WithClicks считает переходы по ссылкам.
+func WithClicks(counter *clicks.Counter) URLOption {
+	return func(handler *URLHandler) {
+		handler.clicks = counter
+	}
+}
@@ -0,0 +1,337 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/BazNick/shortlink/internal/app/apperr"
+	"github.com/BazNick/shortlink/internal/app/functions"
+	"github.com/BazNick/shortlink/internal/app/storage"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	maxImportBytes = 10 << 20
+	// сколько раз подбирать новый код, если занят и случайный
+	importCodeAttempts = 5
+
+	ImportCreated  = "created"
+	ImportRenamed  = "renamed"
+	ImportConflict = "conflict"
+	ImportInvalid  = "invalid"
+)
+
+var (
+	// колонки, под которыми короткий код и исходная ссылка встречаются
+	// в наших выгрузках и в выгрузках других сокращателей (Bitly и т.п.)
+	shortCodeColumns   = []string{"short_code", "short_url", "link", "bitlink", "id"}
+	originalURLColumns = []string{"original_url", "long_url", "url", "destination"}
+
+	shortCodeRe = regexp.MustCompile(`^[A-Za-z0-9_-]{1,15}$`)
+)
+
+// ImportUserLinks загружает выгрузку построчно. Импорт не атомарен:
+// каждая строка пишется отдельно и получает свой статус, ошибка в одной
+// строке не откатывает уже созданные. Лимит списывается на весь файл
+// сразу, несозданные строки возвращаются в него, как бы ни закончился
+// запрос.
+func (handler *URLHandler) ImportUserLinks(c *gin.Context) {
+	user, err := functions.GetUser(c)
+	if err != nil {
+		c.Writer.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	format := c.Query("format")
+	if format == "" {
+		format = FormatJSONL
+		if strings.Contains(c.GetHeader("Content-Type"), "csv") {
+			format = FormatCSV
+		}
+	}
+
+	body := http.MaxBytesReader(c.Writer, c.Request.Body, maxImportBytes)
+	defer body.Close()
+
+	var rows []map[string]string
+
+	switch format {
+	case FormatCSV:
+		rows, err = readCSVRows(body)
+	case FormatJSONL:
+		rows, err = readJSONLRows(body)
+	default:
+		http.Error(c.Writer, "unknown format, use csv or jsonl", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(c.Writer, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	reservation, ok := reserveQuota(c, handler.quota, user, len(rows))
+	if !ok {
+		return
+	}
+
+	result := ImportResult{Rows: make([]ImportRow, 0, len(rows))}
+	defer func() { reservation.Done(result.Imported) }()
+
+	for idx, row := range rows {
+		res, created := handler.importRow(c, user, row)
+		res.Row = idx + 1
+		if created != nil {
+			handler.audit.Record(c, storage.AuditCreate, nil, created)
+		}
+		if res.ShortCode != "" && res.Status != ImportConflict && res.Status != ImportInvalid {
+			res.ShortURL = functions.SchemeAndHost(c.Request) + "/" + res.ShortCode
+		}
+
+		switch res.Status {
+		case ImportCreated:
+			result.Imported++
+		case ImportRenamed:
+			result.Imported++
+			result.Renamed++
+		case ImportConflict:
+			result.Conflicts++
+		case ImportInvalid:
+			result.Invalid++
+		}
+		result.Rows = append(result.Rows, res)
+	}
+
+	resp, err := json.Marshal(result)
+	if err != nil {
+		http.Error(c.Writer, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	c.Writer.Header().Set("content-type", "application/json")
+	c.Writer.WriteHeader(http.StatusOK)
+	c.Writer.Write(resp)
+}
+
+// importRow возвращает статус строки и созданную ссылку, если она создана.
+func (handler *URLHandler) importRow(c *gin.Context, user string, row map[string]string) (ImportRow, *storage.Link) {
+	res := ImportRow{
+		OriginalURL: pickColumn(row, originalURLColumns),
+		ShortCode:   shortCodeFrom(pickColumn(row, shortCodeColumns)),
+	}
+
+	original, err := handler.urls.Normalize(res.OriginalURL)
+	if err != nil {
+		res.Status = ImportInvalid
+		res.Error = err.Error()
+		return res, nil
+	}
+	res.OriginalURL = original
+
+	if verdict := flagged(c, handler.safety, original); verdict.Flagged() {
+		res.Status = ImportInvalid
+		res.Error = "url is flagged as unsafe: " + verdict.Reason
+		return res, nil
+	}
+
+	if handler.storage.CheckValExists(user, res.OriginalURL) {
+		res.Status = ImportConflict
+		res.Error = apperr.ErrLinkExists.Error()
+		return res, nil
+	}
+
+	res.Status = ImportCreated
+	if res.ShortCode != "" && !shortCodeRe.MatchString(res.ShortCode) {
+		res.Error = fmt.Sprintf("short code %q is not supported, assigned a new one", res.ShortCode)
+		res.Status = ImportRenamed
+		res.ShortCode = ""
+	}
+
+	// код занимается вместе с записью, поэтому параллельный импорт или
+	// сокращение не отдадут его двум ссылкам
+	link, err := importedLink(row)
+	if err != nil {
+		res.Status = ImportInvalid
+		res.Error = err.Error()
+		return res, nil
+	}
+	link.OriginalURL, link.UserID = res.OriginalURL, user
+	// время ставится здесь, а не хранилищем, чтобы в журнал попала та же
+	// запись, что и в хранилище
+	if link.CreatedAt.IsZero() {
+		link.CreatedAt = time.Now()
+	}
+	if link.UpdatedAt.IsZero() {
+		link.UpdatedAt = link.CreatedAt
+	}
+
+	requested := res.ShortCode
+	for attempt := 0; attempt < importCodeAttempts; attempt++ {
+		if res.ShortCode == "" {
+			res.ShortCode = functions.RandSeq(8)
+		}
+		link.ShortURL = res.ShortCode
+
+		err = storage.InsertLink(handler.storage, link)
+		if !errors.Is(err, apperr.ErrCodeTaken) {
+			break
+		}
+		if res.ShortCode == requested {
+			res.Error = fmt.Sprintf("short code %q is taken, assigned a new one", requested)
+			res.Status = ImportRenamed
+		}
+		res.ShortCode = ""
+	}
+
+	switch {
+	case err == nil:
+		return res, &link
+	case errors.Is(err, apperr.ErrValAlreadyExists):
+		res.Status = ImportConflict
+		res.Error = apperr.ErrLinkExists.Error()
+	default:
+		res.Status = ImportInvalid
+		res.Error = err.Error()
+	}
+
+	return res, nil
+}
+
+// importedLink переносит из строки выгрузки подписи и время, метки
+// перечисляются через запятую.
+func importedLink(row map[string]string) (storage.Link, error) {
+	meta, err := LinkMetadata{
+		Title:       row["title"],
+		Description: row["description"],
+		Tags:        strings.Split(row["tags"], ","),
+	}.normalize()
+	if err != nil {
+		return storage.Link{}, err
+	}
+
+	var link storage.Link
+	meta.apply(&link)
+
+	for column, dst := range map[string]*time.Time{
+		"created_at": &link.CreatedAt,
+		"updated_at": &link.UpdatedAt,
+	} {
+		if *dst, err = parseImportTime(row, column); err != nil {
+			return storage.Link{}, err
+		}
+	}
+
+	lastAccessedAt, err := parseImportTime(row, "last_accessed_at")
+	if err != nil {
+		return storage.Link{}, err
+	}
+	if !lastAccessedAt.IsZero() {
+		link.LastAccessedAt = &lastAccessedAt
+	}
+
+	return link, nil
+}
+
+func parseImportTime(row map[string]string, column string) (time.Time, error) {
+	value := strings.TrimSpace(row[column])
+	if value == "" {
+		return time.Time{}, nil
+	}
+
+	t, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%s must be an RFC 3339 time", column)
+	}
+	return t, nil
+}
+
+func readCSVRows(r io.Reader) ([]map[string]string, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("cannot read csv header: %w", err)
+	}
+	for i := range header {
+		header[i] = strings.ToLower(strings.TrimSpace(header[i]))
+	}
+
+	var rows []map[string]string
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		row := make(map[string]string, len(header))
+		for i, value := range record {
+			if i < len(header) {
+				row[header[i]] = value
+			}
+		}
+		rows = append(rows, row)
+	}
+}
+
+func readJSONLRows(r io.Reader) ([]map[string]string, error) {
+	dec := json.NewDecoder(r)
+
+	var rows []map[string]string
+	for {
+		var raw map[string]any
+		err := dec.Decode(&raw)
+		if err == io.EOF {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		row := make(map[string]string, len(raw))
+		for key, value := range raw {
+			switch value := value.(type) {
+			case string:
+				row[strings.ToLower(key)] = value
+			case []any:
+				// метки в JSONL — массив, в CSV — строка через запятую
+				var items []string
+				for _, item := range value {
+					if str, ok := item.(string); ok {
+						items = append(items, str)
+					}
+				}
+				row[strings.ToLower(key)] = strings.Join(items, ",")
+			}
+		}
+		rows = append(rows, row)
+	}
+}
+
+func pickColumn(row map[string]string, names []string) string {
+	for _, name := range names {
+		if value := strings.TrimSpace(row[name]); value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// shortCodeFrom достаёт код из полной короткой ссылки вида https://bit.ly/abc.
+func shortCodeFrom(value string) string {
+	value = strings.TrimRight(value, "/")
+	if idx := strings.LastIndex(value, "/"); idx >= 0 {
+		value = value[idx+1:]
+	}
+	return value
+}
@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/BazNick/shortlink/internal/app/storage"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	maxTitleLen       = 200
+	maxDescriptionLen = 2000
+	maxTags           = 20
+	maxTagLen         = 50
+)
+
+// LinkMetadata — подписи, которые владелец может дать ссылке при
+// создании и правке.
+type LinkMetadata struct {
+	Title       string   `json:"title,omitempty"`
+	Description string   `json:"description,omitempty"`
+	Tags        []string `json:"tags,omitempty"`
+}
+
+func (m LinkMetadata) empty() bool {
+	return m.Title == "" && m.Description == "" && len(m.Tags) == 0
+}
+
+func (m LinkMetadata) apply(link *storage.Link) {
+	link.Title = m.Title
+	link.Description = m.Description
+	link.Tags = m.Tags
+}
+
+// normalize обрезает пробелы и проверяет длины. Метки приводятся к
+// нижнему регистру, повторы и пустые выбрасываются.
+func (m LinkMetadata) normalize() (LinkMetadata, error) {
+	m.Title = strings.TrimSpace(m.Title)
+	if utf8.RuneCountInString(m.Title) > maxTitleLen {
+		return LinkMetadata{}, fmt.Errorf("title is longer than %d characters", maxTitleLen)
+	}
+
+	m.Description = strings.TrimSpace(m.Description)
+	if utf8.RuneCountInString(m.Description) > maxDescriptionLen {
+		return LinkMetadata{}, fmt.Errorf("description is longer than %d characters", maxDescriptionLen)
+	}
+
+	tags, err := normalizeTags(m.Tags)
+	if err != nil {
+		return LinkMetadata{}, err
+	}
+	m.Tags = tags
+
+	return m, nil
+}
+
+func normalizeTags(raw []string) ([]string, error) {
+	var tags []string
+	for _, tag := range raw {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || slices.Contains(tags, tag) {
+			continue
+		}
+		if utf8.RuneCountInString(tag) > maxTagLen {
+			return nil, fmt.Errorf("tag %q is longer than %d characters", tag, maxTagLen)
+		}
+		tags = append(tags, tag)
+	}
+
+	if len(tags) > maxTags {
+		return nil, fmt.Errorf("a link can have at most %d tags", maxTags)
+	}
+	return tags, nil
+}
+
+// linkMetadata проверяет подписи из запроса и сама отвечает 400, если
+// они не годятся.
+func linkMetadata(c *gin.Context, m LinkMetadata) (LinkMetadata, bool) {
+	m, err := m.normalize()
+	if err != nil {
+		http.Error(c.Writer, err.Error(), http.StatusBadRequest)
+		return LinkMetadata{}, false
+	}
+	return m, true
+}
+
+// tagFilter — метка из ?tag= в том же виде, в каком метки хранятся.
+func tagFilter(c *gin.Context) string {
+	return strings.ToLower(strings.TrimSpace(c.Query("tag")))
+}
@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/BazNick/shortlink/cmd/middleware/auth"
+	"github.com/BazNick/shortlink/internal/app/entities"
+	"github.com/BazNick/shortlink/internal/app/storage"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLinkMetadata(t *testing.T) {
+	keyring, err := auth.LoadKeyring("secret_key", "", "")
+	require.NoError(t, err)
+	token, err := auth.NewToken(keyring)
+	require.NoError(t, err)
+
+	handler := NewURLHandler(entities.NewHashDict(), "test.json", "")
+
+	router := gin.Default()
+	router.Use(auth.Middleware(keyring))
+	router.POST("/api/shorten", handler.PostJSONLink)
+	router.POST("/api/shorten/batch", handler.BatchLinks)
+	router.GET("/api/user/urls", handler.GetUserLinks)
+	router.PATCH("/api/user/urls/:id", handler.PatchUserLink)
+
+	do := func(method, target, body string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(method, "http://localhost:8080"+target, strings.NewReader(body))
+		request.Header.Set(auth.HeaderName, auth.BearerPrefix+token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, request)
+		return w
+	}
+
+	links := func(target string) []storage.Link {
+		w := do(http.MethodGet, target, "")
+		if w.Code == http.StatusNoContent {
+			return nil
+		}
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		var result []storage.Link
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &result))
+		return result
+	}
+
+	w := do(http.MethodPost, "/api/shorten", `{"url":"https://go.dev","title":" Go ","description":"docs","tags":["Lang","lang"," docs ",""]}`)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var created map[string]string
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+	code := strings.TrimPrefix(created["result"], "http://localhost:8080/")
+
+	w = do(http.MethodPost, "/api/shorten/batch", `[{"correlation_id":"1","original_url":"https://ya.ru","tags":["search"]},{"correlation_id":"2","original_url":"https://vk.ru"}]`)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+	tests := []struct {
+		name         string
+		method       string
+		target       string
+		body         string
+		expectedCode int
+	}{
+		{
+			name:         "Too many tags",
+			method:       http.MethodPost,
+			target:       "/api/shorten",
+			body:         `{"url":"https://many.example","tags":["1","2","3","4","5","6","7","8","9","10","11","12","13","14","15","16","17","18","19","20","21"]}`,
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "Title too long",
+			method:       http.MethodPost,
+			target:       "/api/shorten",
+			body:         `{"url":"https://long.example","title":"` + strings.Repeat("a", maxTitleLen+1) + `"}`,
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "Tag too long in a batch",
+			method:       http.MethodPost,
+			target:       "/api/shorten/batch",
+			body:         `[{"correlation_id":"1","original_url":"https://long.example","tags":["` + strings.Repeat("a", maxTagLen+1) + `"]}]`,
+			expectedCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := do(tt.method, tt.target, tt.body)
+			assert.Equal(t, tt.expectedCode, w.Code, w.Body.String())
+		})
+	}
+
+	t.Run("Stored and listed", func(t *testing.T) {
+		result := links("/api/user/urls?tag=LANG")
+		require.Len(t, result, 1)
+		assert.Equal(t, "Go", result[0].Title)
+		assert.Equal(t, "docs", result[0].Description)
+		assert.Equal(t, []string{"lang", "docs"}, result[0].Tags)
+
+		result = links("/api/user/urls?tag=search")
+		require.Len(t, result, 1)
+		assert.Equal(t, "https://ya.ru", result[0].OriginalURL)
+
+		assert.Len(t, links("/api/user/urls"), 3)
+		assert.Empty(t, links("/api/user/urls?tag=missing"))
+	})
+
+	t.Run("Edited", func(t *testing.T) {
+		w := do(http.MethodPatch, "/api/user/urls/"+code, `{"tags":["Docs"]}`)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		assert.Empty(t, links("/api/user/urls?tag=lang"))
+		result := links("/api/user/urls?tag=docs")
+		require.Len(t, result, 1)
+		// не переданные поля не меняются
+		assert.Equal(t, "Go", result[0].Title)
+
+		w = do(http.MethodPatch, "/api/user/urls/"+code, `{"title":"","tags":[]}`)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.Empty(t, links("/api/user/urls?tag=docs"))
+	})
+}
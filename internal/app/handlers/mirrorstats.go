@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/BazNick/shortlink/internal/app/storage"
+	"github.com/gin-gonic/gin"
+)
+
+func (handler *URLHandler) MirrorStats(c *gin.Context) {
+	mirror, ok := storage.As[*storage.Mirror](handler.storage)
+	if !ok {
+		http.Error(c.Writer, "mirroring is disabled", http.StatusNotFound)
+		return
+	}
+
+	writeMirrorStats(c, mirror)
+}
+
+// ReconcileMirror сверяет оба хранилища и отдаёт отчёт о расхождениях,
+// ничего не исправляя. Сверка читает хранилища целиком и заменяет
+// сохранённый отчёт, поэтому доступна только через POST.
+func (handler *URLHandler) ReconcileMirror(c *gin.Context) {
+	mirror, ok := storage.As[*storage.Mirror](handler.storage)
+	if !ok {
+		http.Error(c.Writer, "mirroring is disabled", http.StatusNotFound)
+		return
+	}
+
+	if _, err := mirror.Reconcile(); err != nil {
+		http.Error(c.Writer, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeMirrorStats(c, mirror)
+}
+
+func writeMirrorStats(c *gin.Context, mirror *storage.Mirror) {
+	resp, err := json.Marshal(mirror.Stats())
+	if err != nil {
+		http.Error(c.Writer, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	c.Writer.Header().Set("content-type", "application/json")
+	c.Writer.WriteHeader(http.StatusOK)
+	c.Writer.Write(resp)
+}
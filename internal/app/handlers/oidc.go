@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/BazNick/shortlink/internal/app/functions"
+	"github.com/BazNick/shortlink/internal/app/oidc"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	oidcStateCookie = "oidc_state"
+	oidcStatePath   = "/api/auth/oidc"
+	// столько у пользователя есть на вход у провайдера
+	oidcStateTTL = 10 * time.Minute
+)
+
+type (
+	OIDCHandler struct {
+		provider *oidc.Provider
+		accounts *AccountHandler
+	}
+
+	// oidcState живёт в cookie между переходом к провайдеру и возвратом
+	oidcState struct {
+		State    string `json:"state"`
+		Nonce    string `json:"nonce"`
+		Verifier string `json:"verifier"`
+	}
+)
+
+func NewOIDCHandler(provider *oidc.Provider, accounts *AccountHandler) *OIDCHandler {
+	return &OIDCHandler{
+		provider: provider,
+		accounts: accounts,
+	}
+}
+
+// Login отправляет пользователя на страницу входа провайдера.
+func (handler *OIDCHandler) Login(c *gin.Context) {
+	verifier, challenge := oidc.NewPKCE()
+	state := oidcState{
+		State:    functions.RandSeq(32),
+		Nonce:    functions.RandSeq(32),
+		Verifier: verifier,
+	}
+
+	target, err := handler.provider.AuthURL(c.Request.Context(), state.State, state.Nonce, challenge)
+	if err != nil {
+		http.Error(c.Writer, err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	data, err := json.Marshal(state)
+	if err != nil {
+		http.Error(c.Writer, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(
+		oidcStateCookie,
+		base64.RawURLEncoding.EncodeToString(data),
+		int(oidcStateTTL.Seconds()),
+		oidcStatePath,
+		"",
+		false,
+		true,
+	)
+	c.Redirect(http.StatusFound, target)
+}
+
+// Callback принимает код от провайдера, сопоставляет sub с нашим
+// пользователем и выдаёт обычную сессию.
+func (handler *OIDCHandler) Callback(c *gin.Context) {
+	if reason := c.Query("error"); reason != "" {
+		http.Error(c.Writer, "provider error: "+reason, http.StatusUnauthorized)
+		return
+	}
+
+	state, err := readOIDCState(c)
+	// cookie одноразовая, повторно по ней не войти
+	c.SetCookie(oidcStateCookie, "", -1, oidcStatePath, "", false, true)
+	if err != nil || subtle.ConstantTimeCompare([]byte(state.State), []byte(c.Query("state"))) != 1 {
+		http.Error(c.Writer, "invalid state", http.StatusBadRequest)
+		return
+	}
+
+	claims, err := handler.provider.Exchange(c.Request.Context(), c.Query("code"), state.Verifier, state.Nonce)
+	if errors.Is(err, oidc.ErrInvalidIDToken) || errors.Is(err, oidc.ErrRejected) {
+		http.Error(c.Writer, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	if err != nil {
+		http.Error(c.Writer, err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	user, err := handler.accounts.subjectUser(claims.Subject)
+	if err != nil {
+		http.Error(c.Writer, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	handler.accounts.signIn(c, user, http.StatusOK)
+}
+
+func readOIDCState(c *gin.Context) (oidcState, error) {
+	var state oidcState
+
+	cookie, err := c.Cookie(oidcStateCookie)
+	if err != nil {
+		return state, err
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(cookie)
+	if err != nil {
+		return state, err
+	}
+
+	if err := json.Unmarshal(data, &state); err != nil {
+		return state, err
+	}
+	if state.State == "" {
+		return state, errors.New("empty state")
+	}
+
+	return state, nil
+}
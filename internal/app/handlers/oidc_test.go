@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/BazNick/shortlink/cmd/middleware/auth"
+	"github.com/BazNick/shortlink/internal/app/entities"
+	"github.com/BazNick/shortlink/internal/app/oidc"
+	"github.com/BazNick/shortlink/internal/app/oidc/oidctest"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOIDCLogin(t *testing.T) {
+	issuer := oidctest.NewIssuer(t, "shortener", "client_secret")
+
+	users, err := entities.NewUsers("")
+	require.NoError(t, err)
+	keyring, err := auth.LoadKeyring("secret_key", "", "")
+	require.NoError(t, err)
+
+	accounts := NewAccountHandler(users, entities.NewHashDict(), auth.NewSessions(keyring), nil, nil)
+	handler := NewOIDCHandler(
+		oidc.NewProvider(oidc.Config{
+			Issuer:       issuer.URL,
+			ClientID:     "shortener",
+			ClientSecret: "client_secret",
+			RedirectURL:  "http://localhost:8080/api/auth/oidc/callback",
+		}),
+		accounts,
+	)
+
+	router := gin.Default()
+	router.GET("/api/auth/oidc/login", handler.Login)
+	router.GET("/api/auth/oidc/callback", handler.Callback)
+
+	// login проходит весь путь: переход к провайдеру и возврат с кодом
+	login := func(t *testing.T, state string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/auth/oidc/login", nil))
+		require.Equal(t, http.StatusFound, w.Code)
+
+		back := issuer.Authorize(t, w.Header().Get("Location"))
+		if state != "" {
+			q := back.Query()
+			q.Set("state", state)
+			back.RawQuery = q.Encode()
+		}
+
+		request := httptest.NewRequest(http.MethodGet, back.String(), nil)
+		for _, cookie := range w.Result().Cookies() {
+			request.AddCookie(cookie)
+		}
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, request)
+		return w
+	}
+
+	tests := []struct {
+		name         string
+		subject      string
+		state        string
+		expectedCode int
+	}{
+		{name: "First login creates the user", subject: "alice", expectedCode: http.StatusOK},
+		{name: "Second login maps to the same user", subject: "alice", expectedCode: http.StatusOK},
+		{name: "Another subject is another user", subject: "bob", expectedCode: http.StatusOK},
+		{name: "Forged state", subject: "alice", state: "forged", expectedCode: http.StatusBadRequest},
+	}
+
+	ids := make(map[string]string)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			issuer.Subject = tt.subject
+
+			w := login(t, tt.state)
+			require.Equal(t, tt.expectedCode, w.Code)
+			if tt.expectedCode != http.StatusOK {
+				return
+			}
+
+			var account AccountOut
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &account))
+			assert.Equal(t, "oidc:"+tt.subject, account.Login)
+
+			claims, err := keyring.Parse(account.AccessToken)
+			require.NoError(t, err)
+			assert.Equal(t, account.UserID, claims.UserID)
+
+			if id, ok := ids[tt.subject]; ok {
+				assert.Equal(t, id, account.UserID)
+			}
+			ids[tt.subject] = account.UserID
+		})
+	}
+
+	assert.NotEqual(t, ids["alice"], ids["bob"])
+}
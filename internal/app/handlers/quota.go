@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/BazNick/shortlink/cmd/middleware/auth"
+	"github.com/BazNick/shortlink/internal/app/functions"
+	"github.com/BazNick/shortlink/internal/app/quota"
+	"github.com/gin-gonic/gin"
+)
+
+// GetUserQuota — сколько лимитов пользователь уже израсходовал.
+func (handler *URLHandler) GetUserQuota(c *gin.Context) {
+	user, err := functions.GetUser(c)
+	if err != nil {
+		c.Writer.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	usage, err := handler.quota.Usage(user, quotaCounter(c, user))
+	if err != nil {
+		http.Error(c.Writer, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(c, http.StatusOK, usage)
+}
+
+// reserveQuota списывает n ссылок с лимитов пользователя. При отказе ответ
+// уже отправлен, иначе резервирование нужно закрыть через Done.
+func reserveQuota(c *gin.Context, q *quota.Quota, user string, n int) (*quota.Reservation, bool) {
+	reservation, err := q.Reserve(user, quotaCounter(c, user), n)
+	if err != nil {
+		writeQuotaError(c, err)
+		return nil, false
+	}
+
+	return reservation, true
+}
+
+func writeQuotaError(c *gin.Context, err error) {
+	var quotaErr *quota.Error
+	if !errors.As(err, &quotaErr) {
+		http.Error(c.Writer, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if quotaErr.ResetsAt != nil {
+		retry := time.Until(*quotaErr.ResetsAt).Round(time.Second)
+		c.Writer.Header().Set("Retry-After", strconv.Itoa(int(retry.Seconds())))
+	}
+	writeJSON(c, quotaErr.Status(), quotaErr)
+}
+
+// quotaCounter — чей суточный счётчик. Без cookie анонимный пользователь
+// новый на каждый запрос, поэтому таких считаем по адресу.
+func quotaCounter(c *gin.Context, user string) string {
+	if c.GetBool(auth.NewUserKey) {
+		return "ip:" + c.ClientIP()
+	}
+	return "user:" + user
+}
@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/BazNick/shortlink/cmd/middleware/auth"
+	"github.com/BazNick/shortlink/internal/app/entities"
+	"github.com/BazNick/shortlink/internal/app/quota"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQuota(t *testing.T) {
+	keyring, err := auth.LoadKeyring("secret_key", "", "")
+	require.NoError(t, err)
+	token, err := auth.NewToken(keyring)
+	require.NoError(t, err)
+
+	store := entities.NewHashDict()
+	handler := NewURLHandler(
+		store,
+		"test.json",
+		"",
+		WithQuota(quota.New(store, entities.NewBuckets(48*time.Hour), quota.Limits{
+			MaxLinks:   10,
+			MaxBatch:   2,
+			DailyLinks: 2,
+		})),
+	)
+
+	router := gin.Default()
+	router.Use(auth.Middleware(keyring))
+	router.POST("/api/shorten", handler.PostJSONLink)
+	router.POST("/api/shorten/batch", handler.BatchLinks)
+	router.GET("/api/user/quota", handler.GetUserQuota)
+
+	do := func(method, target, body string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(method, "http://localhost:8080"+target, strings.NewReader(body))
+		request.Header.Set(auth.HeaderName, auth.BearerPrefix+token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, request)
+		return w
+	}
+
+	tests := []struct {
+		name         string
+		method       string
+		target       string
+		body         string
+		expectedCode int
+		check        func(t *testing.T, w *httptest.ResponseRecorder)
+	}{
+		{
+			name:         "Batch over the limit",
+			method:       http.MethodPost,
+			target:       "/api/shorten/batch",
+			body:         `[{"correlation_id":"1","original_url":"https://a.ru"},{"correlation_id":"2","original_url":"https://b.ru"},{"correlation_id":"3","original_url":"https://c.ru"}]`,
+			expectedCode: http.StatusForbidden,
+			check: func(t *testing.T, w *httptest.ResponseRecorder) {
+				var quotaErr quota.Error
+				require.NoError(t, json.Unmarshal(w.Body.Bytes(), &quotaErr))
+				assert.Equal(t, quota.KindBatch, quotaErr.Kind)
+				assert.Equal(t, 2, quotaErr.Limit)
+			},
+		},
+		{
+			name:         "First link",
+			method:       http.MethodPost,
+			target:       "/api/shorten",
+			body:         `{"url":"https://ya.ru"}`,
+			expectedCode: http.StatusCreated,
+		},
+		{
+			name:         "Second link",
+			method:       http.MethodPost,
+			target:       "/api/shorten",
+			body:         `{"url":"https://go.dev"}`,
+			expectedCode: http.StatusCreated,
+		},
+		{
+			name:         "Daily limit reached",
+			method:       http.MethodPost,
+			target:       "/api/shorten",
+			body:         `{"url":"https://vk.ru"}`,
+			expectedCode: http.StatusTooManyRequests,
+			check: func(t *testing.T, w *httptest.ResponseRecorder) {
+				assert.NotEmpty(t, w.Header().Get("Retry-After"))
+
+				var quotaErr quota.Error
+				require.NoError(t, json.Unmarshal(w.Body.Bytes(), &quotaErr))
+				assert.Equal(t, quota.KindDaily, quotaErr.Kind)
+				assert.Equal(t, 2, quotaErr.Used)
+				assert.NotNil(t, quotaErr.ResetsAt)
+			},
+		},
+		{
+			name:         "Current usage",
+			method:       http.MethodGet,
+			target:       "/api/user/quota",
+			expectedCode: http.StatusOK,
+			check: func(t *testing.T, w *httptest.ResponseRecorder) {
+				var usage quota.Usage
+				require.NoError(t, json.Unmarshal(w.Body.Bytes(), &usage))
+				assert.Equal(t, quota.Counter{Used: 2, Limit: 10}, usage.Links)
+				assert.Equal(t, 2, usage.Daily.Used)
+				assert.Equal(t, 2, usage.MaxBatch)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := do(tt.method, tt.target, tt.body)
+			require.Equal(t, tt.expectedCode, w.Code, w.Body.String())
+			if tt.check != nil {
+				tt.check(t, w)
+			}
+		})
+	}
+}
@@ -0,0 +1,297 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/BazNick/shortlink/cmd/middleware/auth"
+	"github.com/BazNick/shortlink/internal/app/apperr"
+	"github.com/BazNick/shortlink/internal/app/audit"
+	"github.com/BazNick/shortlink/internal/app/functions"
+	"github.com/BazNick/shortlink/internal/app/storage"
+	"github.com/gin-gonic/gin"
+)
+
+const maxReportReasonLen = 500
+
+// решения модератора по жалобе
+const (
+	ModerationDismiss = "dismiss"
+	ModerationDisable = "disable"
+	ModerationEnable  = "enable"
+)
+
+var disabledPage = template.Must(template.New("disabled").Parse(`<!doctype html>
+<html>
+<head><meta charset="utf-8"><title>Link disabled</title></head>
+<body>
+<h1>This link has been disabled</h1>
+<p>The short link was disabled by the moderators and no longer leads anywhere.</p>
+</body>
+</html>
+`))
+
+type (
+	// ReportHandler — жалобы на ссылки и очередь модерации. Когда на
+	// ссылку пожаловались с threshold разных адресов, она отключается до
+	// решения администратора; 0 отключает это. Считаются адреса, а не
+	// пользователи: анонимный токен получить ничего не стоит.
+	ReportHandler struct {
+		links     storage.Storage
+		reports   storage.ReportStore
+		audit     *audit.Log
+		threshold int
+	}
+
+	ReportIn struct {
+		Reason string `json:"reason"`
+	}
+
+	ReportOut struct {
+		ID     string `json:"id"`
+		Status string `json:"status"`
+	}
+
+	ModerationIn struct {
+		Action string `json:"action"`
+	}
+
+	ModerationOut struct {
+		Resolved int           `json:"resolved"`
+		Link     *storage.Link `json:"link,omitempty"`
+	}
+)
+
+func NewReportHandler(
+	links storage.Storage,
+	reports storage.ReportStore,
+	log *audit.Log,
+	threshold int,
+) *ReportHandler {
+	return &ReportHandler{
+		links:     links,
+		reports:   reports,
+		audit:     log,
+		threshold: threshold,
+	}
+}
+
+// Report принимает жалобу на ссылку от кого угодно. Один человек может
+// держать только одну открытую жалобу на ссылку.
+func (handler *ReportHandler) Report(c *gin.Context) {
+	var in ReportIn
+	if err := json.NewDecoder(c.Request.Body).Decode(&in); err != nil {
+		http.Error(c.Writer, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	in.Reason = strings.TrimSpace(in.Reason)
+	if in.Reason == "" || utf8.RuneCountInString(in.Reason) > maxReportReasonLen {
+		http.Error(c.Writer, fmt.Sprintf("reason must be 1 to %d characters", maxReportReasonLen), http.StatusBadRequest)
+		return
+	}
+
+	link, err := handler.links.GetLink(c.Param("id"))
+	if err == nil && link.IsDeleted {
+		err = apperr.ErrLinkNotFound
+	}
+	if errors.Is(err, apperr.ErrLinkNotFound) {
+		http.Error(c.Writer, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(c.Writer, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	report := storage.Report{
+		ID:        functions.RandSeq(16),
+		ShortURL:  link.ShortURL,
+		Reason:    in.Reason,
+		IP:        c.ClientIP(),
+		CreatedAt: time.Now(),
+		Status:    storage.ReportOpen,
+	}
+	// без cookie анонимный пользователь новый на каждый запрос
+	if !c.GetBool(auth.NewUserKey) {
+		report.ReporterID = c.GetString("userID")
+	}
+
+	var (
+		reporters = map[string]bool{}
+		addresses = map[string]bool{}
+	)
+	err = handler.reports.Reports(storage.ReportFilter{
+		ShortURL: link.ShortURL,
+		Status:   storage.ReportOpen,
+	}, func(open storage.Report) error {
+		reporters[open.Reporter()] = true
+		addresses[open.IP] = true
+		return nil
+	})
+	if err != nil {
+		http.Error(c.Writer, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if reporters[report.Reporter()] {
+		http.Error(c.Writer, "link is already reported", http.StatusConflict)
+		return
+	}
+
+	if err := handler.reports.AddReport(report); err != nil {
+		http.Error(c.Writer, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	addresses[report.IP] = true
+
+	if handler.threshold > 0 && len(addresses) >= handler.threshold && !link.IsDisabled {
+		handler.autoDisable(c, link.ShortURL, len(addresses))
+	}
+
+	writeJSON(c, http.StatusAccepted, ReportOut{ID: report.ID, Status: report.Status})
+}
+
+// autoDisable отключает ссылку по жалобам. Жалоба уже принята, поэтому
+// ошибка только попадает в журнал.
+func (handler *ReportHandler) autoDisable(c *gin.Context, hash string, reports int) {
+	var before storage.Link
+	link, err := handler.links.UpdateLink(hash, func(link *storage.Link) error {
+		before = *link
+		link.IsDisabled = true
+		return nil
+	})
+	if err != nil {
+		log.Printf("auto-disable %s: %v", hash, err)
+		return
+	}
+
+	handler.audit.Add(
+		audit.Source(c),
+		storage.AuditDisable,
+		&before,
+		&link,
+		fmt.Sprintf("disabled after %d reports", reports),
+	)
+}
+
+// ListReports — очередь модерации: по умолчанию открытые жалобы, старые
+// первыми. Фильтры: ?status=, ?code=.
+func (handler *ReportHandler) ListReports(c *gin.Context) {
+	limit, ok := adminLimit(c)
+	if !ok {
+		return
+	}
+
+	filter := storage.ReportFilter{
+		ShortURL: shortCodeFrom(c.Query("code")),
+		Status:   c.DefaultQuery("status", storage.ReportOpen),
+	}
+	if filter.Status == "all" {
+		filter.Status = ""
+	}
+
+	result := []storage.Report{}
+	err := handler.reports.Reports(filter, func(report storage.Report) error {
+		result = append(result, report)
+		if len(result) >= limit {
+			return errLimit
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errLimit) {
+		http.Error(c.Writer, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(c, http.StatusOK, result)
+}
+
+// ResolveReport — решение по жалобе, которое относится ко всем открытым
+// жалобам на ту же ссылку: dismiss их отклоняет, disable отключает
+// ссылку, enable отклоняет жалобы и включает ссылку обратно, если её
+// отключили по ошибке.
+func (handler *ReportHandler) ResolveReport(c *gin.Context) {
+	var in ModerationIn
+	if err := json.NewDecoder(c.Request.Body).Decode(&in); err != nil {
+		http.Error(c.Writer, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var (
+		status   string
+		disabled bool
+		action   string
+	)
+	switch in.Action {
+	case ModerationDismiss:
+		status = storage.ReportDismissed
+	case ModerationDisable:
+		status, disabled, action = storage.ReportActioned, true, storage.AuditDisable
+	case ModerationEnable:
+		status, disabled, action = storage.ReportDismissed, false, storage.AuditEnable
+	default:
+		http.Error(c.Writer, "action must be dismiss, disable or enable", http.StatusBadRequest)
+		return
+	}
+
+	var report *storage.Report
+	err := handler.reports.Reports(storage.ReportFilter{ID: c.Param("id")}, func(r storage.Report) error {
+		report = &r
+		return nil
+	})
+	if err != nil {
+		http.Error(c.Writer, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if report == nil {
+		http.Error(c.Writer, "report not found", http.StatusNotFound)
+		return
+	}
+
+	var out ModerationOut
+	if action != "" {
+		var before storage.Link
+		link, err := handler.links.UpdateLink(report.ShortURL, func(link *storage.Link) error {
+			before = *link
+			link.IsDisabled = disabled
+			return nil
+		})
+		if err != nil && !errors.Is(err, apperr.ErrLinkNotFound) {
+			http.Error(c.Writer, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		// ссылку могли уже удалить, жалобы всё равно нужно закрыть
+		if err == nil {
+			if before.IsDisabled != link.IsDisabled {
+				handler.audit.Record(c, action, &before, &link)
+			}
+			out.Link = &link
+		}
+	}
+
+	out.Resolved, err = handler.reports.ResolveReports(
+		storage.ReportFilter{ShortURL: report.ShortURL},
+		status,
+		c.GetString("userID"),
+		time.Now(),
+	)
+	if err != nil {
+		http.Error(c.Writer, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(c, http.StatusOK, out)
+}
+
+func writeDisabledPage(c *gin.Context) {
+	c.Writer.Header().Set("Content-Type", "text/html; charset=utf-8")
+	c.Writer.WriteHeader(http.StatusGone)
+	disabledPage.Execute(c.Writer, nil)
+}
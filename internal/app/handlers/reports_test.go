@@ -0,0 +1,217 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/BazNick/shortlink/cmd/middleware/auth"
+	"github.com/BazNick/shortlink/internal/app/audit"
+	"github.com/BazNick/shortlink/internal/app/entities"
+	"github.com/BazNick/shortlink/internal/app/storage"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReports(t *testing.T) {
+	keyring, err := auth.LoadKeyring("secret_key", "", "")
+	require.NoError(t, err)
+
+	var tokens []string
+	for i := 0; i < 3; i++ {
+		token, err := auth.NewToken(keyring)
+		require.NoError(t, err)
+		tokens = append(tokens, token)
+	}
+
+	store := entities.NewHashDict()
+	store.AddHash("phishing", "https://phish.example/", "owner")
+	store.AddHash("innocent", "https://example.com/", "owner")
+	store.AddHash("targeted", "https://example.org/", "owner")
+
+	queue, err := entities.NewReports("")
+	require.NoError(t, err)
+	entries, err := entities.NewAuditLog("")
+	require.NoError(t, err)
+
+	var (
+		reports = NewReportHandler(store, queue, audit.New(entries), 2)
+		links   = NewURLHandler(store, "test.json", "")
+	)
+
+	router := gin.Default()
+	router.Use(auth.Middleware(keyring))
+	router.GET("/:id", links.GetLink)
+	router.POST("/:id/report", reports.Report)
+	router.GET("/api/admin/reports", reports.ListReports)
+	router.POST("/api/admin/reports/:id", reports.ResolveReport)
+
+	do := func(method, target, body string, token int) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(method, "http://localhost:8080"+target, strings.NewReader(body))
+		request.Header.Set(auth.HeaderName, auth.BearerPrefix+tokens[token])
+		// у каждого жалобщика свой адрес
+		request.RemoteAddr = fmt.Sprintf("192.0.2.%d:1234", token+1)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, request)
+		return w
+	}
+
+	queued := func(t *testing.T, query string) []storage.Report {
+		w := do(http.MethodGet, "/api/admin/reports"+query, "", 0)
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var result []storage.Report
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &result))
+		return result
+	}
+
+	tests := []struct {
+		name         string
+		method       string
+		target       string
+		body         string
+		token        int
+		expectedCode int
+		check        func(t *testing.T, w *httptest.ResponseRecorder)
+	}{
+		{
+			name:         "Report without reason",
+			method:       http.MethodPost,
+			target:       "/phishing/report",
+			body:         `{"reason":"  "}`,
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "Report of unknown link",
+			method:       http.MethodPost,
+			target:       "/unknown1/report",
+			body:         `{"reason":"spam"}`,
+			expectedCode: http.StatusNotFound,
+		},
+		{
+			name:         "First report",
+			method:       http.MethodPost,
+			target:       "/phishing/report",
+			body:         `{"reason":"fake bank login"}`,
+			expectedCode: http.StatusAccepted,
+			check: func(t *testing.T, w *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusTemporaryRedirect, do(http.MethodGet, "/phishing", "", 0).Code)
+			},
+		},
+		{
+			name:         "Same reporter again",
+			method:       http.MethodPost,
+			target:       "/phishing/report",
+			body:         `{"reason":"still phishing"}`,
+			expectedCode: http.StatusConflict,
+		},
+		{
+			name:         "Threshold disables the link",
+			method:       http.MethodPost,
+			target:       "/phishing/report",
+			body:         `{"reason":"phishing"}`,
+			token:        1,
+			expectedCode: http.StatusAccepted,
+			check: func(t *testing.T, w *httptest.ResponseRecorder) {
+				page := do(http.MethodGet, "/phishing", "", 0)
+				assert.Equal(t, http.StatusGone, page.Code)
+				assert.Contains(t, page.Body.String(), "has been disabled")
+
+				var disabled []storage.AuditEntry
+				entries.AuditEntries(storage.AuditFilter{Action: storage.AuditDisable}, func(e storage.AuditEntry) error {
+					disabled = append(disabled, e)
+					return nil
+				})
+				require.Len(t, disabled, 1)
+				assert.Equal(t, "disabled after 2 reports", disabled[0].Note)
+			},
+		},
+		{
+			name:         "Report of another link",
+			method:       http.MethodPost,
+			target:       "/innocent/report",
+			body:         `{"reason":"i do not like it"}`,
+			token:        2,
+			expectedCode: http.StatusAccepted,
+			check: func(t *testing.T, w *httptest.ResponseRecorder) {
+				result := queued(t, "")
+				require.Len(t, result, 3)
+				assert.Equal(t, "fake bank login", result[0].Reason)
+				assert.Len(t, queued(t, "?code=innocent"), 1)
+			},
+		},
+		{
+			name:         "Unknown moderation action",
+			method:       http.MethodPost,
+			target:       "/api/admin/reports/unknown",
+			body:         `{"action":"ban"}`,
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "Unknown report",
+			method:       http.MethodPost,
+			target:       "/api/admin/reports/unknown",
+			body:         `{"action":"dismiss"}`,
+			expectedCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := do(tt.method, tt.target, tt.body, tt.token)
+			require.Equal(t, tt.expectedCode, w.Code, w.Body.String())
+			if tt.check != nil {
+				tt.check(t, w)
+			}
+		})
+	}
+
+	t.Run("Moderation", func(t *testing.T) {
+		innocent := queued(t, "?code=innocent")[0]
+		w := do(http.MethodPost, "/api/admin/reports/"+innocent.ID, `{"action":"dismiss"}`, 0)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.Equal(t, http.StatusTemporaryRedirect, do(http.MethodGet, "/innocent", "", 0).Code)
+
+		// решение по одной жалобе закрывает все жалобы на ссылку
+		phishing := queued(t, "?code=phishing")[1]
+		w = do(http.MethodPost, "/api/admin/reports/"+phishing.ID, `{"action":"disable"}`, 0)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		var out ModerationOut
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &out))
+		assert.Equal(t, 2, out.Resolved)
+		assert.True(t, out.Link.IsDisabled)
+
+		assert.Empty(t, queued(t, ""))
+		closed := queued(t, "?status=actioned")
+		require.Len(t, closed, 2)
+		assert.NotNil(t, closed[0].ResolvedAt)
+
+		// новая жалоба после решения снова попадает в очередь
+		assert.Equal(t, http.StatusAccepted, do(http.MethodPost, "/phishing/report", `{"reason":"x"}`, 2).Code)
+		w = do(http.MethodPost, "/api/admin/reports/"+queued(t, "")[0].ID, `{"action":"enable"}`, 0)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.Equal(t, http.StatusTemporaryRedirect, do(http.MethodGet, "/phishing", "", 0).Code)
+	})
+
+	t.Run("One address with fresh tokens", func(t *testing.T) {
+		for i := 0; i < 4; i++ {
+			token, err := auth.NewToken(keyring)
+			require.NoError(t, err)
+
+			request := httptest.NewRequest(http.MethodPost, "http://localhost:8080/targeted/report", strings.NewReader(`{"reason":"spam"}`))
+			request.Header.Set(auth.HeaderName, auth.BearerPrefix+token)
+			request.RemoteAddr = "198.51.100.7:1234"
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, request)
+			require.Equal(t, http.StatusAccepted, w.Code, w.Body.String())
+		}
+
+		assert.Equal(t, http.StatusTemporaryRedirect, do(http.MethodGet, "/targeted", "", 0).Code)
+		assert.Len(t, queued(t, "?code=targeted"), 4)
+	})
+}
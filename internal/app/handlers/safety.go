@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"html/template"
+	"net/http"
+
+	"github.com/BazNick/shortlink/internal/app/safety"
+	"github.com/gin-gonic/gin"
+)
+
+// UnsafeURLError — ссылка, которую проверка безопасности сочла опасной.
+type UnsafeURLError struct {
+	Message string `json:"error"`
+	safety.Verdict
+	URL string `json:"url"`
+	// только для пакета ссылок
+	Index         *int   `json:"index,omitempty"`
+	CorrelationID string `json:"correlation_id,omitempty"`
+}
+
+var warningPage = template.Must(template.New("warning").Parse(`<!doctype html>
+<html>
+<head><meta charset="utf-8"><title>Unsafe link</title></head>
+<body>
+<h1>This link may be unsafe</h1>
+<p>The short link leads to <code>{{.URL}}</code>, which was flagged: {{.Reason}}{{with .Detail}} ({{.}}){{end}}.</p>
+<p>It may be used for phishing or to spread malware. If you trust it, <a href="{{.Confirm}}" rel="noreferrer">continue anyway</a>.</p>
+</body>
+</html>
+`))
+
+// checkSafety проверяет ссылку перед сохранением. При отказе ответ уже
+// отправлен.
+func checkSafety(c *gin.Context, checker safety.Checker, link string) bool {
+	verdict := flagged(c, checker, link)
+	if !verdict.Flagged() {
+		return true
+	}
+
+	writeJSON(c, http.StatusBadRequest, UnsafeURLError{
+		Message: "url is flagged as unsafe",
+		Verdict: verdict,
+		URL:     link,
+	})
+	return false
+}
+
+// flagged проверяет ссылку, ошибки проверок пропускаются.
+func flagged(c *gin.Context, checker safety.Checker, link string) safety.Verdict {
+	if checker == nil {
+		return safety.Verdict{}
+	}
+
+	ctx := safety.WithHost(c.Request.Context(), c.Request.Host)
+	verdict, _ := checker.Check(ctx, link)
+	return verdict
+}
+
+func writeWarningPage(c *gin.Context, link string, verdict safety.Verdict) {
+	c.Writer.Header().Set("Content-Type", "text/html; charset=utf-8")
+	c.Writer.WriteHeader(http.StatusOK)
+	warningPage.Execute(c.Writer, map[string]string{
+		"URL":     link,
+		"Reason":  verdict.Reason,
+		"Detail":  verdict.Detail,
+		"Confirm": c.Request.URL.Path + "?confirm=1",
+	})
+}
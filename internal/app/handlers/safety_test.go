@@ -0,0 +1,174 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/BazNick/shortlink/cmd/middleware/auth"
+	"github.com/BazNick/shortlink/internal/app/entities"
+	"github.com/BazNick/shortlink/internal/app/safety"
+	"github.com/BazNick/shortlink/internal/app/safety/safetytest"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSafety(t *testing.T) {
+	keyring, err := auth.LoadKeyring("secret_key", "", "")
+	require.NoError(t, err)
+	token, err := auth.NewToken(keyring)
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "blocklist.txt")
+	require.NoError(t, os.WriteFile(path, []byte("evil.example\n"), 0o644))
+	blocklist, err := safety.LoadBlocklist(path)
+	require.NoError(t, err)
+	lookup := safetytest.NewServer(t, "https://phish.example/")
+
+	store := entities.NewHashDict()
+	handler := NewURLHandler(
+		store,
+		"test.json",
+		"",
+		WithSafety(safety.Chain{
+			safety.NewSelfHosts("https://sho.rt"),
+			blocklist,
+			safety.NewLookup(lookup.URL, nil, 0),
+		}),
+	)
+	store.AddHash("later001", "https://later.example/", "someone")
+
+	router := gin.Default()
+	router.Use(auth.Middleware(keyring))
+	router.GET("/:id", handler.GetLink)
+	router.POST("/api/shorten", handler.PostJSONLink)
+	router.POST("/api/shorten/batch", handler.BatchLinks)
+
+	do := func(method, target, body string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(method, "http://localhost:8080"+target, strings.NewReader(body))
+		request.Header.Set(auth.HeaderName, auth.BearerPrefix+token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, request)
+		return w
+	}
+
+	unsafe := func(reason string) func(t *testing.T, w *httptest.ResponseRecorder) {
+		return func(t *testing.T, w *httptest.ResponseRecorder) {
+			var urlErr UnsafeURLError
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &urlErr))
+			assert.Equal(t, reason, urlErr.Reason)
+		}
+	}
+
+	tests := []struct {
+		name         string
+		method       string
+		target       string
+		body         string
+		before       func()
+		expectedCode int
+		check        func(t *testing.T, w *httptest.ResponseRecorder)
+	}{
+		{
+			name:         "Safe link",
+			method:       http.MethodPost,
+			target:       "/api/shorten",
+			body:         `{"url":"https://example.com/"}`,
+			expectedCode: http.StatusCreated,
+		},
+		{
+			name:         "Blocked subdomain",
+			method:       http.MethodPost,
+			target:       "/api/shorten",
+			body:         `{"url":"https://login.evil.example/"}`,
+			expectedCode: http.StatusBadRequest,
+			check:        unsafe(safety.ReasonBlocklist),
+		},
+		{
+			name:         "Flagged by lookup",
+			method:       http.MethodPost,
+			target:       "/api/shorten",
+			body:         `{"url":"https://phish.example/"}`,
+			expectedCode: http.StatusBadRequest,
+			check:        unsafe(safety.ReasonLookup),
+		},
+		{
+			name:         "Link to the configured host",
+			method:       http.MethodPost,
+			target:       "/api/shorten",
+			body:         `{"url":"https://sho.rt/abc"}`,
+			expectedCode: http.StatusBadRequest,
+			check:        unsafe(safety.ReasonLoop),
+		},
+		{
+			name:         "Link to the request host",
+			method:       http.MethodPost,
+			target:       "/api/shorten",
+			body:         `{"url":"http://localhost:8080/abc"}`,
+			expectedCode: http.StatusBadRequest,
+			check:        unsafe(safety.ReasonLoop),
+		},
+		{
+			name:         "Batch with a blocked link",
+			method:       http.MethodPost,
+			target:       "/api/shorten/batch",
+			body:         `[{"correlation_id":"a","original_url":"https://ok.example/"},{"correlation_id":"b","original_url":"https://evil.example/x"}]`,
+			expectedCode: http.StatusBadRequest,
+			check: func(t *testing.T, w *httptest.ResponseRecorder) {
+				var urlErr UnsafeURLError
+				require.NoError(t, json.Unmarshal(w.Body.Bytes(), &urlErr))
+				require.NotNil(t, urlErr.Index)
+				assert.Equal(t, 1, *urlErr.Index)
+				assert.Equal(t, "b", urlErr.CorrelationID)
+			},
+		},
+		{
+			name:         "Redirect to a link not yet blocked",
+			method:       http.MethodGet,
+			target:       "/later001",
+			expectedCode: http.StatusTemporaryRedirect,
+		},
+		{
+			name:   "Warning for a link blocked later",
+			method: http.MethodGet,
+			target: "/later001",
+			before: func() {
+				require.NoError(t, os.WriteFile(path, []byte("evil.example\nlater.example\n"), 0o644))
+				require.NoError(t, blocklist.Reload())
+			},
+			expectedCode: http.StatusOK,
+			check: func(t *testing.T, w *httptest.ResponseRecorder) {
+				assert.Empty(t, w.Header().Get("Location"))
+				assert.Contains(t, w.Body.String(), "https://later.example/")
+				assert.Contains(t, w.Body.String(), `href="/later001?confirm=1"`)
+			},
+		},
+		{
+			name:         "Confirmed redirect",
+			method:       http.MethodGet,
+			target:       "/later001?confirm=1",
+			expectedCode: http.StatusTemporaryRedirect,
+			check: func(t *testing.T, w *httptest.ResponseRecorder) {
+				assert.Equal(t, "https://later.example/", w.Header().Get("Location"))
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.before != nil {
+				tt.before()
+			}
+			w := do(tt.method, tt.target, tt.body)
+			require.Equal(t, tt.expectedCode, w.Code, w.Body.String())
+			if tt.check != nil {
+				tt.check(t, w)
+			}
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/BazNick/shortlink/internal/app/functions"
+	"github.com/BazNick/shortlink/internal/app/storage"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	maxSearchLen = 200
+
+	searchDefaultLimit = 20
+	searchMaxLimit     = 100
+)
+
+// SearchUserLinks ищет ?q= по адресам, заголовкам, описаниям и меткам
+// ссылок пользователя. Самые подходящие ссылки идут первыми.
+func (handler *URLHandler) SearchUserLinks(c *gin.Context) {
+	user, err := functions.GetUser(c)
+	if err != nil {
+		c.Writer.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	text := strings.TrimSpace(c.Query("q"))
+	if text == "" {
+		http.Error(c.Writer, "q is required", http.StatusBadRequest)
+		return
+	}
+	if len([]rune(text)) > maxSearchLen {
+		http.Error(c.Writer, "q is too long", http.StatusBadRequest)
+		return
+	}
+
+	limit, ok := searchLimit(c)
+	if !ok {
+		return
+	}
+
+	results, err := storage.SearchLinks(handler.storage, storage.SearchQuery{
+		UserID: user,
+		Text:   text,
+		Limit:  limit,
+	})
+	if errors.Is(err, storage.ErrSearchUnsupported) {
+		http.Error(c.Writer, err.Error(), http.StatusNotImplemented)
+		return
+	}
+	if err != nil {
+		http.Error(c.Writer, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	for i := range results {
+		results[i].ShortURL = functions.SchemeAndHost(c.Request) + "/" + results[i].ShortURL
+		results[i].UserID = ""
+	}
+	if results == nil {
+		results = []storage.SearchResult{}
+	}
+
+	writeJSON(c, http.StatusOK, results)
+}
+
+func searchLimit(c *gin.Context) (int, bool) {
+	raw := c.Query("limit")
+	if raw == "" {
+		return searchDefaultLimit, true
+	}
+
+	limit, err := strconv.Atoi(raw)
+	if err != nil || limit <= 0 || limit > searchMaxLimit {
+		http.Error(c.Writer, "limit must be between 1 and 100", http.StatusBadRequest)
+		return 0, false
+	}
+	return limit, true
+}
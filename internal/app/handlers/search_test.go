@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/BazNick/shortlink/cmd/middleware/auth"
+	"github.com/BazNick/shortlink/internal/app/entities"
+	"github.com/BazNick/shortlink/internal/app/search"
+	"github.com/BazNick/shortlink/internal/app/storage"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSearchUserLinks(t *testing.T) {
+	keyring, err := auth.LoadKeyring("secret_key", "", "")
+	require.NoError(t, err)
+	token, err := auth.NewToken(keyring)
+	require.NoError(t, err)
+
+	indexed, err := search.NewIndexed(entities.NewHashDict())
+	require.NoError(t, err)
+
+	var (
+		handler  = NewURLHandler(indexed, "test.json", "")
+		plain    = NewURLHandler(entities.NewHashDict(), "test.json", "")
+		router   = gin.Default()
+		withAuth = router.Group("", auth.Middleware(keyring))
+	)
+	withAuth.POST("/api/shorten", handler.PostJSONLink)
+	withAuth.GET("/api/user/urls/search", handler.SearchUserLinks)
+	withAuth.GET("/plain/search", plain.SearchUserLinks)
+
+	do := func(method, target, body string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(method, "http://localhost:8080"+target, strings.NewReader(body))
+		request.Header.Set(auth.HeaderName, auth.BearerPrefix+token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, request)
+		return w
+	}
+
+	for _, body := range []string{
+		`{"url":"https://jira.example.com/browse/OPS-7","title":"Incident from last week"}`,
+		`{"url":"https://wiki.example.com/ops","tags":["jira"]}`,
+		`{"url":"https://ya.ru"}`,
+	} {
+		require.Equal(t, http.StatusCreated, do(http.MethodPost, "/api/shorten", body).Code)
+	}
+
+	tests := []struct {
+		name         string
+		target       string
+		expectedCode int
+		want         []string
+	}{
+		{
+			name:         "Ranked by matched words",
+			target:       "/api/user/urls/search?q=that+jira+link+from+last+week",
+			expectedCode: http.StatusOK,
+			want:         []string{"https://jira.example.com/browse/OPS-7", "https://wiki.example.com/ops"},
+		},
+		{
+			name:         "Nothing found",
+			target:       "/api/user/urls/search?q=confluence",
+			expectedCode: http.StatusOK,
+			want:         []string{},
+		},
+		{
+			name:         "Empty query",
+			target:       "/api/user/urls/search?q=+",
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "Backslashes and quotes are plain text",
+			target:       `/api/user/urls/search?q=jira%5C%27`,
+			expectedCode: http.StatusOK,
+			want:         []string{"https://wiki.example.com/ops", "https://jira.example.com/browse/OPS-7"},
+		},
+		{
+			name:         "Limit above the maximum",
+			target:       "/api/user/urls/search?q=jira&limit=101",
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "Storage without search",
+			target:       "/plain/search?q=jira",
+			expectedCode: http.StatusNotImplemented,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := do(http.MethodGet, tt.target, "")
+			require.Equal(t, tt.expectedCode, w.Code, w.Body.String())
+			if tt.want == nil {
+				return
+			}
+
+			var results []storage.SearchResult
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &results))
+
+			got := []string{}
+			for _, res := range results {
+				assert.True(t, strings.HasPrefix(res.ShortURL, "http://localhost:8080/"))
+				assert.Positive(t, res.Rank)
+				got = append(got, res.OriginalURL)
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,36 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/BazNick/shortlink/internal/app/urlnorm"
+	"github.com/gin-gonic/gin"
+)
+
+// BatchURLError — какая из ссылок пакета не прошла проверку.
+type BatchURLError struct {
+	*urlnorm.Error
+	Index         int    `json:"index"`
+	CorrelationID string `json:"correlation_id"`
+}
+
+// normalizeLink проверяет ссылку перед сокращением. При отказе ответ уже
+// отправлен.
+func normalizeLink(c *gin.Context, n *urlnorm.Normalizer, raw string) (string, bool) {
+	link, err := n.Normalize(raw)
+	if err != nil {
+		writeURLError(c, err)
+		return "", false
+	}
+	return link, true
+}
+
+func writeURLError(c *gin.Context, err error) {
+	var urlErr *urlnorm.Error
+	if !errors.As(err, &urlErr) {
+		http.Error(c.Writer, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(c, http.StatusBadRequest, urlErr)
+}
@@ -1,15 +1,29 @@
 package handlers
 
 import (
-	"context"
+	"encoding/base64"
 	"encoding/json"
 	"net/http"
+	"strings"
 
-	"github.com/BazNick/shortlink/internal/app/entities"
 	"github.com/BazNick/shortlink/internal/app/functions"
+	"github.com/BazNick/shortlink/internal/app/storage"
 	"github.com/gin-gonic/gin"
 )
 
+const defaultLinkSort = "-" + storage.SortCreated
+
+// pageCursor — курсор, который видит клиент. Сортировка в нём нужна,
+// чтобы курсор от одного порядка не применили к другому.
+type pageCursor struct {
+	Sort string `json:"sort"`
+	storage.ListCursor
+}
+
+// GetUserLinks — ссылки пользователя постранично. Параметры: limit,
+// cursor, sort (created, clicks, с минусом — по убыванию), url —
+// подстрока адреса, tag, state (active, deleted, expired). Ссылка на
+// следующую страницу — в заголовке Link.
 func (handler *URLHandler) GetUserLinks(c *gin.Context) {
 	user, err := functions.GetUser(c)
 	if err != nil {
@@ -17,35 +31,36 @@ func (handler *URLHandler) GetUserLinks(c *gin.Context) {
 		return
 	}
 
-	rows, err := handler.db.QueryContext(
-		context.Background(),
-		`SELECT short_url, original_url FROM links WHERE user_id = $1`,
-		user,
-	)
-	if err != nil {
-		http.Error(c.Writer, err.Error(), http.StatusInternalServerError)
+	q, ok := linkQuery(c, user)
+	if !ok {
 		return
 	}
+	limit := q.Limit
+	// на одну больше, чтобы знать, есть ли следующая страница
+	q.Limit++
 
-	defer rows.Close()
-
-	var result []entities.FileLinks
-	for rows.Next() {
-		var rec entities.FileLinks
-		if err := rows.Scan(&rec.ShortURL, &rec.OriginalURL); err != nil {
-			http.Error(c.Writer, err.Error(), http.StatusBadRequest)
-		}
-		rec.ShortURL = functions.SchemeAndHost(c.Request) + "/" + rec.ShortURL
-		result = append(result, rec)
+	page, err := storage.ListLinks(handler.storage, q)
+	if err != nil {
+		http.Error(c.Writer, err.Error(), http.StatusInternalServerError)
+		return
 	}
 
-	if len(result) == 0 {
+	if len(page) == 0 {
 		c.Writer.WriteHeader(http.StatusNoContent)
 		return
 	}
 
-	if err := rows.Err(); err != nil {
-		http.Error(c.Writer, err.Error(), http.StatusInternalServerError)
+	if len(page) > limit {
+		page = page[:limit]
+		next := pageCursor{Sort: c.DefaultQuery("sort", defaultLinkSort), ListCursor: storage.CursorOf(page[limit-1])}
+		c.Writer.Header().Set("Link", `<`+nextPageURL(c, next)+`>; rel="next"`)
+	}
+
+	result := make([]storage.Link, len(page))
+	for i, rec := range page {
+		rec.ShortURL = functions.SchemeAndHost(c.Request) + "/" + rec.ShortURL
+		rec.UserID = ""
+		result[i] = rec
 	}
 
 	resp, err := json.Marshal(result)
@@ -58,3 +73,57 @@ func (handler *URLHandler) GetUserLinks(c *gin.Context) {
 	c.Writer.Header().Set("content-type", "application/json")
 	c.Writer.Write(resp)
 }
+
+func linkQuery(c *gin.Context, user string) (storage.LinkQuery, bool) {
+	limit, ok := adminLimit(c)
+	if !ok {
+		return storage.LinkQuery{}, false
+	}
+
+	q := storage.LinkQuery{
+		UserID:   user,
+		Contains: c.Query("url"),
+		Tag:      tagFilter(c),
+		State:    c.Query("state"),
+		Limit:    limit,
+	}
+
+	switch q.State {
+	case "", storage.StateActive, storage.StateDeleted, storage.StateExpired:
+	default:
+		http.Error(c.Writer, "state must be active, deleted or expired", http.StatusBadRequest)
+		return storage.LinkQuery{}, false
+	}
+
+	sort := c.DefaultQuery("sort", defaultLinkSort)
+	q.Sort, q.Desc = strings.TrimPrefix(sort, "-"), strings.HasPrefix(sort, "-")
+	if q.Sort != storage.SortCreated && q.Sort != storage.SortClicks {
+		http.Error(c.Writer, "sort must be created or clicks, optionally with a leading -", http.StatusBadRequest)
+		return storage.LinkQuery{}, false
+	}
+
+	if raw := c.Query("cursor"); raw != "" {
+		var cursor pageCursor
+		data, err := base64.RawURLEncoding.DecodeString(raw)
+		if err == nil {
+			err = json.Unmarshal(data, &cursor)
+		}
+		if err != nil || cursor.Sort != sort {
+			http.Error(c.Writer, "invalid cursor", http.StatusBadRequest)
+			return storage.LinkQuery{}, false
+		}
+		q.After = &cursor.ListCursor
+	}
+
+	return q, true
+}
+
+// nextPageURL — тот же запрос с курсором следующей страницы.
+func nextPageURL(c *gin.Context, cursor pageCursor) string {
+	data, _ := json.Marshal(cursor)
+
+	query := c.Request.URL.Query()
+	query.Set("cursor", base64.RawURLEncoding.EncodeToString(data))
+
+	return functions.SchemeAndHost(c.Request) + c.Request.URL.Path + "?" + query.Encode()
+}
@@ -0,0 +1,159 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/BazNick/shortlink/cmd/middleware/auth"
+	"github.com/BazNick/shortlink/internal/app/clicks"
+	"github.com/BazNick/shortlink/internal/app/entities"
+	"github.com/BazNick/shortlink/internal/app/storage"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUserLinksPages(t *testing.T) {
+	keyring, err := auth.LoadKeyring("secret_key", "", "")
+	require.NoError(t, err)
+	token, err := auth.NewToken(keyring)
+	require.NoError(t, err)
+
+	var (
+		store   = entities.NewHashDict()
+		counter = clicks.New(store)
+		handler = NewURLHandler(store, "test.json", "", WithClicks(counter))
+	)
+
+	router := gin.Default()
+	router.Use(auth.Middleware(keyring))
+	router.GET("/:id", handler.GetLink)
+	router.POST("/api/shorten", handler.PostJSONLink)
+	router.GET("/api/user/urls", handler.GetUserLinks)
+
+	do := func(method, target, body string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(method, "http://localhost:8080"+target, strings.NewReader(body))
+		request.Header.Set(auth.HeaderName, auth.BearerPrefix+token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, request)
+		return w
+	}
+
+	var codes []string
+	for _, url := range []string{"https://a.ru", "https://b.ru", "https://c.ru", "https://d.ru", "https://e.ru"} {
+		w := do(http.MethodPost, "/api/shorten", `{"url":"`+url+`"}`)
+		require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+		var resp map[string]string
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		codes = append(codes, strings.TrimPrefix(resp["result"], "http://localhost:8080/"))
+		// время создания задаёт порядок страниц
+		time.Sleep(time.Millisecond)
+	}
+
+	for i := 0; i < 3; i++ {
+		require.Equal(t, http.StatusTemporaryRedirect, do(http.MethodGet, "/"+codes[1], "").Code)
+	}
+	require.Equal(t, http.StatusTemporaryRedirect, do(http.MethodGet, "/"+codes[3], "").Code)
+	require.NoError(t, counter.Flush())
+
+	nextRe := regexp.MustCompile(`^<http://localhost:8080(/api/user/urls\?[^>]+)>; rel="next"$`)
+
+	// all обходит страницы по заголовку Link и возвращает адреса по порядку
+	all := func(target string) []string {
+		var result []string
+		for target != "" {
+			w := do(http.MethodGet, target, "")
+			require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+			var page []storage.Link
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+			require.LessOrEqual(t, len(page), 2)
+			for _, link := range page {
+				result = append(result, strings.TrimPrefix(link.OriginalURL, "https://"))
+			}
+
+			target = ""
+			if m := nextRe.FindStringSubmatch(w.Header().Get("Link")); m != nil {
+				target = m[1]
+			}
+		}
+		return result
+	}
+
+	tests := []struct {
+		name   string
+		target string
+		// сравниваются только первые len(want) адресов
+		want []string
+	}{
+		{
+			name:   "Newest first by default",
+			target: "/api/user/urls?limit=2",
+			want:   []string{"e.ru", "d.ru", "c.ru", "b.ru", "a.ru"},
+		},
+		{
+			name:   "Oldest first",
+			target: "/api/user/urls?limit=2&sort=created",
+			want:   []string{"a.ru", "b.ru", "c.ru", "d.ru", "e.ru"},
+		},
+		{
+			name:   "Most clicked first",
+			target: "/api/user/urls?limit=2&sort=-clicks",
+			want:   []string{"b.ru", "d.ru"},
+		},
+		{
+			name:   "Filtered by address",
+			target: "/api/user/urls?limit=2&url=C.RU",
+			want:   []string{"c.ru"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := all(tt.target)
+			require.GreaterOrEqual(t, len(got), len(tt.want))
+			assert.Equal(t, tt.want, got[:len(tt.want)])
+		})
+	}
+
+	t.Run("Timestamps", func(t *testing.T) {
+		for url, clicked := range map[string]bool{"b.ru": true, "a.ru": false} {
+			w := do(http.MethodGet, "/api/user/urls?url="+url, "")
+			require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+			var page []map[string]any
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+			require.Len(t, page, 1)
+			assert.NotEmpty(t, page[0]["created_at"])
+			assert.NotEmpty(t, page[0]["updated_at"])
+			_, ok := page[0]["last_accessed_at"]
+			assert.Equal(t, clicked, ok, url)
+		}
+	})
+
+	t.Run("Invalid parameters", func(t *testing.T) {
+		for _, target := range []string{
+			"/api/user/urls?sort=title",
+			"/api/user/urls?state=archived",
+			"/api/user/urls?limit=0",
+			"/api/user/urls?cursor=broken",
+		} {
+			assert.Equal(t, http.StatusBadRequest, do(http.MethodGet, target, "").Code, target)
+		}
+	})
+
+	t.Run("Cursor of another sort", func(t *testing.T) {
+		w := do(http.MethodGet, "/api/user/urls?limit=2&sort=clicks", "")
+		m := nextRe.FindStringSubmatch(w.Header().Get("Link"))
+		require.NotNil(t, m)
+
+		target := strings.Replace(m[1], "sort=clicks", "sort=created", 1)
+		assert.Equal(t, http.StatusBadRequest, do(http.MethodGet, target, "").Code)
+	})
+}
@@ -0,0 +1,446 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/BazNick/shortlink/internal/app/apperr"
+	"github.com/BazNick/shortlink/internal/app/audit"
+	"github.com/BazNick/shortlink/internal/app/entities"
+	"github.com/BazNick/shortlink/internal/app/functions"
+	"github.com/BazNick/shortlink/internal/app/quota"
+	"github.com/BazNick/shortlink/internal/app/safety"
+	"github.com/BazNick/shortlink/internal/app/storage"
+	"github.com/BazNick/shortlink/internal/app/urlnorm"
+	"github.com/gin-gonic/gin"
+)
+
+const maxWorkspaceNameLen = 100
+
+type (
+	// WorkspaceHandler — общие пространства: ссылки в них принадлежат всем
+	// участникам, что с ними можно делать, решает роль участника.
+	WorkspaceHandler struct {
+		workspaces storage.WorkspaceStore
+		links      storage.Storage
+		users      storage.UserStore
+		quota      *quota.Quota
+		audit      *audit.Log
+		urls       *urlnorm.Normalizer
+		safety     safety.Checker
+	}
+
+	WorkspaceIn struct {
+		Name string `json:"name"`
+	}
+
+	WorkspaceOut struct {
+		storage.Workspace
+		// роль текущего пользователя
+		Role string `json:"role"`
+	}
+
+	MemberIn struct {
+		UserID string `json:"user_id"`
+		Login  string `json:"login"`
+		Role   string `json:"role"`
+	}
+
+	MemberOut struct {
+		storage.Member
+		Login string `json:"login,omitempty"`
+	}
+)
+
+func NewWorkspaceHandler(
+	workspaces storage.WorkspaceStore,
+	links storage.Storage,
+	users storage.UserStore,
+	q *quota.Quota,
+	log *audit.Log,
+	urls *urlnorm.Normalizer,
+	checker safety.Checker,
+) *WorkspaceHandler {
+	return &WorkspaceHandler{
+		workspaces: workspaces,
+		links:      links,
+		users:      users,
+		quota:      q,
+		audit:      log,
+		urls:       urls,
+		safety:     checker,
+	}
+}
+
+// CreateWorkspace заводит пространство, создатель становится владельцем.
+func (handler *WorkspaceHandler) CreateWorkspace(c *gin.Context) {
+	user, err := functions.GetUser(c)
+	if err != nil {
+		c.Writer.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	var in WorkspaceIn
+	if err := json.NewDecoder(c.Request.Body).Decode(&in); err != nil {
+		http.Error(c.Writer, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	in.Name = strings.TrimSpace(in.Name)
+	if n := utf8.RuneCountInString(in.Name); n == 0 || n > maxWorkspaceNameLen {
+		http.Error(c.Writer, "name must be 1 to 100 characters", http.StatusBadRequest)
+		return
+	}
+
+	now := time.Now()
+	ws := storage.Workspace{
+		ID:        functions.RandSeq(16),
+		Name:      in.Name,
+		CreatedAt: now,
+	}
+	owner := storage.Member{
+		WorkspaceID: ws.ID,
+		UserID:      user,
+		Role:        storage.WorkspaceOwner,
+		AddedAt:     now,
+	}
+
+	if err := handler.workspaces.AddWorkspace(ws, owner); err != nil {
+		http.Error(c.Writer, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(c, http.StatusCreated, WorkspaceOut{Workspace: ws, Role: owner.Role})
+}
+
+// ListWorkspaces — пространства, где пользователь участник.
+func (handler *WorkspaceHandler) ListWorkspaces(c *gin.Context) {
+	user, err := functions.GetUser(c)
+	if err != nil {
+		c.Writer.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	members, err := handler.workspaces.UserWorkspaces(user)
+	if err != nil {
+		http.Error(c.Writer, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	result := make([]WorkspaceOut, 0, len(members))
+	for _, m := range members {
+		ws, err := handler.workspaces.Workspace(m.WorkspaceID)
+		if err != nil {
+			http.Error(c.Writer, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		result = append(result, WorkspaceOut{Workspace: ws, Role: m.Role})
+	}
+
+	if len(result) == 0 {
+		c.Writer.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	writeJSON(c, http.StatusOK, result)
+}
+
+func (handler *WorkspaceHandler) ListMembers(c *gin.Context) {
+	if _, ok := handler.member(c, storage.WorkspaceViewer); !ok {
+		return
+	}
+
+	members, err := handler.workspaces.Members(c.Param("ws"))
+	if err != nil {
+		http.Error(c.Writer, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	result := make([]MemberOut, 0, len(members))
+	for _, m := range members {
+		out := MemberOut{Member: m}
+		if user, err := handler.users.UserByID(m.UserID); err == nil {
+			out.Login = user.Login
+		}
+		result = append(result, out)
+	}
+
+	writeJSON(c, http.StatusOK, result)
+}
+
+// PutMember добавляет участника или меняет его роль. Участник задаётся
+// UserID или логином, последнего владельца понизить нельзя.
+func (handler *WorkspaceHandler) PutMember(c *gin.Context) {
+	if _, ok := handler.member(c, storage.WorkspaceOwner); !ok {
+		return
+	}
+
+	var in MemberIn
+	if err := json.NewDecoder(c.Request.Body).Decode(&in); err != nil {
+		http.Error(c.Writer, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if !storage.ValidWorkspaceRole(in.Role) {
+		http.Error(c.Writer, "role must be owner, editor or viewer", http.StatusBadRequest)
+		return
+	}
+
+	if in.Login != "" {
+		user, err := handler.users.UserByLogin(strings.ToLower(strings.TrimSpace(in.Login)))
+		if errors.Is(err, apperr.ErrUserNotFound) {
+			http.Error(c.Writer, err.Error(), http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(c.Writer, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		in.UserID = user.ID
+	}
+
+	if in.UserID == "" {
+		http.Error(c.Writer, "user_id or login is required", http.StatusBadRequest)
+		return
+	}
+
+	workspaceID := c.Param("ws")
+	if in.Role != storage.WorkspaceOwner && !handler.checkNotLastOwner(c, workspaceID, in.UserID) {
+		return
+	}
+
+	m := storage.Member{
+		WorkspaceID: workspaceID,
+		UserID:      in.UserID,
+		Role:        in.Role,
+		AddedAt:     time.Now(),
+	}
+	if err := handler.workspaces.PutMember(m); err != nil {
+		http.Error(c.Writer, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	m, err := handler.workspaces.Member(workspaceID, in.UserID)
+	if err != nil {
+		http.Error(c.Writer, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(c, http.StatusOK, m)
+}
+
+// RemoveMember убирает участника. Владелец убирает кого угодно, остальные
+// могут только выйти сами.
+func (handler *WorkspaceHandler) RemoveMember(c *gin.Context) {
+	target := c.Param("user")
+
+	current, ok := handler.member(c, storage.WorkspaceViewer)
+	if !ok {
+		return
+	}
+	if current.UserID != target && !current.Can(storage.WorkspaceOwner) {
+		http.Error(c.Writer, "only owners can remove members", http.StatusForbidden)
+		return
+	}
+
+	workspaceID := c.Param("ws")
+	if !handler.checkNotLastOwner(c, workspaceID, target) {
+		return
+	}
+
+	err := handler.workspaces.RemoveMember(workspaceID, target)
+	if errors.Is(err, apperr.ErrMemberNotFound) {
+		http.Error(c.Writer, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(c.Writer, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	c.Writer.WriteHeader(http.StatusNoContent)
+}
+
+// AddLink сокращает ссылку сразу в пространство.
+func (handler *WorkspaceHandler) AddLink(c *gin.Context) {
+	current, ok := handler.member(c, storage.WorkspaceEditor)
+	if !ok {
+		return
+	}
+
+	var link JSONLink
+	if err := json.NewDecoder(c.Request.Body).Decode(&link); err != nil {
+		http.Error(c.Writer, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	original, ok := normalizeLink(c, handler.urls, link.Link)
+	if !ok || !checkSafety(c, handler.safety, original) {
+		return
+	}
+
+	meta, ok := linkMetadata(c, link.LinkMetadata)
+	if !ok {
+		return
+	}
+
+	if _, isDB := storage.As[*entities.DB](handler.links); !isDB {
+		if handler.links.CheckValExists(current.UserID, original) {
+			http.Error(c.Writer, apperr.ErrLinkExists.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	reservation, ok := reserveQuota(c, handler.quota, current.UserID, 1)
+	if !ok {
+		return
+	}
+
+	// ссылка сразу пишется с пространством, чтобы не остаться личной,
+	// если запись прервётся
+	created := storage.Link{
+		ShortURL:    functions.RandSeq(8),
+		OriginalURL: original,
+		UserID:      current.UserID,
+		WorkspaceID: current.WorkspaceID,
+	}
+	meta.apply(&created)
+
+	err := storage.InsertLink(handler.links, created)
+	if err != nil {
+		reservation.Done(0)
+	} else {
+		reservation.Done(1)
+	}
+	if errors.Is(err, apperr.ErrValAlreadyExists) {
+		// уже сокращённую ссылку в пространство не переносим
+		var shortURL string
+		if db, ok := storage.As[*entities.DB](handler.links); ok {
+			shortURL, _ = db.FindShortURL(current.UserID, original)
+		}
+		writeJSON(c, http.StatusConflict, map[string]string{
+			"result": functions.SchemeAndHost(c.Request) + "/" + shortURL,
+		})
+		return
+	}
+	if err != nil {
+		http.Error(c.Writer, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	handler.audit.Record(c, storage.AuditCreate, nil, &created)
+
+	writeJSON(c, http.StatusCreated, map[string]string{
+		"result": functions.SchemeAndHost(c.Request) + "/" + created.ShortURL,
+	})
+}
+
+// Links — ссылки пространства всех участников. UserID оставлен, чтобы
+// было видно автора. ?tag= оставляет ссылки с этой меткой.
+func (handler *WorkspaceHandler) Links(c *gin.Context) {
+	current, ok := handler.member(c, storage.WorkspaceViewer)
+	if !ok {
+		return
+	}
+
+	tag := tagFilter(c)
+
+	var result []storage.Link
+	err := handler.links.WorkspaceLinks(current.WorkspaceID, func(rec storage.Link) error {
+		if tag != "" && !rec.HasTag(tag) {
+			return nil
+		}
+		rec.ShortURL = functions.SchemeAndHost(c.Request) + "/" + rec.ShortURL
+		result = append(result, rec)
+		return nil
+	})
+	if err != nil {
+		http.Error(c.Writer, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if len(result) == 0 {
+		c.Writer.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	writeJSON(c, http.StatusOK, result)
+}
+
+// DeleteLinks удаляет ссылки пространства, кто бы их ни создал. Как и
+// /api/user/urls, удаление идёт в фоне.
+func (handler *WorkspaceHandler) DeleteLinks(c *gin.Context) {
+	current, ok := handler.member(c, storage.WorkspaceEditor)
+	if !ok {
+		return
+	}
+
+	var links []string
+	if err := json.NewDecoder(c.Request.Body).Decode(&links); err != nil {
+		http.Error(c.Writer, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	entities.DeleteChan <- entities.DeleteRequest{
+		UserID:      current.UserID,
+		WorkspaceID: current.WorkspaceID,
+		ShortURLs:   links,
+		Source:      audit.Source(c),
+	}
+
+	c.Writer.WriteHeader(http.StatusAccepted)
+}
+
+// member проверяет, что пользователь состоит в пространстве :ws с ролью не
+// ниже role. Чужим пространство не показываем — для них оно не найдено.
+func (handler *WorkspaceHandler) member(c *gin.Context, role string) (storage.Member, bool) {
+	user, err := functions.GetUser(c)
+	if err != nil {
+		c.Writer.WriteHeader(http.StatusUnauthorized)
+		return storage.Member{}, false
+	}
+
+	m, err := handler.workspaces.Member(c.Param("ws"), user)
+	if errors.Is(err, apperr.ErrMemberNotFound) {
+		http.Error(c.Writer, apperr.ErrWorkspaceNotFound.Error(), http.StatusNotFound)
+		return storage.Member{}, false
+	}
+	if err != nil {
+		http.Error(c.Writer, err.Error(), http.StatusInternalServerError)
+		return storage.Member{}, false
+	}
+
+	if !m.Can(role) {
+		http.Error(c.Writer, "workspace role "+role+" required", http.StatusForbidden)
+		return storage.Member{}, false
+	}
+
+	return m, true
+}
+
+// checkNotLastOwner не даёт оставить пространство без владельца.
+func (handler *WorkspaceHandler) checkNotLastOwner(c *gin.Context, workspaceID, userID string) bool {
+	members, err := handler.workspaces.Members(workspaceID)
+	if err != nil {
+		http.Error(c.Writer, err.Error(), http.StatusInternalServerError)
+		return false
+	}
+
+	var owners int
+	isOwner := false
+	for _, m := range members {
+		if m.Role == storage.WorkspaceOwner {
+			owners++
+			isOwner = isOwner || m.UserID == userID
+		}
+	}
+
+	if isOwner && owners == 1 {
+		http.Error(c.Writer, "workspace must keep at least one owner", http.StatusConflict)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,258 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/BazNick/shortlink/cmd/middleware/auth"
+	"github.com/BazNick/shortlink/internal/app/entities"
+	"github.com/BazNick/shortlink/internal/app/storage"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWorkspaces(t *testing.T) {
+	secret := "secret_key"
+
+	users, err := entities.NewUsers("")
+	require.NoError(t, err)
+	spaces, err := entities.NewWorkspaces("")
+	require.NoError(t, err)
+	keyring, err := auth.LoadKeyring(secret, "", "")
+	require.NoError(t, err)
+
+	store := entities.NewHashDict()
+
+	var (
+		accounts   = NewAccountHandler(users, store, auth.NewSessions(keyring), nil, nil)
+		workspaces = NewWorkspaceHandler(spaces, store, users, nil, nil, nil, nil)
+	)
+
+	router := gin.Default()
+	router.POST("/api/auth/register", accounts.Register)
+	router.Use(auth.Middleware(keyring))
+	group := router.Group("/api/workspaces", auth.Required())
+	group.POST("", workspaces.CreateWorkspace)
+	group.GET("", workspaces.ListWorkspaces)
+	group.GET("/:ws/members", workspaces.ListMembers)
+	group.POST("/:ws/members", workspaces.PutMember)
+	group.DELETE("/:ws/members/:user", workspaces.RemoveMember)
+	group.POST("/:ws/urls", workspaces.AddLink)
+	group.GET("/:ws/urls", workspaces.Links)
+	group.DELETE("/:ws/urls", workspaces.DeleteLinks)
+
+	do := func(method, target, body, token string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(method, "http://localhost:8080"+target, strings.NewReader(body))
+		if token != "" {
+			request.Header.Set(auth.HeaderName, auth.BearerPrefix+token)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, request)
+		return w
+	}
+	register := func(login string) AccountOut {
+		w := do(http.MethodPost, "/api/auth/register", `{"login":"`+login+`","password":"long enough"}`, "")
+		require.Equal(t, http.StatusCreated, w.Code)
+
+		var account AccountOut
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &account))
+		return account
+	}
+
+	var (
+		alice = register("alice")
+		bob   = register("bob")
+		carol = register("carol")
+		dave  = register("dave")
+	)
+
+	w := do(http.MethodPost, "/api/workspaces", `{"name":"Marketing"}`, alice.AccessToken)
+	require.Equal(t, http.StatusCreated, w.Code)
+	var ws WorkspaceOut
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &ws))
+	require.Equal(t, storage.WorkspaceOwner, ws.Role)
+
+	base := "/api/workspaces/" + ws.ID
+	var bobLink string
+
+	tests := []struct {
+		name         string
+		method       string
+		target       string
+		body         string
+		token        string
+		expectedCode int
+		check        func(t *testing.T, body []byte)
+	}{
+		{
+			name:         "Empty name",
+			method:       http.MethodPost,
+			target:       "/api/workspaces",
+			body:         `{"name":"  "}`,
+			token:        alice.AccessToken,
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "Add editor by login",
+			method:       http.MethodPost,
+			target:       base + "/members",
+			body:         `{"login":"Bob","role":"editor"}`,
+			token:        alice.AccessToken,
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:         "Add viewer by user id",
+			method:       http.MethodPost,
+			target:       base + "/members",
+			body:         `{"user_id":"` + carol.UserID + `","role":"viewer"}`,
+			token:        alice.AccessToken,
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:         "Unknown role",
+			method:       http.MethodPost,
+			target:       base + "/members",
+			body:         `{"login":"dave","role":"admin"}`,
+			token:        alice.AccessToken,
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "Editor cannot manage members",
+			method:       http.MethodPost,
+			target:       base + "/members",
+			body:         `{"login":"dave","role":"viewer"}`,
+			token:        bob.AccessToken,
+			expectedCode: http.StatusForbidden,
+		},
+		{
+			name:         "Outsider does not see workspace",
+			method:       http.MethodGet,
+			target:       base + "/urls",
+			token:        dave.AccessToken,
+			expectedCode: http.StatusNotFound,
+		},
+		{
+			name:         "Viewer cannot add links",
+			method:       http.MethodPost,
+			target:       base + "/urls",
+			body:         `{"url":"https://ya.ru"}`,
+			token:        carol.AccessToken,
+			expectedCode: http.StatusForbidden,
+		},
+		{
+			name:         "Editor adds link",
+			method:       http.MethodPost,
+			target:       base + "/urls",
+			body:         `{"url":"https://go.dev"}`,
+			token:        bob.AccessToken,
+			expectedCode: http.StatusCreated,
+			check: func(t *testing.T, body []byte) {
+				var resp map[string]string
+				require.NoError(t, json.Unmarshal(body, &resp))
+				bobLink = shortCodeFrom(resp["result"])
+
+				link, err := store.GetLink(bobLink)
+				require.NoError(t, err)
+				assert.Equal(t, ws.ID, link.WorkspaceID)
+				assert.Equal(t, bob.UserID, link.UserID)
+			},
+		},
+		{
+			name:         "Viewer lists links",
+			method:       http.MethodGet,
+			target:       base + "/urls",
+			token:        carol.AccessToken,
+			expectedCode: http.StatusOK,
+			check: func(t *testing.T, body []byte) {
+				var links []storage.Link
+				require.NoError(t, json.Unmarshal(body, &links))
+				require.Len(t, links, 1)
+				assert.Equal(t, "https://go.dev", links[0].OriginalURL)
+			},
+		},
+		{
+			name:         "Members list",
+			method:       http.MethodGet,
+			target:       base + "/members",
+			token:        carol.AccessToken,
+			expectedCode: http.StatusOK,
+			check: func(t *testing.T, body []byte) {
+				var members []MemberOut
+				require.NoError(t, json.Unmarshal(body, &members))
+				require.Len(t, members, 3)
+				assert.Equal(t, "alice", members[0].Login)
+				assert.Equal(t, storage.WorkspaceOwner, members[0].Role)
+			},
+		},
+		{
+			name:         "Viewer cannot remove others",
+			method:       http.MethodDelete,
+			target:       base + "/members/" + bob.UserID,
+			token:        carol.AccessToken,
+			expectedCode: http.StatusForbidden,
+		},
+		{
+			name:         "Last owner cannot leave",
+			method:       http.MethodDelete,
+			target:       base + "/members/" + alice.UserID,
+			token:        alice.AccessToken,
+			expectedCode: http.StatusConflict,
+		},
+		{
+			name:         "Last owner cannot be demoted",
+			method:       http.MethodPost,
+			target:       base + "/members",
+			body:         `{"user_id":"` + alice.UserID + `","role":"editor"}`,
+			token:        alice.AccessToken,
+			expectedCode: http.StatusConflict,
+		},
+		{
+			name:         "Viewer leaves",
+			method:       http.MethodDelete,
+			target:       base + "/members/" + carol.UserID,
+			token:        carol.AccessToken,
+			expectedCode: http.StatusNoContent,
+		},
+		{
+			name:         "Workspaces of user",
+			method:       http.MethodGet,
+			target:       "/api/workspaces",
+			token:        bob.AccessToken,
+			expectedCode: http.StatusOK,
+			check: func(t *testing.T, body []byte) {
+				var list []WorkspaceOut
+				require.NoError(t, json.Unmarshal(body, &list))
+				require.Len(t, list, 1)
+				assert.Equal(t, "Marketing", list[0].Name)
+				assert.Equal(t, storage.WorkspaceEditor, list[0].Role)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := do(tt.method, tt.target, tt.body, tt.token)
+			require.Equal(t, tt.expectedCode, w.Code, w.Body.String())
+			if tt.check != nil {
+				tt.check(t, w.Body.Bytes())
+			}
+		})
+	}
+
+	t.Run("Owner deletes editor's link", func(t *testing.T) {
+		w := do(http.MethodDelete, base+"/urls", `["`+bobLink+`"]`, alice.AccessToken)
+		require.Equal(t, http.StatusAccepted, w.Code)
+
+		req := <-entities.DeleteChan
+		assert.Equal(t, ws.ID, req.WorkspaceID)
+		require.NoError(t, entities.DeleteWorkspaceLinks(store, req.WorkspaceID, req.ShortURLs))
+
+		link, err := store.GetLink(bobLink)
+		require.NoError(t, err)
+		assert.True(t, link.IsDeleted)
+	})
+}
@@ -0,0 +1,120 @@
+package migrate
+
+import (
+	"github.com/BazNick/shortlink/internal/app/storage"
+)
+
+// сколько кодов с ошибками держать в отчёте
+const failureSampleSize = 20
+
+type (
+	Options struct {
+		BatchSize int
+		DryRun    bool
+		// Logf, если задан, получает прогресс после каждой пачки
+		Logf func(format string, args ...any)
+	}
+
+	Report struct {
+		Source   int                      `json:"source"`
+		Deleted  int                      `json:"deleted"`
+		Skipped  int                      `json:"skipped"`
+		Copied   int                      `json:"copied"`
+		Failed   int                      `json:"failed"`
+		Failures []string                 `json:"failures,omitempty"`
+		Verify   *storage.ReconcileReport `json:"verify,omitempty"`
+	}
+)
+
+// Run переносит все ссылки из src в dst пачками по BatchSize вместе с
+// владельцем и признаком удаления. Коды, которые уже есть в dst,
+// пропускаются, поэтому прерванный перенос можно просто запустить заново.
+func Run(src, dst storage.Storage, opts Options) (*Report, error) {
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 500
+	}
+
+	existing := make(map[string]bool)
+	err := dst.Links(func(link storage.Link) error {
+		existing[link.ShortURL] = true
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		report = &Report{}
+		batch  = make([]storage.Link, 0, opts.BatchSize)
+	)
+
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+
+		if opts.DryRun {
+			report.Copied += len(batch)
+		} else {
+			copyBatch(dst, batch, report)
+		}
+		batch = batch[:0]
+
+		if opts.Logf != nil {
+			opts.Logf("migrated %d of %d links (skipped %d, failed %d)",
+				report.Copied, report.Source, report.Skipped, report.Failed)
+		}
+	}
+
+	err = src.Links(func(link storage.Link) error {
+		report.Source++
+		if link.IsDeleted {
+			report.Deleted++
+		}
+
+		if existing[link.ShortURL] {
+			report.Skipped++
+			return nil
+		}
+
+		batch = append(batch, link)
+		if len(batch) == opts.BatchSize {
+			flush()
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	flush()
+
+	if opts.DryRun {
+		return report, nil
+	}
+
+	report.Verify, err = storage.Reconcile(src, dst)
+	if err != nil {
+		return nil, err
+	}
+
+	return report, nil
+}
+
+func copyBatch(dst storage.Storage, batch []storage.Link, report *Report) {
+	if err := dst.AddBatch(batch); err == nil {
+		report.Copied += len(batch)
+		return
+	}
+
+	// пачка откатилась целиком, ищем виноватые ссылки по одной
+	for _, link := range batch {
+		if err := dst.AddBatch([]storage.Link{link}); err != nil {
+			report.Failed++
+			if len(report.Failures) < failureSampleSize {
+				report.Failures = append(report.Failures, link.ShortURL+": "+err.Error())
+			}
+			continue
+		}
+		report.Copied++
+	}
+}
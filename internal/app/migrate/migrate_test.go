@@ -0,0 +1,95 @@
+package migrate
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+
+	"github.com/BazNick/shortlink/internal/app/entities"
+	"github.com/BazNick/shortlink/internal/app/storage"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		name        string
+		dryRun      bool
+		preexisting []string
+		wantCopied  int
+		wantSkipped int
+		wantTarget  int
+	}{
+		{
+			name:       "All links are copied",
+			wantCopied: 3,
+			wantTarget: 3,
+		},
+		{
+			name:       "Dry run writes nothing",
+			dryRun:     true,
+			wantCopied: 3,
+			wantTarget: 0,
+		},
+		{
+			name:        "Already migrated links are skipped",
+			preexisting: []string{"aaaaaaaa"},
+			wantCopied:  2,
+			wantSkipped: 1,
+			wantTarget:  3,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src := entities.NewFileStore(filepath.Join(t.TempDir(), "links.json"))
+			src.AddHash("aaaaaaaa", "https://a.ru", "user1")
+			src.AddHash("bbbbbbbb", "https://b.ru", "user1")
+			src.AddHash("cccccccc", "https://c.ru", "user2")
+			src.DeleteLinks("user2", []string{"cccccccc"})
+
+			dst := entities.NewHashDict()
+			for _, hash := range tt.preexisting {
+				dst.AddHash(hash, "https://a.ru", "user1")
+			}
+
+			report, err := Run(src, dst, Options{BatchSize: 2, DryRun: tt.dryRun})
+			require.NoError(t, err)
+
+			require.Equal(t, 3, report.Source)
+			require.Equal(t, 1, report.Deleted)
+			require.Equal(t, tt.wantCopied, report.Copied)
+			require.Equal(t, tt.wantSkipped, report.Skipped)
+			require.Len(t, dst.Dict, tt.wantTarget)
+
+			if !tt.dryRun {
+				require.Zero(t, report.Verify.MissingInSecondary)
+				require.Zero(t, report.Verify.Mismatched)
+				require.Equal(t, "", dst.GetHash("cccccccc"))
+				require.Equal(t, "https://b.ru", dst.GetHash("bbbbbbbb"))
+			}
+		})
+	}
+}
+
+func TestRun_MemoryDump(t *testing.T) {
+	src := entities.NewHashDict()
+	src.AddBatch([]storage.Link{
+		{ShortURL: "aaaaaaaa", OriginalURL: "https://a.ru", UserID: "user1"},
+		{ShortURL: "bbbbbbbb", OriginalURL: "https://b.ru", UserID: "user1", IsDeleted: true},
+	})
+
+	dst := entities.NewHashDict()
+	_, err := Run(src, dst, Options{})
+	require.NoError(t, err)
+
+	var dump bytes.Buffer
+	require.NoError(t, dst.Dump(&dump))
+
+	restored := entities.NewHashDict()
+	require.NoError(t, restored.Load(&dump))
+
+	report, err := storage.Reconcile(src, restored)
+	require.NoError(t, err)
+	require.Equal(t, 2, report.Secondary)
+	require.Zero(t, report.Mismatched)
+}
@@ -0,0 +1,88 @@
+package oidc_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/BazNick/shortlink/internal/app/oidc"
+	"github.com/BazNick/shortlink/internal/app/oidc/oidctest"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProvider(t *testing.T) {
+	issuer := oidctest.NewIssuer(t, "shortener", "client_secret")
+
+	tests := []struct {
+		name      string
+		tamper    func(claims jwt.MapClaims)
+		verifier  func(verifier string) string
+		wantErr   error
+		wantEmail string
+	}{
+		{
+			name:      "Valid login",
+			wantEmail: "subject@example.com",
+		},
+		{
+			name:     "Wrong code verifier",
+			verifier: func(string) string { return "guessed" },
+			wantErr:  oidc.ErrRejected,
+		},
+		{
+			name:    "Token for another client",
+			tamper:  func(claims jwt.MapClaims) { claims["aud"] = "other" },
+			wantErr: oidc.ErrInvalidIDToken,
+		},
+		{
+			name:    "Token from another issuer",
+			tamper:  func(claims jwt.MapClaims) { claims["iss"] = "https://evil.example.com" },
+			wantErr: oidc.ErrInvalidIDToken,
+		},
+		{
+			name:    "Replayed nonce",
+			tamper:  func(claims jwt.MapClaims) { claims["nonce"] = "old" },
+			wantErr: oidc.ErrInvalidIDToken,
+		},
+		{
+			name:    "Expired token",
+			tamper:  func(claims jwt.MapClaims) { claims["exp"] = time.Now().Add(-time.Minute).Unix() },
+			wantErr: oidc.ErrInvalidIDToken,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			issuer.Tamper = tt.tamper
+
+			provider := oidc.NewProvider(oidc.Config{
+				Issuer:       issuer.URL,
+				ClientID:     "shortener",
+				ClientSecret: "client_secret",
+				RedirectURL:  "http://localhost:8080/api/auth/oidc/callback",
+			})
+
+			verifier, challenge := oidc.NewPKCE()
+			authURL, err := provider.AuthURL(context.Background(), "state", "nonce", challenge)
+			require.NoError(t, err)
+
+			back := issuer.Authorize(t, authURL)
+			require.Equal(t, "state", back.Query().Get("state"))
+
+			if tt.verifier != nil {
+				verifier = tt.verifier(verifier)
+			}
+
+			claims, err := provider.Exchange(context.Background(), back.Query().Get("code"), verifier, "nonce")
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, "subject", claims.Subject)
+			assert.Equal(t, tt.wantEmail, claims.Email)
+		})
+	}
+}
@@ -0,0 +1,336 @@
+// Package quota ограничивает, сколько ссылок пользователь может держать и
+// создавать. Нулевой лимит ничего не ограничивает, nil *Quota тоже.
+package quota
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/BazNick/shortlink/internal/app/storage"
+)
+
+const (
+	KindLinks = "links"
+	KindBatch = "batch"
+	KindDaily = "daily"
+
+	day = 24 * time.Hour
+
+	// activeTTL — сколько Quota верит запомненному числу активных ссылок.
+	// Удаления и отключения идут мимо неё, поэтому перед отказом число
+	// всё равно пересчитывается по хранилищу.
+	activeTTL = time.Minute
+)
+
+type (
+	Limits struct {
+		// активных (не удалённых и не отключённых) ссылок у пользователя
+		MaxLinks int
+		// ссылок в одном запросе /api/shorten/batch
+		MaxBatch int
+		// новых ссылок за сутки по UTC
+		DailyLinks int
+	}
+
+	// Quota считает активные ссылки по хранилищу, а созданные за сутки —
+	// счётчиками в BucketStore, общими для реплик, если хранилище общее.
+	// Лимит активных ссылок держится в пределах одной реплики.
+	Quota struct {
+		limits   Limits
+		links    storage.Storage
+		counters storage.BucketStore
+		now      func() time.Time
+
+		// mu делает проверку и списание MaxLinks одним шагом
+		mu sync.Mutex
+		// active — число активных ссылок в хранилище на момент подсчёта
+		active    map[string]activeCount
+		lastSweep time.Time
+		// held — списанные, но ещё не записанные ссылки
+		held map[string]int
+	}
+
+	activeCount struct {
+		n  int
+		at time.Time
+	}
+
+	// Reservation — ссылки, списанные с лимитов. Когда запись закончена,
+	// нужно вызвать Done с числом созданных ссылок, остальные вернутся в
+	// лимиты. nil Reservation ничего не держит.
+	Reservation struct {
+		q       *Quota
+		userID  string
+		counter string
+		n       int
+	}
+
+	// Error — превышенный лимит, отдаётся клиенту как есть.
+	Error struct {
+		Message   string `json:"error"`
+		Kind      string `json:"quota"`
+		Limit     int    `json:"limit"`
+		Used      int    `json:"used"`
+		Requested int    `json:"requested"`
+		// когда лимит освободится, только у суточного
+		ResetsAt *time.Time `json:"resets_at,omitempty"`
+	}
+
+	Counter struct {
+		Used     int        `json:"used"`
+		Limit    int        `json:"limit"`
+		ResetsAt *time.Time `json:"resets_at,omitempty"`
+	}
+
+	Usage struct {
+		Links    Counter `json:"links"`
+		Daily    Counter `json:"daily"`
+		MaxBatch int     `json:"max_batch"`
+	}
+)
+
+func New(links storage.Storage, counters storage.BucketStore, limits Limits) *Quota {
+	return &Quota{
+		limits:   limits,
+		links:    links,
+		counters: counters,
+		now:      time.Now,
+		active:   make(map[string]activeCount),
+		held:     make(map[string]int),
+	}
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// Status — 403 для лимитов, которые сами не освободятся, 429 для суточного.
+func (e *Error) Status() int {
+	if e.Kind == KindDaily {
+		return http.StatusTooManyRequests
+	}
+	return http.StatusForbidden
+}
+
+// Window — сколько должен храниться суточный счётчик, 0 без суточного
+// лимита.
+func (l Limits) Window() time.Duration {
+	if l.DailyLinks <= 0 {
+		return 0
+	}
+	return day
+}
+
+// CheckBatch проверяет размер одного пакета.
+func (q *Quota) CheckBatch(n int) error {
+	if q == nil || q.limits.MaxBatch <= 0 || n <= q.limits.MaxBatch {
+		return nil
+	}
+
+	return &Error{
+		Message:   fmt.Sprintf("batch of %d links exceeds the limit of %d", n, q.limits.MaxBatch),
+		Kind:      KindBatch,
+		Limit:     q.limits.MaxBatch,
+		Requested: n,
+	}
+}
+
+// Reserve проверяет, что userID может создать ещё n ссылок, и списывает
+// их с лимитов, суточный — по ключу counter.
+func (q *Quota) Reserve(userID, counter string, n int) (*Reservation, error) {
+	if q == nil {
+		return nil, nil
+	}
+
+	if q.limits.MaxLinks > 0 {
+		if err := q.holdLinks(userID, n); err != nil {
+			return nil, err
+		}
+	}
+
+	if q.limits.DailyLinks > 0 {
+		if err := q.reserveDaily(counter, n); err != nil {
+			q.releaseLinks(userID, n, 0)
+			return nil, err
+		}
+	}
+
+	return &Reservation{q: q, userID: userID, counter: counter, n: n}, nil
+}
+
+// Done закрывает резервирование: created ссылок уже в хранилище, остальные
+// возвращаются в лимиты. Повторный вызов ничего не делает.
+func (r *Reservation) Done(created int) error {
+	if r == nil || r.q == nil {
+		return nil
+	}
+	q := r.q
+	r.q = nil
+
+	if q.limits.MaxLinks > 0 {
+		q.releaseLinks(r.userID, r.n, created)
+	}
+
+	unused := r.n - created
+	if q.limits.DailyLinks <= 0 || unused <= 0 {
+		return nil
+	}
+
+	now := q.now()
+	return q.counters.UpdateBucket(dailyKey(r.counter), func(b *storage.Bucket) {
+		b.Tokens = float64(max(dailyUsed(b, now)-unused, 0))
+		b.UpdatedAt = now
+	})
+}
+
+// holdLinks списывает n ссылок с лимита активных. Запомненное число
+// пересчитывается, если устарело или лимит по нему уже исчерпан.
+func (q *Quota) holdLinks(userID string, n int) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	now := q.now()
+	if now.Sub(q.lastSweep) >= activeTTL {
+		for user, count := range q.active {
+			if now.Sub(count.at) >= activeTTL {
+				delete(q.active, user)
+			}
+		}
+		q.lastSweep = now
+	}
+
+	count, ok := q.active[userID]
+	if !ok || now.Sub(count.at) >= activeTTL || count.n+q.held[userID]+n > q.limits.MaxLinks {
+		active, err := q.activeLinks(userID, now)
+		if err != nil {
+			return err
+		}
+		count = activeCount{n: active, at: now}
+		q.active[userID] = count
+	}
+
+	used := count.n + q.held[userID]
+	if used+n > q.limits.MaxLinks {
+		return &Error{
+			Message:   fmt.Sprintf("active link limit of %d reached", q.limits.MaxLinks),
+			Kind:      KindLinks,
+			Limit:     q.limits.MaxLinks,
+			Used:      used,
+			Requested: n,
+		}
+	}
+
+	q.held[userID] += n
+	return nil
+}
+
+// releaseLinks снимает n списанных ссылок, из которых created записаны.
+func (q *Quota) releaseLinks(userID string, n, created int) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.held[userID] -= n
+	if q.held[userID] <= 0 {
+		delete(q.held, userID)
+	}
+	if count, ok := q.active[userID]; ok {
+		count.n += created
+		q.active[userID] = count
+	}
+}
+
+func (q *Quota) reserveDaily(counter string, n int) error {
+	var used int
+	allowed := false
+	now := q.now()
+
+	err := q.counters.UpdateBucket(dailyKey(counter), func(b *storage.Bucket) {
+		used = dailyUsed(b, now)
+		if used+n > q.limits.DailyLinks {
+			return
+		}
+		b.Tokens = float64(used + n)
+		b.UpdatedAt = now
+		allowed = true
+	})
+	if err != nil {
+		return err
+	}
+
+	if !allowed {
+		resets := nextDay(now)
+		return &Error{
+			Message:   fmt.Sprintf("daily limit of %d new links reached", q.limits.DailyLinks),
+			Kind:      KindDaily,
+			Limit:     q.limits.DailyLinks,
+			Used:      used,
+			Requested: n,
+			ResetsAt:  &resets,
+		}
+	}
+
+	return nil
+}
+
+// Usage — текущее использование лимитов пользователем.
+func (q *Quota) Usage(userID, counter string) (Usage, error) {
+	if q == nil {
+		return Usage{}, nil
+	}
+
+	usage := Usage{
+		Links:    Counter{Limit: q.limits.MaxLinks},
+		Daily:    Counter{Limit: q.limits.DailyLinks},
+		MaxBatch: q.limits.MaxBatch,
+	}
+
+	now := q.now()
+	active, err := q.activeLinks(userID, now)
+	if err != nil {
+		return Usage{}, err
+	}
+	usage.Links.Used = active
+
+	if q.limits.DailyLinks > 0 {
+		bucket, err := q.counters.GetBucket(dailyKey(counter))
+		if err != nil {
+			return Usage{}, err
+		}
+		usage.Daily.Used = dailyUsed(&bucket, now)
+
+		resets := nextDay(now)
+		usage.Daily.ResetsAt = &resets
+	}
+
+	return usage, nil
+}
+
+// activeLinks — ссылки, которые не удалены, не отключены и не истекли.
+func (q *Quota) activeLinks(userID string, now time.Time) (int, error) {
+	var n int
+	err := q.links.UserLinks(userID, func(link storage.Link) error {
+		if !link.IsDeleted && !link.IsDisabled && !link.Expired(now) {
+			n++
+		}
+		return nil
+	})
+	return n, err
+}
+
+// dailyUsed — сколько уже создано сегодня; счётчик прошлых суток не в счёт.
+func dailyUsed(b *storage.Bucket, now time.Time) int {
+	if b.UpdatedAt.Before(now.UTC().Truncate(day)) {
+		return 0
+	}
+	return int(b.Tokens)
+}
+
+func dailyKey(counter string) string {
+	return "quota:" + counter
+}
+
+func nextDay(now time.Time) time.Time {
+	return now.UTC().Truncate(day).Add(day)
+}
@@ -0,0 +1,127 @@
+package quota
+
+import (
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/BazNick/shortlink/internal/app/entities"
+	"github.com/BazNick/shortlink/internal/app/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQuota(t *testing.T) {
+	store := entities.NewHashDict()
+	store.AddHash("active01", "https://ya.ru", "user1")
+	store.AddHash("deleted1", "https://go.dev", "user1")
+	store.DeleteLinks("user1", []string{"deleted1"})
+
+	q := New(store, entities.NewBuckets(48*time.Hour), Limits{MaxLinks: 3, MaxBatch: 2, DailyLinks: 2})
+	now := time.Date(2024, 5, 1, 23, 0, 0, 0, time.UTC)
+	q.now = func() time.Time { return now }
+
+	var reserved *Reservation
+
+	t.Run("Batch size", func(t *testing.T) {
+		require.NoError(t, q.CheckBatch(2))
+
+		var quotaErr *Error
+		require.ErrorAs(t, q.CheckBatch(3), &quotaErr)
+		assert.Equal(t, KindBatch, quotaErr.Kind)
+		assert.Equal(t, http.StatusForbidden, quotaErr.Status())
+	})
+
+	t.Run("Deleted links are not counted", func(t *testing.T) {
+		_, err := q.Reserve("user1", "user:user1", 3)
+		var quotaErr *Error
+		require.ErrorAs(t, err, &quotaErr)
+		assert.Equal(t, KindLinks, quotaErr.Kind)
+		assert.Equal(t, 1, quotaErr.Used)
+
+		reserved, err = q.Reserve("user1", "user:user1", 2)
+		require.NoError(t, err)
+	})
+
+	t.Run("Reserved links are counted until done", func(t *testing.T) {
+		_, err := q.Reserve("user1", "user:user2", 1)
+		var quotaErr *Error
+		require.ErrorAs(t, err, &quotaErr)
+		assert.Equal(t, KindLinks, quotaErr.Kind)
+		assert.Equal(t, 3, quotaErr.Used)
+	})
+
+	t.Run("Daily limit", func(t *testing.T) {
+		_, err := q.Reserve("user2", "user:user1", 1)
+		var quotaErr *Error
+		require.ErrorAs(t, err, &quotaErr)
+		assert.Equal(t, KindDaily, quotaErr.Kind)
+		assert.Equal(t, http.StatusTooManyRequests, quotaErr.Status())
+		assert.Equal(t, time.Date(2024, 5, 2, 0, 0, 0, 0, time.UTC), *quotaErr.ResetsAt)
+
+		// несозданная ссылка возвращается в лимит, повторный Done ничего не меняет
+		require.NoError(t, reserved.Done(1))
+		require.NoError(t, reserved.Done(0))
+		_, err = q.Reserve("user2", "user:user1", 1)
+		require.NoError(t, err)
+	})
+
+	t.Run("Daily limit resets at midnight", func(t *testing.T) {
+		usage, err := q.Usage("user1", "user:user1")
+		require.NoError(t, err)
+		assert.Equal(t, Counter{Used: 1, Limit: 3}, usage.Links)
+		assert.Equal(t, 2, usage.Daily.Used)
+
+		now = now.Add(2 * time.Hour)
+		usage, err = q.Usage("user1", "user:user1")
+		require.NoError(t, err)
+		assert.Equal(t, 0, usage.Daily.Used)
+		_, err = q.Reserve("user2", "user:user1", 2)
+		require.NoError(t, err)
+	})
+
+	t.Run("Expired links are not counted", func(t *testing.T) {
+		expired := now.Add(-time.Hour)
+		require.NoError(t, store.AddBatch([]storage.Link{{
+			ShortURL:    "expired1",
+			OriginalURL: "https://old.example",
+			UserID:      "user3",
+			ExpiresAt:   &expired,
+		}}))
+
+		usage, err := q.Usage("user3", "user:user3")
+		require.NoError(t, err)
+		assert.Equal(t, 0, usage.Links.Used)
+	})
+
+	t.Run("Nil quota allows everything", func(t *testing.T) {
+		var q *Quota
+		require.NoError(t, q.CheckBatch(1<<20))
+		r, err := q.Reserve("user1", "user:user1", 1<<20)
+		require.NoError(t, err)
+		assert.NoError(t, r.Done(0))
+	})
+}
+
+func TestQuotaParallelReserve(t *testing.T) {
+	q := New(entities.NewHashDict(), entities.NewBuckets(time.Hour), Limits{MaxLinks: 3})
+
+	var (
+		wg      sync.WaitGroup
+		granted atomic.Int32
+	)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := q.Reserve("user1", "user:user1", 1); err == nil {
+				granted.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, int32(3), granted.Load())
+}
@@ -0,0 +1,149 @@
+package safety
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+const regexPrefix = "re:"
+
+// Blocklist — список блокировки из файла, по строке на запись:
+//
+//	# комментарий
+//	evil.example          домен вместе с поддоменами
+//	re:^https?://[^/]+/login\.php   регулярное выражение для всей ссылки
+//
+// Файл перечитывается, когда меняется, так что править его можно без
+// перезапуска.
+type Blocklist struct {
+	path string
+
+	mu       sync.RWMutex
+	domains  map[string]bool
+	patterns []*regexp.Regexp
+	modTime  time.Time
+	size     int64
+}
+
+func LoadBlocklist(path string) (*Blocklist, error) {
+	b := &Blocklist{path: path}
+	if err := b.Reload(); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// Reload перечитывает файл. С ошибкой в файле остаётся прежний список.
+func (b *Blocklist) Reload() error {
+	info, err := os.Stat(b.path)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Open(b.path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	var (
+		domains  = make(map[string]bool)
+		patterns []*regexp.Regexp
+		scanner  = bufio.NewScanner(file)
+		line     int
+	)
+	for scanner.Scan() {
+		line++
+		entry := strings.TrimSpace(scanner.Text())
+		if entry == "" || strings.HasPrefix(entry, "#") {
+			continue
+		}
+
+		if expr, ok := strings.CutPrefix(entry, regexPrefix); ok {
+			re, err := regexp.Compile(expr)
+			if err != nil {
+				return fmt.Errorf("%s:%d: %w", b.path, line, err)
+			}
+			patterns = append(patterns, re)
+			continue
+		}
+
+		domains[strings.TrimSuffix(strings.ToLower(entry), ".")] = true
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	b.mu.Lock()
+	b.domains = domains
+	b.patterns = patterns
+	b.modTime = info.ModTime()
+	b.size = info.Size()
+	b.mu.Unlock()
+
+	return nil
+}
+
+// Watch проверяет файл раз в interval и перечитывает его, если он
+// изменился.
+func (b *Blocklist) Watch(interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+
+	go func() {
+		for range time.Tick(interval) {
+			info, err := os.Stat(b.path)
+			if err != nil {
+				log.Printf("blocklist %s: %v", b.path, err)
+				continue
+			}
+
+			b.mu.RLock()
+			changed := !info.ModTime().Equal(b.modTime) || info.Size() != b.size
+			b.mu.RUnlock()
+			if !changed {
+				continue
+			}
+
+			if err := b.Reload(); err != nil {
+				log.Printf("blocklist %s: %v", b.path, err)
+			}
+		}
+	}()
+}
+
+func (b *Blocklist) Check(_ context.Context, link string) (Verdict, error) {
+	u, err := url.Parse(link)
+	if err != nil {
+		return Verdict{}, err
+	}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	// evil.example блокирует и login.evil.example
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+	for host != "" {
+		if b.domains[host] {
+			return Verdict{Reason: ReasonBlocklist, Detail: "domain " + host + " is blocked"}, nil
+		}
+		_, host, _ = strings.Cut(host, ".")
+	}
+
+	for _, re := range b.patterns {
+		if re.MatchString(link) {
+			return Verdict{Reason: ReasonBlocklist, Detail: "url matches " + re.String()}, nil
+		}
+	}
+
+	return Verdict{}, nil
+}
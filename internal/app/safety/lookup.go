@@ -0,0 +1,124 @@
+package safety
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// сколько ответов сервиса держать в памяти, дальше устаревшие вычищаются
+const lookupCacheSize = 10000
+
+type (
+	// Lookup спрашивает внешний сервис проверки ссылок:
+	//
+	//	POST endpoint {"url": "..."} -> {"flagged": true, "reason": "phishing"}
+	//
+	// Ответы кешируются на ttl, ведь при каждом переходе ссылка
+	// проверяется заново.
+	Lookup struct {
+		endpoint string
+		client   *http.Client
+		ttl      time.Duration
+
+		mu    sync.Mutex
+		cache map[string]cachedVerdict
+	}
+
+	LookupRequest struct {
+		URL string `json:"url"`
+	}
+
+	LookupResponse struct {
+		Flagged bool   `json:"flagged"`
+		Reason  string `json:"reason,omitempty"`
+	}
+
+	cachedVerdict struct {
+		verdict Verdict
+		expires time.Time
+	}
+)
+
+func NewLookup(endpoint string, client *http.Client, ttl time.Duration) *Lookup {
+	if client == nil {
+		client = &http.Client{Timeout: 2 * time.Second}
+	}
+
+	return &Lookup{
+		endpoint: endpoint,
+		client:   client,
+		ttl:      ttl,
+		cache:    make(map[string]cachedVerdict),
+	}
+}
+
+func (l *Lookup) Check(ctx context.Context, link string) (Verdict, error) {
+	now := time.Now()
+
+	l.mu.Lock()
+	cached, ok := l.cache[link]
+	l.mu.Unlock()
+	if ok && now.Before(cached.expires) {
+		return cached.verdict, nil
+	}
+
+	verdict, err := l.lookup(ctx, link)
+	if err != nil {
+		return Verdict{}, err
+	}
+
+	if l.ttl > 0 {
+		l.mu.Lock()
+		if len(l.cache) >= lookupCacheSize {
+			for key, entry := range l.cache {
+				if !now.Before(entry.expires) {
+					delete(l.cache, key)
+				}
+			}
+		}
+		if len(l.cache) < lookupCacheSize {
+			l.cache[link] = cachedVerdict{verdict: verdict, expires: now.Add(l.ttl)}
+		}
+		l.mu.Unlock()
+	}
+
+	return verdict, nil
+}
+
+func (l *Lookup) lookup(ctx context.Context, link string) (Verdict, error) {
+	body, err := json.Marshal(LookupRequest{URL: link})
+	if err != nil {
+		return Verdict{}, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, l.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return Verdict{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := l.client.Do(req)
+	if err != nil {
+		return Verdict{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return Verdict{}, fmt.Errorf("lookup responded %d", resp.StatusCode)
+	}
+
+	var res LookupResponse
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return Verdict{}, err
+	}
+	if !res.Flagged {
+		return Verdict{}, nil
+	}
+
+	return Verdict{Reason: ReasonLookup, Detail: res.Reason}, nil
+}
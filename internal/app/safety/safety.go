@@ -0,0 +1,110 @@
+// Package safety проверяет, не ведёт ли ссылка на опасный адрес: по списку
+// блокировки, по внешнему сервису и на сам сокращатель.
+package safety
+
+import (
+	"context"
+	"log"
+	"net/url"
+	"strings"
+)
+
+const (
+	ReasonBlocklist = "blocklist"
+	ReasonLoop      = "loop"
+	ReasonLookup    = "lookup"
+)
+
+type (
+	// Verdict — почему ссылка опасна. Нулевой Verdict — ссылка чистая.
+	Verdict struct {
+		Reason string `json:"reason,omitempty"`
+		Detail string `json:"detail,omitempty"`
+	}
+
+	Checker interface {
+		Check(ctx context.Context, link string) (Verdict, error)
+	}
+
+	// Chain опрашивает проверки по очереди до первой сработавшей.
+	// Недоступная проверка пропускается, чтобы не мешать сокращать ссылки.
+	Chain []Checker
+
+	hostKey struct{}
+)
+
+func (v Verdict) Flagged() bool {
+	return v.Reason != ""
+}
+
+func (chain Chain) Check(ctx context.Context, link string) (Verdict, error) {
+	for _, checker := range chain {
+		verdict, err := checker.Check(ctx, link)
+		if err != nil {
+			log.Printf("safety check of %s: %v", link, err)
+			continue
+		}
+		if verdict.Flagged() {
+			return verdict, nil
+		}
+	}
+	return Verdict{}, nil
+}
+
+// WithHost запоминает адрес, по которому к нам пришёл запрос: ссылка на
+// него — тоже петля, даже если его нет в настройках.
+func WithHost(ctx context.Context, host string) context.Context {
+	return context.WithValue(ctx, hostKey{}, host)
+}
+
+// SelfHosts не даёт сокращать ссылки на сам сокращатель: такая короткая
+// ссылка ведёт на другую короткую, а то и на себя.
+type SelfHosts struct {
+	hosts map[string]bool
+}
+
+// NewSelfHosts принимает адреса вида host:port или базовые URL сервиса.
+func NewSelfHosts(hosts ...string) *SelfHosts {
+	s := &SelfHosts{hosts: make(map[string]bool, len(hosts))}
+	for _, host := range hosts {
+		if u, err := url.Parse(host); err == nil && u.Host != "" {
+			host = hostPort(u)
+		}
+		if host = strings.ToLower(strings.TrimSpace(host)); host != "" {
+			s.hosts[host] = true
+		}
+	}
+	return s
+}
+
+func (s *SelfHosts) Check(ctx context.Context, link string) (Verdict, error) {
+	u, err := url.Parse(link)
+	if err != nil {
+		return Verdict{}, err
+	}
+
+	target := hostPort(u)
+	own, _ := ctx.Value(hostKey{}).(string)
+
+	if s.hosts[target] || (own != "" && strings.EqualFold(own, target)) {
+		return Verdict{Reason: ReasonLoop, Detail: "url points back to the shortener"}, nil
+	}
+	return Verdict{}, nil
+}
+
+// hostPort — хост с портом, стандартный порт схемы не пишется.
+func hostPort(u *url.URL) string {
+	host := strings.ToLower(u.Hostname())
+	if strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+
+	switch port := u.Port(); {
+	case port == "",
+		port == "80" && u.Scheme == "http",
+		port == "443" && u.Scheme == "https":
+		return host
+	default:
+		return host + ":" + port
+	}
+}
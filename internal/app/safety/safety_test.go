@@ -0,0 +1,135 @@
+package safety_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/BazNick/shortlink/internal/app/safety"
+	"github.com/BazNick/shortlink/internal/app/safety/safetytest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type failingChecker struct{}
+
+func (failingChecker) Check(context.Context, string) (safety.Verdict, error) {
+	return safety.Verdict{}, errors.New("connection refused")
+}
+
+func TestBlocklist(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "blocklist.txt")
+	require.NoError(t, os.WriteFile(path, []byte("# phishing\nEvil.example\nre:/wp-login\\.php$\n"), 0o644))
+
+	blocklist, err := safety.LoadBlocklist(path)
+	require.NoError(t, err)
+
+	tests := []struct {
+		link   string
+		reason string
+	}{
+		{link: "https://evil.example/", reason: safety.ReasonBlocklist},
+		{link: "https://login.evil.example:8443/a", reason: safety.ReasonBlocklist},
+		{link: "https://notevil.example/"},
+		{link: "https://blog.example/wp-login.php", reason: safety.ReasonBlocklist},
+		{link: "https://blog.example/wp-login.php?x=1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.link, func(t *testing.T) {
+			verdict, err := blocklist.Check(context.Background(), tt.link)
+			require.NoError(t, err)
+			assert.Equal(t, tt.reason, verdict.Reason)
+		})
+	}
+
+	// сломанный файл не затирает прежний список
+	require.NoError(t, os.WriteFile(path, []byte("re:(\n"), 0o644))
+	require.Error(t, blocklist.Reload())
+	verdict, _ := blocklist.Check(context.Background(), "https://evil.example/")
+	assert.True(t, verdict.Flagged())
+
+	require.NoError(t, os.WriteFile(path, []byte("other.example\n"), 0o644))
+	require.NoError(t, blocklist.Reload())
+	verdict, _ = blocklist.Check(context.Background(), "https://evil.example/")
+	assert.False(t, verdict.Flagged())
+}
+
+func TestBlocklistWatch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "blocklist.txt")
+	require.NoError(t, os.WriteFile(path, nil, 0o644))
+
+	blocklist, err := safety.LoadBlocklist(path)
+	require.NoError(t, err)
+	blocklist.Watch(10 * time.Millisecond)
+
+	require.NoError(t, os.WriteFile(path, []byte("evil.example\n"), 0o644))
+	assert.Eventually(t, func() bool {
+		verdict, _ := blocklist.Check(context.Background(), "https://evil.example/")
+		return verdict.Flagged()
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestSelfHosts(t *testing.T) {
+	checker := safety.NewSelfHosts("https://sho.rt", "localhost:8080")
+	ctx := safety.WithHost(context.Background(), "127.0.0.1:8080")
+
+	tests := []struct {
+		link string
+		loop bool
+	}{
+		{link: "https://sho.rt/abc", loop: true},
+		{link: "https://SHO.RT:443/abc", loop: true},
+		{link: "http://sho.rt:8443/abc"},
+		{link: "http://localhost:8080/abc", loop: true},
+		{link: "http://localhost:3000/abc"},
+		{link: "http://127.0.0.1:8080/abc", loop: true},
+		{link: "https://example.com/"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.link, func(t *testing.T) {
+			verdict, err := checker.Check(ctx, tt.link)
+			require.NoError(t, err)
+			assert.Equal(t, tt.loop, verdict.Reason == safety.ReasonLoop)
+		})
+	}
+}
+
+func TestLookup(t *testing.T) {
+	server := safetytest.NewServer(t, "https://phish.example/")
+	lookup := safety.NewLookup(server.URL, nil, time.Minute)
+
+	verdict, err := lookup.Check(context.Background(), "https://phish.example/")
+	require.NoError(t, err)
+	assert.Equal(t, safety.Verdict{Reason: safety.ReasonLookup, Detail: "phishing"}, verdict)
+
+	verdict, err = lookup.Check(context.Background(), "https://example.com/")
+	require.NoError(t, err)
+	assert.False(t, verdict.Flagged())
+
+	// повторные проверки берутся из кеша
+	lookup.Check(context.Background(), "https://phish.example/")
+	assert.Equal(t, 2, server.Requests())
+
+	_, err = safety.NewLookup(server.URL+"/missing", nil, 0).Check(context.Background(), "https://example.com/")
+	assert.Error(t, err)
+}
+
+func TestChain(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "blocklist.txt")
+	require.NoError(t, os.WriteFile(path, []byte("evil.example\n"), 0o644))
+	blocklist, err := safety.LoadBlocklist(path)
+	require.NoError(t, err)
+
+	chain := safety.Chain{failingChecker{}, blocklist}
+
+	verdict, err := chain.Check(context.Background(), "https://evil.example/")
+	require.NoError(t, err)
+	assert.Equal(t, safety.ReasonBlocklist, verdict.Reason)
+
+	verdict, err = chain.Check(context.Background(), "https://example.com/")
+	require.NoError(t, err)
+	assert.False(t, verdict.Flagged())
+}
@@ -0,0 +1,72 @@
+// Package safetytest — локальный сервис проверки ссылок для тестов.
+package safetytest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/BazNick/shortlink/internal/app/safety"
+)
+
+// Server помечает опасными ссылки из Flagged с причиной "phishing" и
+// считает запросы к себе.
+type Server struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	flagged  map[string]bool
+	requests int
+}
+
+func NewServer(t testing.TB, flagged ...string) *Server {
+	t.Helper()
+
+	s := &Server{flagged: make(map[string]bool)}
+	for _, link := range flagged {
+		s.flagged[link] = true
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /{$}", s.lookup)
+
+	s.Server = httptest.NewServer(mux)
+	t.Cleanup(s.Close)
+
+	return s
+}
+
+// Flag помечает ссылку опасной.
+func (s *Server) Flag(link string) {
+	s.mu.Lock()
+	s.flagged[link] = true
+	s.mu.Unlock()
+}
+
+func (s *Server) Requests() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests
+}
+
+func (s *Server) lookup(w http.ResponseWriter, r *http.Request) {
+	var req safety.LookupRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	s.requests++
+	res := safety.LookupResponse{Flagged: s.flagged[req.URL]}
+	s.mu.Unlock()
+
+	if res.Flagged {
+		res.Reason = "phishing"
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(res)
+}
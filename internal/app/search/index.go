@@ -0,0 +1,200 @@
+// Package search — полнотекстовый поиск по ссылкам для хранилищ без
+// Postgres: обратный индекс в памяти, который строится при старте и
+// обновляется на каждой записи через обёртку Indexed.
+package search
+
+import (
+	"cmp"
+	"math"
+	"slices"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/BazNick/shortlink/internal/app/storage"
+)
+
+// веса полей: совпадение в заголовке важнее совпадения в адресе
+const (
+	weightTitle       = 4
+	weightTags        = 3
+	weightDescription = 2
+	weightURL         = 1
+
+	// совпадение по началу слова стоит меньше совпадения целиком
+	prefixFactor = 0.5
+	minTokenLen  = 2
+)
+
+// слова, которые есть почти в каждом адресе и ничего не различают
+var stopWords = map[string]bool{"http": true, "https": true, "www": true}
+
+type (
+	// Index — обратные индексы по пользователям: слово → короткие коды →
+	// вес слова в ссылке. Поиск идёт только по словам самого пользователя,
+	// поэтому чужие ссылки его не замедляют. Удалённые ссылки в индекс не
+	// попадают.
+	Index struct {
+		mu    sync.RWMutex
+		docs  map[string]document
+		users map[string]*userIndex
+	}
+
+	document struct {
+		userID string
+		terms  map[string]float64
+	}
+
+	userIndex struct {
+		docs  int
+		terms map[string]map[string]float64
+	}
+)
+
+func NewIndex() *Index {
+	return &Index{
+		docs:  make(map[string]document),
+		users: make(map[string]*userIndex),
+	}
+}
+
+// Tokens делит текст на слова в нижнем регистре: всё, что не буква и не
+// цифра, — разделитель, так что адрес распадается на хост и части пути.
+func Tokens(text string) []string {
+	var tokens []string
+	for _, token := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if len([]rune(token)) < minTokenLen || stopWords[token] {
+			continue
+		}
+		tokens = append(tokens, token)
+	}
+	return tokens
+}
+
+// Put добавляет ссылку в индекс или заменяет прежнюю версию.
+func (idx *Index) Put(link storage.Link) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	idx.remove(link.ShortURL)
+	if link.IsDeleted {
+		return
+	}
+
+	terms := make(map[string]float64)
+	add := func(text string, weight float64) {
+		for _, token := range Tokens(text) {
+			terms[token] += weight
+		}
+	}
+	add(link.Title, weightTitle)
+	add(strings.Join(link.Tags, " "), weightTags)
+	add(link.Description, weightDescription)
+	add(link.OriginalURL, weightURL)
+
+	user, ok := idx.users[link.UserID]
+	if !ok {
+		user = &userIndex{terms: make(map[string]map[string]float64)}
+		idx.users[link.UserID] = user
+	}
+
+	idx.docs[link.ShortURL] = document{userID: link.UserID, terms: terms}
+	user.docs++
+	for term, weight := range terms {
+		postings, ok := user.terms[term]
+		if !ok {
+			postings = make(map[string]float64)
+			user.terms[term] = postings
+		}
+		postings[link.ShortURL] = weight
+	}
+}
+
+func (idx *Index) Remove(hash string) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	idx.remove(hash)
+}
+
+func (idx *Index) remove(hash string) {
+	doc, ok := idx.docs[hash]
+	if !ok {
+		return
+	}
+
+	user := idx.users[doc.userID]
+	for term := range doc.terms {
+		delete(user.terms[term], hash)
+		if len(user.terms[term]) == 0 {
+			delete(user.terms, term)
+		}
+	}
+	user.docs--
+	if user.docs == 0 {
+		delete(idx.users, doc.userID)
+	}
+	delete(idx.docs, hash)
+}
+
+// Search ищет ссылки, в которых есть хотя бы одно слово запроса целиком
+// или как начало слова. Ранг — сумма весов совпавших слов, умноженных на
+// их редкость среди ссылок пользователя (idf), так что ссылка, совпавшая
+// по нескольким словам, выше совпавшей по одному. У найденных ссылок
+// заполнены только код и владелец, остальное индекс не хранит.
+func (idx *Index) Search(q storage.SearchQuery) []storage.SearchResult {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	user, ok := idx.users[q.UserID]
+	if !ok {
+		return []storage.SearchResult{}
+	}
+
+	var (
+		n     = float64(user.docs)
+		ranks = make(map[string]float64)
+	)
+
+	tokens := Tokens(q.Text)
+	slices.Sort(tokens)
+
+	for _, token := range slices.Compact(tokens) {
+		for term, postings := range user.terms {
+			factor := 1.0
+			switch {
+			case term == token:
+			case strings.HasPrefix(term, token):
+				factor = prefixFactor
+			default:
+				continue
+			}
+
+			idf := math.Log(1 + n/float64(len(postings)))
+			for hash, weight := range postings {
+				ranks[hash] += factor * weight * idf
+			}
+		}
+	}
+
+	results := make([]storage.SearchResult, 0, len(ranks))
+	for hash, rank := range ranks {
+		results = append(results, storage.SearchResult{
+			Link: storage.Link{ShortURL: hash, UserID: q.UserID},
+			Rank: rank,
+		})
+	}
+	slices.SortFunc(results, func(a, b storage.SearchResult) int {
+		if n := cmp.Compare(b.Rank, a.Rank); n != 0 {
+			return n
+		}
+		return strings.Compare(a.ShortURL, b.ShortURL)
+	})
+
+	if q.Limit > 0 && len(results) > q.Limit {
+		results = results[:q.Limit]
+	}
+	return results
+}
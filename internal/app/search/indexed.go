@@ -0,0 +1,145 @@
+package search
+
+import (
+	"github.com/BazNick/shortlink/internal/app/storage"
+)
+
+// Indexed — обёртка над хранилищем, которая держит индекс в актуальном
+// состоянии: после каждой записи затронутые ссылки перечитываются из
+// хранилища и переиндексируются.
+type Indexed struct {
+	next  storage.Storage
+	index *Index
+}
+
+// NewIndexed индексирует все ссылки next.
+func NewIndexed(next storage.Storage) (*Indexed, error) {
+	s := &Indexed{next: next, index: NewIndex()}
+
+	err := next.Links(func(link storage.Link) error {
+		s.index.Put(link)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+func (s *Indexed) Unwrap() storage.Storage {
+	return s.next
+}
+
+func (s *Indexed) SearchLinks(q storage.SearchQuery) ([]storage.SearchResult, error) {
+	found := s.index.Search(q)
+
+	results := make([]storage.SearchResult, 0, len(found))
+	for _, res := range found {
+		link, err := s.next.GetLink(res.ShortURL)
+		if err != nil {
+			// ссылку могли удалить уже после поиска
+			continue
+		}
+		res.Link = link
+		results = append(results, res)
+	}
+
+	return results, nil
+}
+
+func (s *Indexed) AddHash(hash, link, userID string) (string, error) {
+	shortURL, err := s.next.AddHash(hash, link, userID)
+	s.reindex(hash)
+	return shortURL, err
+}
+
+func (s *Indexed) AddBatch(links []storage.Link) error {
+	err := s.next.AddBatch(links)
+	for _, link := range links {
+		s.reindex(link.ShortURL)
+	}
+	return err
+}
+
+func (s *Indexed) InsertLink(link storage.Link) error {
+	err := storage.InsertLink(s.next, link)
+	if err == nil {
+		s.reindex(link.ShortURL)
+	}
+	return err
+}
+
+func (s *Indexed) GetHash(hash string) string {
+	return s.next.GetHash(hash)
+}
+
+func (s *Indexed) GetLink(hash string) (storage.Link, error) {
+	return s.next.GetLink(hash)
+}
+
+func (s *Indexed) CheckValExists(userID, link string) bool {
+	return s.next.CheckValExists(userID, link)
+}
+
+func (s *Indexed) SetDedup(mode storage.Dedup) error {
+	return storage.SetDedup(s.next, mode)
+}
+
+func (s *Indexed) DeleteLinks(userID string, hashes []string) error {
+	err := s.next.DeleteLinks(userID, hashes)
+	for _, hash := range hashes {
+		s.reindex(hash)
+	}
+	return err
+}
+
+func (s *Indexed) Links(fn func(link storage.Link) error) error {
+	return s.next.Links(fn)
+}
+
+func (s *Indexed) UserLinks(userID string, fn func(link storage.Link) error) error {
+	return s.next.UserLinks(userID, fn)
+}
+
+func (s *Indexed) WorkspaceLinks(workspaceID string, fn func(link storage.Link) error) error {
+	return s.next.WorkspaceLinks(workspaceID, fn)
+}
+
+func (s *Indexed) ListLinks(q storage.LinkQuery) ([]storage.Link, error) {
+	return storage.ListLinks(s.next, q)
+}
+
+func (s *Indexed) AddClicks(clicks map[string]storage.Clicks) error {
+	return storage.AddClicks(s.next, clicks)
+}
+
+// ReassignLinks переиндексирует все ссылки нового владельца: какие из них
+// перешли от from, хранилище не сообщает.
+func (s *Indexed) ReassignLinks(from, to string) (int, error) {
+	n, err := s.next.ReassignLinks(from, to)
+	if n > 0 {
+		s.next.UserLinks(to, func(link storage.Link) error {
+			s.index.Put(link)
+			return nil
+		})
+	}
+	return n, err
+}
+
+func (s *Indexed) UpdateLink(hash string, fn func(link *storage.Link) error) (storage.Link, error) {
+	link, err := s.next.UpdateLink(hash, fn)
+	if err == nil {
+		s.index.Put(link)
+	}
+	return link, err
+}
+
+func (s *Indexed) reindex(hash string) {
+	link, err := s.next.GetLink(hash)
+	if err != nil {
+		s.index.Remove(hash)
+		return
+	}
+	s.index.Put(link)
+}
@@ -0,0 +1,100 @@
+package search
+
+import (
+	"testing"
+
+	"github.com/BazNick/shortlink/internal/app/entities"
+	"github.com/BazNick/shortlink/internal/app/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTokens(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []string
+	}{
+		{in: "https://jira.example.com/browse/PROJ-123", want: []string{"jira", "example", "com", "browse", "proj", "123"}},
+		{in: "Отчёт за Май", want: []string{"отчёт", "за", "май"}},
+		{in: "a b  ", want: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			assert.Equal(t, tt.want, Tokens(tt.in))
+		})
+	}
+}
+
+func TestIndexed(t *testing.T) {
+	dict := entities.NewHashDict()
+	require.NoError(t, dict.AddBatch([]storage.Link{
+		{ShortURL: "jira0001", OriginalURL: "https://jira.example.com/browse/PROJ-1", UserID: "u"},
+	}))
+
+	s, err := NewIndexed(dict)
+	require.NoError(t, err)
+
+	_, err = s.AddHash("docs0001", "https://docs.example.com/jira-guide", "u")
+	require.NoError(t, err)
+	require.NoError(t, s.AddBatch([]storage.Link{
+		{ShortURL: "titled01", OriginalURL: "https://example.com/x", UserID: "u", Title: "Jira board of the week", Tags: []string{"work"}},
+		{ShortURL: "others01", OriginalURL: "https://jira.example.com/browse/PROJ-2", UserID: "other"},
+	}))
+
+	codes := func(text string) []string {
+		results, err := storage.SearchLinks(s, storage.SearchQuery{UserID: "u", Text: text})
+		require.NoError(t, err)
+
+		var result []string
+		for _, res := range results {
+			require.Equal(t, "u", res.UserID)
+			require.NotEmpty(t, res.OriginalURL)
+			result = append(result, res.ShortURL)
+		}
+		return result
+	}
+
+	// заголовок весит больше адреса, чужие ссылки не находятся
+	assert.Equal(t, "titled01", codes("that Jira link from last week")[0])
+	assert.ElementsMatch(t, []string{"titled01", "jira0001", "docs0001"}, codes("jira"))
+	assert.Equal(t, []string{"titled01"}, codes("WOR"))
+	assert.Empty(t, codes("confluence"))
+
+	_, err = s.UpdateLink("docs0001", func(link *storage.Link) error {
+		link.OriginalURL = "https://docs.example.com/confluence"
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"docs0001"}, codes("confluence"))
+	assert.NotContains(t, codes("jira"), "docs0001")
+
+	require.NoError(t, s.DeleteLinks("u", []string{"jira0001"}))
+	assert.Equal(t, []string{"titled01"}, codes("jira"))
+
+	n, err := s.ReassignLinks("other", "u")
+	require.NoError(t, err)
+	require.Equal(t, 1, n)
+	assert.Contains(t, codes("proj"), "others01")
+}
+
+func TestIndexUsers(t *testing.T) {
+	idx := NewIndex()
+	idx.Put(storage.Link{ShortURL: "jira0001", OriginalURL: "https://jira.example.com/", UserID: "a"})
+
+	search := func(userID string) []storage.SearchResult {
+		return idx.Search(storage.SearchQuery{UserID: userID, Text: "jira"})
+	}
+	require.Len(t, search("a"), 1)
+	assert.Empty(t, search("b"))
+
+	// ссылка перешла к другому владельцу вместе со словами
+	idx.Put(storage.Link{ShortURL: "jira0001", OriginalURL: "https://jira.example.com/", UserID: "b"})
+	assert.Empty(t, search("a"))
+	require.Len(t, search("b"), 1)
+
+	idx.Remove("jira0001")
+	assert.Empty(t, search("b"))
+	assert.Empty(t, idx.users)
+	assert.Empty(t, idx.docs)
+}
@@ -0,0 +1,41 @@
+package storage
+
+import "time"
+
+const (
+	ScopeRead   = "read"
+	ScopeWrite  = "write"
+	ScopeDelete = "delete"
+)
+
+var Scopes = []string{ScopeRead, ScopeWrite, ScopeDelete}
+
+// APIKey — долгоживущий ключ пользователя. Сам секрет не хранится,
+// только его хэш.
+type APIKey struct {
+	ID         string     `json:"id"`
+	UserID     string     `json:"user_id"`
+	Name       string     `json:"name"`
+	Hash       string     `json:"hash"`
+	Scopes     []string   `json:"scopes"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+}
+
+type APIKeyStore interface {
+	AddAPIKey(key APIKey) error
+	APIKeyByHash(hash string) (APIKey, error)
+	UserAPIKeys(userID string) ([]APIKey, error)
+	RevokeAPIKey(userID, id string) error
+	TouchAPIKey(id string, at time.Time) error
+}
+
+func (k APIKey) HasScope(scope string) bool {
+	for _, s := range k.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
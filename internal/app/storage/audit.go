@@ -0,0 +1,70 @@
+package storage
+
+import "time"
+
+// действия в журнале аудита
+const (
+	AuditCreate  = "create"
+	AuditUpdate  = "update"
+	AuditDelete  = "delete"
+	AuditRestore = "restore"
+	AuditOwner   = "owner"
+	AuditDisable = "disable"
+	AuditEnable  = "enable"
+	// ссылки анонимного пользователя перешли к аккаунту при входе
+	AuditClaim = "claim"
+)
+
+type (
+	// AuditSource — кто и откуда совершил действие.
+	AuditSource struct {
+		ActorID   string `json:"actor_id"`
+		IP        string `json:"ip,omitempty"`
+		RequestID string `json:"request_id,omitempty"`
+	}
+
+	// AuditEntry — запись журнала. Before пуст у созданных ссылок, After —
+	// если ссылки после действия нет.
+	AuditEntry struct {
+		ID       string    `json:"id"`
+		Time     time.Time `json:"time"`
+		Action   string    `json:"action"`
+		ShortURL string    `json:"short_url,omitempty"`
+		AuditSource
+		Before *Link  `json:"before,omitempty"`
+		After  *Link  `json:"after,omitempty"`
+		Note   string `json:"note,omitempty"`
+	}
+
+	// AuditFilter — пустые поля не ограничивают выборку.
+	AuditFilter struct {
+		ShortURL string
+		ActorID  string
+		Action   string
+		Since    time.Time
+		Until    time.Time
+	}
+
+	// AuditStore — журнал только дописывается, записи не меняются и не
+	// удаляются. AuditEntries отдаёт записи от новых к старым.
+	AuditStore interface {
+		AddAudit(entry AuditEntry) error
+		AuditEntries(filter AuditFilter, fn func(entry AuditEntry) error) error
+	}
+)
+
+func (f AuditFilter) Match(e AuditEntry) bool {
+	switch {
+	case f.ShortURL != "" && e.ShortURL != f.ShortURL:
+		return false
+	case f.ActorID != "" && e.ActorID != f.ActorID:
+		return false
+	case f.Action != "" && e.Action != f.Action:
+		return false
+	case !f.Since.IsZero() && e.Time.Before(f.Since):
+		return false
+	case !f.Until.IsZero() && !e.Time.Before(f.Until):
+		return false
+	}
+	return true
+}
@@ -0,0 +1,18 @@
+package storage
+
+import "time"
+
+// Bucket — состояние одного ограничителя частоты запросов. Пустой
+// UpdatedAt значит, что ограничитель новый и полон.
+type Bucket struct {
+	Tokens    float64
+	UpdatedAt time.Time
+}
+
+// BucketStore хранит ограничители частоты запросов. UpdateBucket должен
+// выполнять fn атомарно, чтобы реплики с общим хранилищем не разошлись.
+// GetBucket только читает: неизвестный ключ — пустой Bucket.
+type BucketStore interface {
+	UpdateBucket(key string, fn func(b *Bucket)) error
+	GetBucket(key string) (Bucket, error)
+}
@@ -0,0 +1,271 @@
+package storage
+
+import (
+	"container/list"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type (
+	CacheConfig struct {
+		Size        int
+		TTL         time.Duration
+		NegativeTTL time.Duration
+	}
+
+	CacheStats struct {
+		Size         int     `json:"size"`
+		Capacity     int     `json:"capacity"`
+		Hits         uint64  `json:"hits"`
+		NegativeHits uint64  `json:"negative_hits"`
+		Misses       uint64  `json:"misses"`
+		Evictions    uint64  `json:"evictions"`
+		HitRate      float64 `json:"hit_rate"`
+	}
+
+	// Cache — read-through кэш поверх любого хранилища. Держит ограниченное
+	// число коротких ссылок (LRU) с TTL, помнит и отсутствующие ссылки.
+	Cache struct {
+		next Storage
+		conf CacheConfig
+
+		mu    sync.Mutex
+		order *list.List
+		items map[string]*list.Element
+		// gen растёт при каждой инвалидации, чтобы не положить в кэш
+		// значение, прочитанное до удаления
+		gen uint64
+
+		hits         atomic.Uint64
+		negativeHits atomic.Uint64
+		misses       atomic.Uint64
+		evictions    atomic.Uint64
+	}
+
+	cacheEntry struct {
+		hash      string
+		link      string
+		expiresAt time.Time
+	}
+)
+
+func NewCache(next Storage, conf CacheConfig) *Cache {
+	if conf.NegativeTTL == 0 {
+		conf.NegativeTTL = conf.TTL
+	}
+
+	return &Cache{
+		next:  next,
+		conf:  conf,
+		order: list.New(),
+		items: make(map[string]*list.Element),
+	}
+}
+
+func (c *Cache) Unwrap() Storage {
+	return c.next
+}
+
+func (c *Cache) AddHash(hash, link, userID string) (string, error) {
+	shortURL, err := c.next.AddHash(hash, link, userID)
+	// короткий код мог попасть в кэш как отсутствующий
+	c.Invalidate(hash)
+	return shortURL, err
+}
+
+func (c *Cache) AddBatch(links []Link) error {
+	err := c.next.AddBatch(links)
+
+	hashes := make([]string, len(links))
+	for i, link := range links {
+		hashes[i] = link.ShortURL
+	}
+	c.Invalidate(hashes...)
+
+	return err
+}
+
+func (c *Cache) InsertLink(link Link) error {
+	err := InsertLink(c.next, link)
+	c.Invalidate(link.ShortURL)
+	return err
+}
+
+func (c *Cache) GetHash(hash string) string {
+	link, gen, ok := c.lookup(hash)
+	if ok {
+		if link == "" {
+			c.negativeHits.Add(1)
+		} else {
+			c.hits.Add(1)
+		}
+		return link
+	}
+
+	c.misses.Add(1)
+	link = c.next.GetHash(hash)
+
+	ttl := c.conf.TTL
+	if link == "" {
+		ttl = c.conf.NegativeTTL
+	} else if rec, err := c.next.GetLink(hash); err == nil && rec.ExpiresAt != nil {
+		// ссылка не должна пережить в кэше свой срок
+		left := time.Until(*rec.ExpiresAt)
+		if left <= 0 {
+			return link
+		}
+		if ttl <= 0 || left < ttl {
+			ttl = left
+		}
+	}
+	c.store(hash, link, ttl, gen)
+
+	return link
+}
+
+func (c *Cache) GetLink(hash string) (Link, error) {
+	return c.next.GetLink(hash)
+}
+
+func (c *Cache) CheckValExists(userID, link string) bool {
+	return c.next.CheckValExists(userID, link)
+}
+
+func (c *Cache) SetDedup(mode Dedup) error {
+	return SetDedup(c.next, mode)
+}
+
+func (c *Cache) ListLinks(q LinkQuery) ([]Link, error) {
+	return ListLinks(c.next, q)
+}
+
+func (c *Cache) SearchLinks(q SearchQuery) ([]SearchResult, error) {
+	return SearchLinks(c.next, q)
+}
+
+func (c *Cache) AddClicks(clicks map[string]Clicks) error {
+	return AddClicks(c.next, clicks)
+}
+
+func (c *Cache) DeleteLinks(userID string, hashes []string) error {
+	err := c.next.DeleteLinks(userID, hashes)
+	c.Invalidate(hashes...)
+	return err
+}
+
+func (c *Cache) Links(fn func(link Link) error) error {
+	return c.next.Links(fn)
+}
+
+func (c *Cache) UserLinks(userID string, fn func(link Link) error) error {
+	return c.next.UserLinks(userID, fn)
+}
+
+func (c *Cache) WorkspaceLinks(workspaceID string, fn func(link Link) error) error {
+	return c.next.WorkspaceLinks(workspaceID, fn)
+}
+
+func (c *Cache) ReassignLinks(from, to string) (int, error) {
+	return c.next.ReassignLinks(from, to)
+}
+
+func (c *Cache) UpdateLink(hash string, fn func(link *Link) error) (Link, error) {
+	link, err := c.next.UpdateLink(hash, fn)
+	c.Invalidate(hash)
+	return link, err
+}
+
+// Invalidate убирает короткие ссылки из кэша.
+func (c *Cache) Invalidate(hashes ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.gen++
+	for _, hash := range hashes {
+		if el, ok := c.items[hash]; ok {
+			c.order.Remove(el)
+			delete(c.items, hash)
+		}
+	}
+}
+
+func (c *Cache) Stats() CacheStats {
+	c.mu.Lock()
+	size := c.order.Len()
+	c.mu.Unlock()
+
+	stats := CacheStats{
+		Size:         size,
+		Capacity:     c.conf.Size,
+		Hits:         c.hits.Load(),
+		NegativeHits: c.negativeHits.Load(),
+		Misses:       c.misses.Load(),
+		Evictions:    c.evictions.Load(),
+	}
+
+	if total := stats.Hits + stats.NegativeHits + stats.Misses; total > 0 {
+		stats.HitRate = float64(stats.Hits+stats.NegativeHits) / float64(total)
+	}
+
+	return stats
+}
+
+func (c *Cache) lookup(hash string) (string, uint64, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.items[hash]
+	if !ok {
+		return "", c.gen, false
+	}
+
+	entry := el.Value.(*cacheEntry)
+	if !entry.expiresAt.IsZero() && time.Now().After(entry.expiresAt) {
+		c.order.Remove(el)
+		delete(c.items, hash)
+		return "", c.gen, false
+	}
+
+	c.order.MoveToFront(el)
+	return entry.link, c.gen, true
+}
+
+func (c *Cache) store(hash, link string, ttl time.Duration, gen uint64) {
+	if c.conf.Size <= 0 {
+		return
+	}
+
+	var expiresAt time.Time
+	if ttl > 0 {
+		expiresAt = time.Now().Add(ttl)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if gen != c.gen {
+		return
+	}
+
+	if el, ok := c.items[hash]; ok {
+		entry := el.Value.(*cacheEntry)
+		entry.link = link
+		entry.expiresAt = expiresAt
+		c.order.MoveToFront(el)
+		return
+	}
+
+	c.items[hash] = c.order.PushFront(&cacheEntry{
+		hash:      hash,
+		link:      link,
+		expiresAt: expiresAt,
+	})
+
+	for c.order.Len() > c.conf.Size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.items, oldest.Value.(*cacheEntry).hash)
+		c.evictions.Add(1)
+	}
+}
@@ -0,0 +1,217 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/BazNick/shortlink/internal/app/apperr"
+	"github.com/stretchr/testify/require"
+)
+
+type countingStorage struct {
+	links   map[string]string
+	expires map[string]time.Time
+	gets    int
+}
+
+func (s *countingStorage) AddHash(hash, link, userID string) (string, error) {
+	s.links[hash] = link
+	return "", nil
+}
+
+func (s *countingStorage) AddBatch(links []Link) error {
+	for _, link := range links {
+		s.links[link.ShortURL] = link.OriginalURL
+	}
+	return nil
+}
+
+func (s *countingStorage) GetHash(hash string) string {
+	s.gets++
+	if at, ok := s.expires[hash]; ok && time.Now().After(at) {
+		return ""
+	}
+	return s.links[hash]
+}
+
+func (s *countingStorage) GetLink(hash string) (Link, error) {
+	link, ok := s.links[hash]
+	if !ok {
+		return Link{}, apperr.ErrLinkNotFound
+	}
+	rec := Link{ShortURL: hash, OriginalURL: link}
+	if at, ok := s.expires[hash]; ok {
+		rec.ExpiresAt = &at
+	}
+	return rec, nil
+}
+
+func (s *countingStorage) CheckValExists(userID, link string) bool {
+	return false
+}
+
+func (s *countingStorage) DeleteLinks(userID string, hashes []string) error {
+	for _, hash := range hashes {
+		delete(s.links, hash)
+	}
+	return nil
+}
+
+func (s *countingStorage) Links(fn func(link Link) error) error {
+	for hash, link := range s.links {
+		if err := fn(Link{ShortURL: hash, OriginalURL: link}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *countingStorage) UserLinks(userID string, fn func(link Link) error) error {
+	return s.Links(fn)
+}
+
+func (s *countingStorage) WorkspaceLinks(workspaceID string, fn func(link Link) error) error {
+	return nil
+}
+
+func (s *countingStorage) ReassignLinks(from, to string) (int, error) {
+	return 0, nil
+}
+
+func (s *countingStorage) UpdateLink(hash string, fn func(link *Link) error) (Link, error) {
+	link, err := s.GetLink(hash)
+	if err != nil {
+		return Link{}, err
+	}
+	if err := fn(&link); err != nil {
+		return Link{}, err
+	}
+	s.links[hash] = link.OriginalURL
+	return link, nil
+}
+
+func TestCache(t *testing.T) {
+	tests := []struct {
+		name     string
+		conf     CacheConfig
+		run      func(c *Cache, s *countingStorage)
+		wantGets int
+		wantLink string
+		hash     string
+	}{
+		{
+			name: "Repeated reads hit the cache",
+			conf: CacheConfig{Size: 10, TTL: time.Minute},
+			run: func(c *Cache, s *countingStorage) {
+				c.GetHash("a")
+				c.GetHash("a")
+			},
+			hash:     "a",
+			wantGets: 1,
+			wantLink: "https://a.ru",
+		},
+		{
+			name: "Unknown links are cached too",
+			conf: CacheConfig{Size: 10, TTL: time.Minute},
+			run: func(c *Cache, s *countingStorage) {
+				c.GetHash("missing")
+			},
+			hash:     "missing",
+			wantGets: 1,
+			wantLink: "",
+		},
+		{
+			name: "Adding a link drops the negative entry",
+			conf: CacheConfig{Size: 10, TTL: time.Minute},
+			run: func(c *Cache, s *countingStorage) {
+				c.GetHash("new")
+				c.AddHash("new", "https://new.ru", "user")
+			},
+			hash:     "new",
+			wantGets: 2,
+			wantLink: "https://new.ru",
+		},
+		{
+			name: "Delete invalidates the entry",
+			conf: CacheConfig{Size: 10, TTL: time.Minute},
+			run: func(c *Cache, s *countingStorage) {
+				c.GetHash("a")
+				c.DeleteLinks("user", []string{"a"})
+			},
+			hash:     "a",
+			wantGets: 2,
+			wantLink: "",
+		},
+		{
+			name: "Least recently used entry is evicted",
+			conf: CacheConfig{Size: 1, TTL: time.Minute},
+			run: func(c *Cache, s *countingStorage) {
+				c.GetHash("a")
+				c.GetHash("b")
+			},
+			hash:     "a",
+			wantGets: 3,
+			wantLink: "https://a.ru",
+		},
+		{
+			name: "Expired entries are reloaded",
+			conf: CacheConfig{Size: 10, TTL: time.Nanosecond},
+			run: func(c *Cache, s *countingStorage) {
+				c.GetHash("a")
+				time.Sleep(time.Millisecond)
+			},
+			hash:     "a",
+			wantGets: 2,
+			wantLink: "https://a.ru",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &countingStorage{links: map[string]string{
+				"a": "https://a.ru",
+				"b": "https://b.ru",
+			}}
+			c := NewCache(s, tt.conf)
+
+			tt.run(c, s)
+
+			require.Equal(t, tt.wantLink, c.GetHash(tt.hash))
+			require.Equal(t, tt.wantGets, s.gets)
+		})
+	}
+}
+
+func TestCacheLinkExpiry(t *testing.T) {
+	s := &countingStorage{
+		links:   map[string]string{"a": "https://a.ru"},
+		expires: map[string]time.Time{"a": time.Now().Add(20 * time.Millisecond)},
+	}
+	c := NewCache(s, CacheConfig{Size: 10, TTL: time.Minute})
+
+	require.Equal(t, "https://a.ru", c.GetHash("a"))
+	require.Equal(t, "https://a.ru", c.GetHash("a"))
+	require.Equal(t, 1, s.gets)
+
+	// запись в кэше истекает вместе со ссылкой, а не через TTL
+	time.Sleep(30 * time.Millisecond)
+	require.Equal(t, "", c.GetHash("a"))
+	require.Equal(t, 2, s.gets)
+}
+
+func TestCacheStats(t *testing.T) {
+	s := &countingStorage{links: map[string]string{"a": "https://a.ru"}}
+	c := NewCache(s, CacheConfig{Size: 10, TTL: time.Minute})
+
+	c.GetHash("a")
+	c.GetHash("a")
+	c.GetHash("a")
+	c.GetHash("missing")
+	c.GetHash("missing")
+
+	stats := c.Stats()
+	require.Equal(t, uint64(2), stats.Hits)
+	require.Equal(t, uint64(1), stats.NegativeHits)
+	require.Equal(t, uint64(2), stats.Misses)
+	require.InDelta(t, 0.6, stats.HitRate, 0.001)
+}
@@ -0,0 +1,57 @@
+package storage
+
+import (
+	"errors"
+	"strings"
+)
+
+// Dedup — какие ссылки считаются повтором уже сокращённой.
+type Dedup string
+
+const (
+	// DedupGlobal — один адрес сокращается один раз на весь сервис
+	DedupGlobal Dedup = "global"
+	// DedupUser — один адрес один раз у каждого пользователя
+	DedupUser Dedup = "user"
+	// DedupOff — повторы не ищутся, каждый раз новая короткая ссылка
+	DedupOff Dedup = "off"
+)
+
+var ErrInvalidDedup = errors.New("dedup must be global, user or off")
+
+// Deduper реализуют хранилища, которые сами следят за повторами, например
+// уникальным индексом. Без SetDedup хранилище работает в DedupGlobal.
+type Deduper interface {
+	SetDedup(mode Dedup) error
+}
+
+func ParseDedup(s string) (Dedup, error) {
+	switch mode := Dedup(strings.ToLower(strings.TrimSpace(s))); mode {
+	case "":
+		return DedupGlobal, nil
+	case DedupGlobal, DedupUser, DedupOff:
+		return mode, nil
+	default:
+		return "", ErrInvalidDedup
+	}
+}
+
+// Duplicate — повтор ли ссылка existing адреса link пользователя userID.
+func (d Dedup) Duplicate(existing Link, userID, link string) bool {
+	switch d {
+	case DedupOff:
+		return false
+	case DedupUser:
+		return existing.OriginalURL == link && existing.UserID == userID
+	default:
+		return existing.OriginalURL == link
+	}
+}
+
+// SetDedup передаёт режим хранилищу s, если оно его поддерживает.
+func SetDedup(s Storage, mode Dedup) error {
+	if d, ok := s.(Deduper); ok {
+		return d.SetDedup(mode)
+	}
+	return nil
+}
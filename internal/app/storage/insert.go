@@ -0,0 +1,32 @@
+package storage
+
+import (
+	"errors"
+
+	"github.com/BazNick/shortlink/internal/app/apperr"
+)
+
+// Inserter реализуют хранилища, которые проверяют, свободен ли короткий
+// код, и добавляют ссылку одной операцией.
+type Inserter interface {
+	// InsertLink добавляет ссылку целиком, если её код свободен, иначе
+	// возвращает apperr.ErrCodeTaken. Существующую ссылку не трогает.
+	InsertLink(link Link) error
+}
+
+// InsertLink добавляет ссылку, не затирая чужую с тем же кодом. У хранилищ
+// без Inserter проверка и запись не атомарны.
+func InsertLink(s Storage, link Link) error {
+	if i, ok := s.(Inserter); ok {
+		return i.InsertLink(link)
+	}
+
+	_, err := s.GetLink(link.ShortURL)
+	if err == nil {
+		return apperr.ErrCodeTaken
+	}
+	if !errors.Is(err, apperr.ErrLinkNotFound) {
+		return err
+	}
+	return s.AddBatch([]Link{link})
+}
@@ -0,0 +1,176 @@
+package storage
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	SortCreated = "created"
+	SortClicks  = "clicks"
+
+	StateActive  = "active"
+	StateDeleted = "deleted"
+	StateExpired = "expired"
+)
+
+type (
+	// ListCursor — последняя ссылка предыдущей страницы: следующая
+	// начинается сразу после неё.
+	ListCursor struct {
+		CreatedAt time.Time `json:"created_at"`
+		Clicks    int64     `json:"clicks"`
+		ShortURL  string    `json:"short_url"`
+	}
+
+	// LinkQuery — страница ссылок пользователя. Ссылки упорядочены по
+	// Sort, а при равенстве — по короткому коду, так что порядок
+	// однозначен и страницы не пересекаются.
+	LinkQuery struct {
+		UserID string
+		// Contains — подстрока адреса, без учёта регистра
+		Contains string
+		Tag      string
+		// StateActive, StateDeleted или StateExpired, пусто — все
+		State string
+		// SortCreated или SortClicks, пусто — SortCreated
+		Sort  string
+		Desc  bool
+		After *ListCursor
+		Limit int
+		// Now — от чего считать срок, по умолчанию текущее время
+		Now time.Time
+	}
+
+	// LinkLister реализуют хранилища, которые умеют отдавать страницу
+	// ссылок сами, не выгружая все ссылки пользователя.
+	LinkLister interface {
+		ListLinks(q LinkQuery) ([]Link, error)
+	}
+
+	// Clicks — переходы по ссылке с прошлого сброса счётчиков.
+	Clicks struct {
+		Count  int64     `json:"count"`
+		LastAt time.Time `json:"last_at"`
+	}
+
+	// ClickCounter реализуют хранилища, которые хранят число переходов и
+	// время последнего.
+	ClickCounter interface {
+		// AddClicks прибавляет к ссылкам clicks[hash] переходов и
+		// сдвигает LastAccessedAt, неизвестные коды пропускаются
+		AddClicks(clicks map[string]Clicks) error
+	}
+)
+
+// Match — подходит ли ссылка под фильтры запроса, без учёта курсора.
+func (q LinkQuery) Match(link Link) bool {
+	if link.UserID != q.UserID {
+		return false
+	}
+	if q.Contains != "" && !strings.Contains(strings.ToLower(link.OriginalURL), strings.ToLower(q.Contains)) {
+		return false
+	}
+	if q.Tag != "" && !link.HasTag(q.Tag) {
+		return false
+	}
+
+	expired := link.Expired(q.now())
+	switch q.State {
+	case StateActive:
+		return !link.IsDeleted && !expired
+	case StateDeleted:
+		return link.IsDeleted
+	case StateExpired:
+		return !link.IsDeleted && expired
+	}
+	return true
+}
+
+// Compare упорядочивает ссылки так, как их отдаёт запрос.
+func (q LinkQuery) Compare(a, b ListCursor) int {
+	var n int
+	if q.Sort == SortClicks {
+		n = cmp.Compare(a.Clicks, b.Clicks)
+	} else {
+		n = a.CreatedAt.Compare(b.CreatedAt)
+	}
+	if n == 0 {
+		n = strings.Compare(a.ShortURL, b.ShortURL)
+	}
+	if q.Desc {
+		return -n
+	}
+	return n
+}
+
+func (q LinkQuery) now() time.Time {
+	if q.Now.IsZero() {
+		return time.Now()
+	}
+	return q.Now
+}
+
+func CursorOf(link Link) ListCursor {
+	return ListCursor{CreatedAt: link.CreatedAt, Clicks: link.Clicks, ShortURL: link.ShortURL}
+}
+
+// ListLinks отдаёт страницу ссылок. Хранилища без LinkLister перебирают
+// все ссылки пользователя, но держат в памяти только подошедшие.
+func ListLinks(s Storage, q LinkQuery) ([]Link, error) {
+	if l, ok := s.(LinkLister); ok {
+		return l.ListLinks(q)
+	}
+
+	var page []Link
+	err := s.UserLinks(q.UserID, func(link Link) error {
+		if !q.Match(link) {
+			return nil
+		}
+		if q.After != nil && q.Compare(CursorOf(link), *q.After) <= 0 {
+			return nil
+		}
+		page = append(page, link)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	slices.SortFunc(page, func(a, b Link) int {
+		return q.Compare(CursorOf(a), CursorOf(b))
+	})
+	if q.Limit > 0 && len(page) > q.Limit {
+		page = page[:q.Limit]
+	}
+
+	return page, nil
+}
+
+// AddClicks передаёт переходы хранилищу s, если оно их хранит.
+func AddClicks(s Storage, clicks map[string]Clicks) error {
+	if c, ok := s.(ClickCounter); ok {
+		return c.AddClicks(clicks)
+	}
+	return nil
+}
+
+// Add учитывает переходы other.
+func (c Clicks) Add(other Clicks) Clicks {
+	c.Count += other.Count
+	if other.LastAt.After(c.LastAt) {
+		c.LastAt = other.LastAt
+	}
+	return c
+}
+
+// Apply переносит переходы в ссылку.
+func (c Clicks) Apply(link *Link) {
+	link.Clicks += c.Count
+	if link.LastAccessedAt == nil || c.LastAt.After(*link.LastAccessedAt) {
+		at := c.LastAt
+		link.LastAccessedAt = &at
+	}
+}
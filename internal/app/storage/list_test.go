@@ -0,0 +1,104 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type listStorage struct {
+	countingStorage
+	links []Link
+}
+
+func (s *listStorage) UserLinks(userID string, fn func(link Link) error) error {
+	for _, link := range s.links {
+		if link.UserID != userID {
+			continue
+		}
+		if err := fn(link); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestListLinks(t *testing.T) {
+	var (
+		now  = time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+		past = now.Add(-time.Hour)
+	)
+
+	store := &listStorage{links: []Link{
+		{ShortURL: "a", OriginalURL: "https://Go.dev/doc", UserID: "u", CreatedAt: now.Add(-3 * time.Hour), Clicks: 5, Tags: []string{"go"}},
+		{ShortURL: "b", OriginalURL: "https://ya.ru", UserID: "u", CreatedAt: now.Add(-2 * time.Hour), Clicks: 5},
+		{ShortURL: "c", OriginalURL: "https://go.dev/blog", UserID: "u", CreatedAt: now.Add(-time.Hour), Clicks: 1, IsDeleted: true},
+		{ShortURL: "d", OriginalURL: "https://vk.ru", UserID: "u", CreatedAt: now, Clicks: 9, ExpiresAt: &past},
+		{ShortURL: "e", OriginalURL: "https://go.dev", UserID: "other", CreatedAt: now},
+	}}
+
+	codes := func(links []Link) []string {
+		var result []string
+		for _, link := range links {
+			result = append(result, link.ShortURL)
+		}
+		return result
+	}
+
+	tests := []struct {
+		name  string
+		query LinkQuery
+		want  []string
+	}{
+		{
+			name:  "Oldest first",
+			query: LinkQuery{UserID: "u"},
+			want:  []string{"a", "b", "c", "d"},
+		},
+		{
+			name:  "Most clicked first, ties by code",
+			query: LinkQuery{UserID: "u", Sort: SortClicks, Desc: true},
+			want:  []string{"d", "b", "a", "c"},
+		},
+		{
+			name:  "Page after a cursor",
+			query: LinkQuery{UserID: "u", Sort: SortClicks, Desc: true, Limit: 2, After: &ListCursor{Clicks: 5, ShortURL: "b"}},
+			want:  []string{"a", "c"},
+		},
+		{
+			name:  "Substring of the address",
+			query: LinkQuery{UserID: "u", Contains: "GO.DEV"},
+			want:  []string{"a", "c"},
+		},
+		{
+			name:  "Tag",
+			query: LinkQuery{UserID: "u", Tag: "go"},
+			want:  []string{"a"},
+		},
+		{
+			name:  "Active",
+			query: LinkQuery{UserID: "u", State: StateActive, Now: now},
+			want:  []string{"a", "b"},
+		},
+		{
+			name:  "Deleted",
+			query: LinkQuery{UserID: "u", State: StateDeleted, Now: now},
+			want:  []string{"c"},
+		},
+		{
+			name:  "Expired",
+			query: LinkQuery{UserID: "u", State: StateExpired, Now: now},
+			want:  []string{"d"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			links, err := ListLinks(store, tt.query)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, codes(links))
+		})
+	}
+}
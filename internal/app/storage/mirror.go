@@ -0,0 +1,311 @@
+package storage
+
+import (
+	"errors"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/BazNick/shortlink/internal/app/apperr"
+)
+
+// сколько расходящихся кодов держать в отчёте
+const reportSampleSize = 20
+
+type (
+	MirrorStats struct {
+		SecondaryErrors uint64           `json:"secondary_errors"`
+		FallbackReads   uint64           `json:"fallback_reads"`
+		LastReport      *ReconcileReport `json:"last_report,omitempty"`
+	}
+
+	ReconcileReport struct {
+		CheckedAt          time.Time `json:"checked_at"`
+		Primary            int       `json:"primary"`
+		Secondary          int       `json:"secondary"`
+		MissingInSecondary int       `json:"missing_in_secondary"`
+		MissingInPrimary   int       `json:"missing_in_primary"`
+		Mismatched         int       `json:"mismatched"`
+		Samples            []string  `json:"samples,omitempty"`
+	}
+
+	// Mirror пишет в оба хранилища, а читает из основного. Если основное
+	// вернуло ошибку и включён fallback, ссылка берётся из запасного.
+	Mirror struct {
+		primary   Storage
+		secondary Storage
+		fallback  bool
+
+		secondaryErrors atomic.Uint64
+		fallbackReads   atomic.Uint64
+
+		mu         sync.Mutex
+		lastReport *ReconcileReport
+	}
+)
+
+func NewMirror(primary, secondary Storage, fallback bool) *Mirror {
+	return &Mirror{
+		primary:   primary,
+		secondary: secondary,
+		fallback:  fallback,
+	}
+}
+
+func (m *Mirror) Unwrap() Storage {
+	return m.primary
+}
+
+func (m *Mirror) AddHash(hash, link, userID string) (string, error) {
+	shortURL, err := m.primary.AddHash(hash, link, userID)
+	if err != nil {
+		// при конфликте ссылка уже лежит в обоих хранилищах
+		return shortURL, err
+	}
+
+	_, errSecondary := m.secondary.AddHash(hash, link, userID)
+	if errSecondary != nil && !errors.Is(errSecondary, apperr.ErrValAlreadyExists) {
+		m.secondaryFailed("add", errSecondary)
+	}
+
+	return shortURL, nil
+}
+
+func (m *Mirror) AddBatch(links []Link) error {
+	if err := m.primary.AddBatch(links); err != nil {
+		return err
+	}
+
+	if err := m.secondary.AddBatch(links); err != nil {
+		m.secondaryFailed("batch", err)
+	}
+
+	return nil
+}
+
+func (m *Mirror) InsertLink(link Link) error {
+	// время создания задаётся здесь, чтобы совпасть в обоих хранилищах
+	if link.CreatedAt.IsZero() {
+		link.CreatedAt = time.Now()
+	}
+	if err := InsertLink(m.primary, link); err != nil {
+		return err
+	}
+
+	if err := m.secondary.AddBatch([]Link{link}); err != nil {
+		m.secondaryFailed("insert", err)
+	}
+
+	return nil
+}
+
+func (m *Mirror) GetHash(hash string) string {
+	link, err := Lookup(m.primary, hash)
+	if err == nil || !m.fallback {
+		return link
+	}
+
+	m.fallbackReads.Add(1)
+	link, err = Lookup(m.secondary, hash)
+	if err != nil {
+		return ""
+	}
+
+	return link
+}
+
+func (m *Mirror) GetLink(hash string) (Link, error) {
+	return m.primary.GetLink(hash)
+}
+
+func (m *Mirror) CheckValExists(userID, link string) bool {
+	return m.primary.CheckValExists(userID, link)
+}
+
+// SetDedup нужен обоим хранилищам, иначе запасное не примет ссылки,
+// которые основное считает разными.
+func (m *Mirror) SetDedup(mode Dedup) error {
+	if err := SetDedup(m.primary, mode); err != nil {
+		return err
+	}
+	return SetDedup(m.secondary, mode)
+}
+
+func (m *Mirror) ListLinks(q LinkQuery) ([]Link, error) {
+	return ListLinks(m.primary, q)
+}
+
+func (m *Mirror) SearchLinks(q SearchQuery) ([]SearchResult, error) {
+	return SearchLinks(m.primary, q)
+}
+
+func (m *Mirror) AddClicks(clicks map[string]Clicks) error {
+	if err := AddClicks(m.primary, clicks); err != nil {
+		return err
+	}
+
+	if err := AddClicks(m.secondary, clicks); err != nil {
+		m.secondaryFailed("clicks", err)
+	}
+
+	return nil
+}
+
+func (m *Mirror) DeleteLinks(userID string, hashes []string) error {
+	if err := m.primary.DeleteLinks(userID, hashes); err != nil {
+		return err
+	}
+
+	if err := m.secondary.DeleteLinks(userID, hashes); err != nil {
+		m.secondaryFailed("delete", err)
+	}
+
+	return nil
+}
+
+func (m *Mirror) Links(fn func(link Link) error) error {
+	return m.primary.Links(fn)
+}
+
+func (m *Mirror) UserLinks(userID string, fn func(link Link) error) error {
+	return m.primary.UserLinks(userID, fn)
+}
+
+func (m *Mirror) WorkspaceLinks(workspaceID string, fn func(link Link) error) error {
+	return m.primary.WorkspaceLinks(workspaceID, fn)
+}
+
+func (m *Mirror) ReassignLinks(from, to string) (int, error) {
+	n, err := m.primary.ReassignLinks(from, to)
+	if err != nil {
+		return n, err
+	}
+
+	if _, err := m.secondary.ReassignLinks(from, to); err != nil {
+		m.secondaryFailed("reassign", err)
+	}
+
+	return n, nil
+}
+
+func (m *Mirror) UpdateLink(hash string, fn func(link *Link) error) (Link, error) {
+	link, err := m.primary.UpdateLink(hash, fn)
+	if err != nil {
+		return link, err
+	}
+
+	// во второе хранилище кладём ровно то, что получилось в основном
+	_, err = m.secondary.UpdateLink(hash, func(l *Link) error {
+		*l = link
+		return nil
+	})
+	if err != nil {
+		m.secondaryFailed("update", err)
+	}
+
+	return link, nil
+}
+
+func (m *Mirror) Stats() MirrorStats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return MirrorStats{
+		SecondaryErrors: m.secondaryErrors.Load(),
+		FallbackReads:   m.fallbackReads.Load(),
+		LastReport:      m.lastReport,
+	}
+}
+
+// Reconcile сравнивает содержимое хранилищ и запоминает отчёт.
+func (m *Mirror) Reconcile() (*ReconcileReport, error) {
+	report, err := Reconcile(m.primary, m.secondary)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	m.lastReport = report
+	m.mu.Unlock()
+
+	return report, nil
+}
+
+// StartReconcile периодически сверяет хранилища и пишет расхождения в лог.
+func (m *Mirror) StartReconcile(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			report, err := m.Reconcile()
+			if err != nil {
+				log.Printf("mirror: reconcile failed: %v", err)
+				continue
+			}
+			if report.MissingInPrimary+report.MissingInSecondary+report.Mismatched > 0 {
+				log.Printf(
+					"mirror: divergence found: missing in secondary %d, missing in primary %d, mismatched %d",
+					report.MissingInSecondary,
+					report.MissingInPrimary,
+					report.Mismatched,
+				)
+			}
+		}
+	}()
+}
+
+func (m *Mirror) secondaryFailed(op string, err error) {
+	m.secondaryErrors.Add(1)
+	log.Printf("mirror: secondary %s failed: %v", op, err)
+}
+
+// Reconcile сравнивает два хранилища по всем ссылкам.
+func Reconcile(primary, secondary Storage) (*ReconcileReport, error) {
+	report := &ReconcileReport{CheckedAt: time.Now()}
+
+	links := make(map[string]Link)
+	err := primary.Links(func(link Link) error {
+		links[link.ShortURL] = link
+		report.Primary++
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sample := func(hash string) {
+		if len(report.Samples) < reportSampleSize {
+			report.Samples = append(report.Samples, hash)
+		}
+	}
+
+	err = secondary.Links(func(link Link) error {
+		report.Secondary++
+
+		want, ok := links[link.ShortURL]
+		if !ok {
+			report.MissingInPrimary++
+			sample(link.ShortURL)
+			return nil
+		}
+		delete(links, link.ShortURL)
+
+		if !want.Equal(link) {
+			report.Mismatched++
+			sample(link.ShortURL)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for hash := range links {
+		report.MissingInSecondary++
+		sample(hash)
+	}
+
+	return report, nil
+}
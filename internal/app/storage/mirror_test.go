@@ -0,0 +1,72 @@
+package storage
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type brokenStorage struct {
+	countingStorage
+}
+
+func (s *brokenStorage) LookupHash(hash string) (string, error) {
+	return "", errors.New("connection refused")
+}
+
+func TestMirror_GetHash(t *testing.T) {
+	tests := []struct {
+		name     string
+		fallback bool
+		want     string
+	}{
+		{
+			name:     "Fallback reads from the secondary storage",
+			fallback: true,
+			want:     "https://a.ru",
+		},
+		{
+			name:     "Without fallback the primary error is final",
+			fallback: false,
+			want:     "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				primary   = &brokenStorage{countingStorage{links: map[string]string{}}}
+				secondary = &countingStorage{links: map[string]string{"a": "https://a.ru"}}
+				mirror    = NewMirror(primary, secondary, tt.fallback)
+			)
+
+			require.Equal(t, tt.want, mirror.GetHash("a"))
+		})
+	}
+}
+
+func TestMirror_Reconcile(t *testing.T) {
+	var (
+		primary   = &countingStorage{links: map[string]string{}}
+		secondary = &countingStorage{links: map[string]string{}}
+		mirror    = NewMirror(primary, secondary, false)
+	)
+
+	mirror.AddHash("a", "https://a.ru", "user")
+	mirror.AddHash("b", "https://b.ru", "user")
+	primary.links["c"] = "https://c.ru"
+	secondary.links["d"] = "https://d.ru"
+	secondary.links["b"] = "https://changed.ru"
+
+	report, err := mirror.Reconcile()
+	require.NoError(t, err)
+
+	require.Equal(t, 3, report.Primary)
+	require.Equal(t, 3, report.Secondary)
+	require.Equal(t, 1, report.MissingInSecondary)
+	require.Equal(t, 1, report.MissingInPrimary)
+	require.Equal(t, 1, report.Mismatched)
+	require.ElementsMatch(t, []string{"b", "c", "d"}, report.Samples)
+	require.Equal(t, report, mirror.Stats().LastReport)
+}
@@ -0,0 +1,64 @@
+package storage
+
+import "time"
+
+// состояния жалобы
+const (
+	ReportOpen      = "open"
+	ReportDismissed = "dismissed"
+	// по жалобе ссылку отключили
+	ReportActioned = "actioned"
+)
+
+type (
+	// Report — жалоба на ссылку. ReporterID пуст, если жалобу оставил
+	// анонимный пользователь без cookie, тогда жалобщик — это IP.
+	Report struct {
+		ID         string     `json:"id"`
+		ShortURL   string     `json:"short_url"`
+		Reason     string     `json:"reason"`
+		ReporterID string     `json:"reporter_id,omitempty"`
+		IP         string     `json:"ip"`
+		CreatedAt  time.Time  `json:"created_at"`
+		Status     string     `json:"status"`
+		ResolvedBy string     `json:"resolved_by,omitempty"`
+		ResolvedAt *time.Time `json:"resolved_at,omitempty"`
+	}
+
+	// ReportFilter — пустые поля не ограничивают выборку.
+	ReportFilter struct {
+		ID       string
+		ShortURL string
+		Status   string
+	}
+
+	// ReportStore — очередь модерации. Reports отдаёт жалобы от старых к
+	// новым.
+	ReportStore interface {
+		AddReport(report Report) error
+		Reports(filter ReportFilter, fn func(report Report) error) error
+		// ResolveReports закрывает открытые жалобы, подходящие под filter,
+		// и возвращает, сколько их было.
+		ResolveReports(filter ReportFilter, status, by string, at time.Time) (int, error)
+	}
+)
+
+func (f ReportFilter) Match(r Report) bool {
+	switch {
+	case f.ID != "" && r.ID != f.ID:
+		return false
+	case f.ShortURL != "" && r.ShortURL != f.ShortURL:
+		return false
+	case f.Status != "" && r.Status != f.Status:
+		return false
+	}
+	return true
+}
+
+// Reporter — кто пожаловался: пользователь или, если его нет, адрес.
+func (r Report) Reporter() string {
+	if r.ReporterID != "" {
+		return "user:" + r.ReporterID
+	}
+	return "ip:" + r.IP
+}
@@ -0,0 +1,34 @@
+package storage
+
+import "errors"
+
+var ErrSearchUnsupported = errors.New("search is not supported by this storage")
+
+type (
+	// SearchQuery — поиск по ссылкам пользователя: адресу, заголовку,
+	// описанию и меткам. Удалённые ссылки не ищутся.
+	SearchQuery struct {
+		UserID string
+		Text   string
+		Limit  int
+	}
+
+	// SearchResult — найденная ссылка, самые подходящие идут первыми.
+	SearchResult struct {
+		Link
+		Rank float64 `json:"rank"`
+	}
+
+	// Searcher реализуют хранилища с полнотекстовым поиском.
+	Searcher interface {
+		SearchLinks(q SearchQuery) ([]SearchResult, error)
+	}
+)
+
+// SearchLinks ищет ссылки, если хранилище это умеет.
+func SearchLinks(s Storage, q SearchQuery) ([]SearchResult, error) {
+	if searcher, ok := s.(Searcher); ok {
+		return searcher.SearchLinks(q)
+	}
+	return nil, ErrSearchUnsupported
+}
@@ -1,7 +1,124 @@
 package storage
 
+import (
+	"slices"
+	"time"
+)
+
 type Storage interface {
 	AddHash(hash, link, userID string) (string, error)
+	AddBatch(links []Link) error
 	GetHash(hash string) string
-	CheckValExists(link string) bool
-}
\ No newline at end of file
+	GetLink(hash string) (Link, error)
+	// CheckValExists — сокращал ли уже кто-то link, с учётом режима
+	// дедупликации: в DedupUser важен только сам userID.
+	CheckValExists(userID, link string) bool
+	DeleteLinks(userID string, hashes []string) error
+	Links(fn func(link Link) error) error
+	UserLinks(userID string, fn func(link Link) error) error
+	WorkspaceLinks(workspaceID string, fn func(link Link) error) error
+	// ReassignLinks передаёт все ссылки пользователя from пользователю to
+	// и возвращает, сколько их было.
+	ReassignLinks(from, to string) (int, error)
+	// UpdateLink меняет одну ссылку: fn получает текущую запись и правит
+	// её на месте. Код ссылки не меняется.
+	UpdateLink(hash string, fn func(link *Link) error) (Link, error)
+}
+
+// Link — запись о короткой ссылке так, как её хранят все бэкенды.
+type Link struct {
+	ShortURL    string `json:"short_url"`
+	OriginalURL string `json:"original_url"`
+	UserID      string `json:"user_id,omitempty"`
+	IsDeleted   bool   `json:"is_deleted,omitempty"`
+	// IsDisabled — ссылку отключил администратор, владелец её не удалял
+	IsDisabled bool `json:"is_disabled,omitempty"`
+	// WorkspaceID — пространство, которому принадлежит ссылка, пусто у
+	// личных ссылок
+	WorkspaceID string `json:"workspace_id,omitempty"`
+	// ExpiresAt — после этого момента ссылка не открывается, пусто —
+	// бессрочная
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	// подписи владельца, на переход не влияют
+	Title       string   `json:"title,omitempty"`
+	Description string   `json:"description,omitempty"`
+	Tags        []string `json:"tags,omitempty"`
+	// CreatedAt и UpdatedAt проставляет хранилище, если ссылку добавили
+	// без них. UpdatedAt — последнее изменение записи, переходы не в счёт
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	// LastAccessedAt — последний переход, пусто — переходов не было;
+	// пишется вместе с Clicks
+	LastAccessedAt *time.Time `json:"last_accessed_at,omitempty"`
+	// Clicks — переходы по ссылке; они копятся в clicks.Counter и
+	// попадают сюда с задержкой
+	Clicks int64 `json:"clicks"`
+}
+
+func (l Link) Expired(now time.Time) bool {
+	return l.ExpiresAt != nil && !now.Before(*l.ExpiresAt)
+}
+
+// SetEditable переносит из from то, что владелец может менять сам.
+func (l *Link) SetEditable(from Link) {
+	l.OriginalURL = from.OriginalURL
+	l.ExpiresAt = from.ExpiresAt
+	l.Title = from.Title
+	l.Description = from.Description
+	l.Tags = from.Tags
+}
+
+// Equal сравнивает ссылки по значению: срок — как момент времени, а не
+// указатель, пустой список меток равен nil. Время записей и переходы
+// каждое хранилище ведёт само, они не сравниваются.
+func (l Link) Equal(other Link) bool {
+	if l.ShortURL != other.ShortURL || l.OriginalURL != other.OriginalURL || l.UserID != other.UserID ||
+		l.IsDeleted != other.IsDeleted || l.IsDisabled != other.IsDisabled || l.WorkspaceID != other.WorkspaceID ||
+		l.Title != other.Title || l.Description != other.Description || !slices.Equal(l.Tags, other.Tags) {
+		return false
+	}
+	if l.ExpiresAt == nil || other.ExpiresAt == nil {
+		return l.ExpiresAt == other.ExpiresAt
+	}
+	return l.ExpiresAt.Equal(*other.ExpiresAt)
+}
+
+func (l Link) HasTag(tag string) bool {
+	return slices.Contains(l.Tags, tag)
+}
+
+// Unwrapper реализуют хранилища-обёртки (кэш и т.п.), чтобы можно было
+// добраться до хранилища, которое лежит под ними.
+type Unwrapper interface {
+	Unwrap() Storage
+}
+
+// Lookuper реализуют хранилища, которые умеют отличать отсутствие ссылки
+// от ошибки чтения.
+type Lookuper interface {
+	LookupHash(hash string) (string, error)
+}
+
+// As ищет в цепочке обёрток хранилище нужного типа.
+func As[T Storage](s Storage) (T, bool) {
+	for s != nil {
+		if target, ok := s.(T); ok {
+			return target, true
+		}
+		u, ok := s.(Unwrapper)
+		if !ok {
+			break
+		}
+		s = u.Unwrap()
+	}
+	var zero T
+	return zero, false
+}
+
+// Lookup читает ссылку, возвращая ошибку, если хранилище умеет её сообщать.
+func Lookup(s Storage, hash string) (string, error) {
+	if l, ok := s.(Lookuper); ok {
+		return l.LookupHash(hash)
+	}
+	return s.GetHash(hash), nil
+}
@@ -0,0 +1,26 @@
+package storage
+
+import "time"
+
+// RoleAdmin — роль с доступом к /api/admin
+const RoleAdmin = "admin"
+
+// User — зарегистрированный пользователь. ID совпадает с UserID его
+// ссылок, пароль хранится только в виде bcrypt-хэша. У пришедших через
+// OIDC пароля нет, зато есть Subject — sub от провайдера.
+type User struct {
+	ID           string    `json:"id"`
+	Login        string    `json:"login"`
+	PasswordHash string    `json:"password_hash,omitempty"`
+	Subject      string    `json:"subject,omitempty"`
+	Role         string    `json:"role,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+type UserStore interface {
+	AddUser(user User) error
+	UserByLogin(login string) (User, error)
+	UserByID(id string) (User, error)
+	UserBySubject(subject string) (User, error)
+	SetUserRole(id, role string) error
+}
@@ -0,0 +1,26 @@
+package storage
+
+import "time"
+
+type (
+	// LinkVersion — состояние ссылки после очередной правки. Версии
+	// нумеруются с 1, первая — ссылка такой, какой она была до первой
+	// правки.
+	LinkVersion struct {
+		ShortURL  string    `json:"short_url"`
+		Version   int       `json:"version"`
+		Link      Link      `json:"link"`
+		ChangedBy string    `json:"changed_by,omitempty"`
+		ChangedAt time.Time `json:"changed_at"`
+		// RestoredFrom — к какой версии откатили ссылку этой правкой
+		RestoredFrom int `json:"restored_from,omitempty"`
+	}
+
+	// VersionStore — история правок ссылок, только дописывается.
+	VersionStore interface {
+		// AddVersion сохраняет v под следующим номером и возвращает его.
+		AddVersion(v LinkVersion) (LinkVersion, error)
+		// Versions отдаёт версии ссылки от первой к последней.
+		Versions(hash string, fn func(v LinkVersion) error) error
+	}
+)
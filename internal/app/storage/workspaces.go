@@ -0,0 +1,56 @@
+package storage
+
+import "time"
+
+// роли участников по возрастанию прав: viewer видит ссылки, editor ещё
+// создаёт и удаляет любые ссылки пространства, owner ещё управляет
+// участниками
+const (
+	WorkspaceViewer = "viewer"
+	WorkspaceEditor = "editor"
+	WorkspaceOwner  = "owner"
+)
+
+var workspaceRoleRank = map[string]int{
+	WorkspaceViewer: 1,
+	WorkspaceEditor: 2,
+	WorkspaceOwner:  3,
+}
+
+type (
+	Workspace struct {
+		ID        string    `json:"id"`
+		Name      string    `json:"name"`
+		CreatedAt time.Time `json:"created_at"`
+	}
+
+	Member struct {
+		WorkspaceID string    `json:"workspace_id"`
+		UserID      string    `json:"user_id"`
+		Role        string    `json:"role"`
+		AddedAt     time.Time `json:"added_at"`
+	}
+
+	WorkspaceStore interface {
+		// AddWorkspace создаёт пространство вместе с его первым владельцем.
+		AddWorkspace(ws Workspace, owner Member) error
+		Workspace(id string) (Workspace, error)
+		// UserWorkspaces — пространства, где userID участник.
+		UserWorkspaces(userID string) ([]Member, error)
+		Member(workspaceID, userID string) (Member, error)
+		Members(workspaceID string) ([]Member, error)
+		// PutMember добавляет участника или меняет его роль.
+		PutMember(m Member) error
+		RemoveMember(workspaceID, userID string) error
+	}
+)
+
+func ValidWorkspaceRole(role string) bool {
+	_, ok := workspaceRoleRank[role]
+	return ok
+}
+
+// Can — хватает ли роли участника для действия, требующего role.
+func (m Member) Can(role string) bool {
+	return workspaceRoleRank[m.Role] >= workspaceRoleRank[role]
+}
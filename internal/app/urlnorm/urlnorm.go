@@ -0,0 +1,166 @@
+// Package urlnorm проверяет ссылки перед сокращением и приводит их к
+// одному написанию, чтобы одинаковые адреса не сокращались дважды.
+package urlnorm
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/idna"
+)
+
+const (
+	ReasonEmpty  = "empty"
+	ReasonSyntax = "syntax"
+	ReasonScheme = "scheme"
+	ReasonHost   = "host"
+)
+
+// DefaultSchemes разрешены, если Options.Schemes не задан.
+var DefaultSchemes = []string{"http", "https"}
+
+var defaultPorts = map[string]string{
+	"http":  "80",
+	"https": "443",
+}
+
+type (
+	Options struct {
+		// разрешённые схемы, по умолчанию DefaultSchemes
+		Schemes []string
+		// убирать порт, если он для схемы стандартный
+		StripDefaultPort bool
+		// убирать #фрагмент
+		StripFragment bool
+		// сортировать параметры запроса по имени
+		SortQuery bool
+	}
+
+	// Normalizer проверяет и приводит ссылки к одному виду. Хост всегда
+	// переводится в нижний регистр и в punycode, остальное — по Options.
+	// nil *Normalizer работает с Options по умолчанию.
+	Normalizer struct {
+		opts    Options
+		schemes map[string]bool
+	}
+
+	// Error — ссылка, которую нельзя сократить, отдаётся клиенту как есть.
+	Error struct {
+		Message string `json:"error"`
+		Reason  string `json:"reason"`
+		URL     string `json:"url"`
+	}
+)
+
+func New(opts Options) *Normalizer {
+	schemes := make(map[string]bool, len(opts.Schemes))
+	for _, scheme := range opts.Schemes {
+		if scheme = strings.ToLower(strings.TrimSpace(scheme)); scheme != "" {
+			schemes[scheme] = true
+		}
+	}
+	if len(schemes) == 0 {
+		for _, scheme := range DefaultSchemes {
+			schemes[scheme] = true
+		}
+	}
+
+	return &Normalizer{opts: opts, schemes: schemes}
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// Normalize возвращает ссылку в каноническом виде или *Error.
+func (n *Normalizer) Normalize(raw string) (string, error) {
+	if n == nil {
+		n = New(Options{})
+	}
+
+	link := strings.TrimSpace(raw)
+	if link == "" {
+		return "", &Error{Message: "url is empty", Reason: ReasonEmpty, URL: raw}
+	}
+
+	u, err := url.Parse(link)
+	if err != nil {
+		return "", &Error{Message: "url cannot be parsed", Reason: ReasonSyntax, URL: raw}
+	}
+
+	if u.Scheme == "" {
+		return "", &Error{Message: "url must be absolute", Reason: ReasonScheme, URL: raw}
+	}
+	if !n.schemes[u.Scheme] {
+		return "", &Error{
+			Message: fmt.Sprintf("scheme %q is not allowed", u.Scheme),
+			Reason:  ReasonScheme,
+			URL:     raw,
+		}
+	}
+
+	// http:example.com и http:///path хоста не содержат
+	if u.Opaque != "" || u.Host == "" {
+		return "", &Error{Message: "url has no host", Reason: ReasonHost, URL: raw}
+	}
+
+	host, err := canonicalHost(u.Hostname())
+	if err != nil {
+		return "", &Error{
+			Message: fmt.Sprintf("host %q is not valid", u.Hostname()),
+			Reason:  ReasonHost,
+			URL:     raw,
+		}
+	}
+
+	port := u.Port()
+	if n.opts.StripDefaultPort && port == defaultPorts[u.Scheme] {
+		port = ""
+	}
+	if port != "" {
+		host = net.JoinHostPort(host, port)
+	} else if strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+	u.Host = host
+
+	if n.opts.StripFragment {
+		u.Fragment = ""
+		u.RawFragment = ""
+	}
+
+	if n.opts.SortQuery && u.RawQuery != "" {
+		// кривой запрос оставляем как есть, сервер назначения разберётся
+		if query, err := url.ParseQuery(u.RawQuery); err == nil {
+			u.RawQuery = query.Encode()
+		}
+	}
+
+	return u.String(), nil
+}
+
+// canonicalHost переводит хост в нижний регистр, а IDN — в punycode.
+// Адреса IP возвращаются без скобок.
+func canonicalHost(host string) (string, error) {
+	if host == "" {
+		return "", fmt.Errorf("empty host")
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		return ip.String(), nil
+	}
+
+	for i := 0; i < len(host); i++ {
+		if host[i] >= 0x80 {
+			return idna.Lookup.ToASCII(host)
+		}
+	}
+
+	// ASCII-хост оставляем без проверок idna: в реальных именах встречается
+	// подчёркивание, которое она не пропускает
+	if strings.ContainsAny(host, " %") {
+		return "", fmt.Errorf("invalid host")
+	}
+	return strings.ToLower(host), nil
+}
@@ -0,0 +1,59 @@
+package urlnorm
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalize(t *testing.T) {
+	all := New(Options{
+		StripDefaultPort: true,
+		StripFragment:    true,
+		SortQuery:        true,
+	})
+
+	tests := []struct {
+		name   string
+		n      *Normalizer
+		in     string
+		want   string
+		reason string
+	}{
+		{name: "as is", in: "https://example.com/Path?b=2&a=1#top", want: "https://example.com/Path?b=2&a=1#top"},
+		{name: "whitespace", in: "  https://example.com/\n", want: "https://example.com/"},
+		{name: "host case", in: "HTTP://Example.COM/A", want: "http://example.com/A"},
+		{name: "idn", in: "https://Пример.рф/путь", want: "https://xn--e1afmkfd.xn--p1ai/%D0%BF%D1%83%D1%82%D1%8C"},
+		{name: "ipv6", in: "http://[::1]:8080/", want: "http://[::1]:8080/"},
+		{name: "default port kept", in: "https://example.com:443/", want: "https://example.com:443/"},
+		{name: "default port", n: all, in: "https://example.com:443/", want: "https://example.com/"},
+		{name: "other port", n: all, in: "http://example.com:443/", want: "http://example.com:443/"},
+		{name: "fragment", n: all, in: "https://example.com/#top", want: "https://example.com/"},
+		{name: "sorted query", n: all, in: "https://example.com/?b=2&a=1&a=0", want: "https://example.com/?a=1&a=0&b=2"},
+		{name: "own scheme", n: New(Options{Schemes: []string{"ftp"}}), in: "ftp://example.com/f", want: "ftp://example.com/f"},
+		{name: "empty", in: " \n", reason: ReasonEmpty},
+		{name: "javascript", in: "javascript:alert(1)", reason: ReasonScheme},
+		{name: "relative", in: "example.com/path", reason: ReasonScheme},
+		{name: "disallowed scheme", n: New(Options{Schemes: []string{"ftp"}}), in: "https://example.com", reason: ReasonScheme},
+		{name: "no host", in: "http:///path", reason: ReasonHost},
+		{name: "opaque", in: "http:example.com", reason: ReasonHost},
+		{name: "broken", in: "http://exa mple.com", reason: ReasonSyntax},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.n.Normalize(tt.in)
+			if tt.reason != "" {
+				var urlErr *Error
+				require.True(t, errors.As(err, &urlErr), err)
+				assert.Equal(t, tt.reason, urlErr.Reason)
+				assert.Equal(t, tt.in, urlErr.URL)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}